@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/control"
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const agentLogCommand = "agent-log"
+
+// agentLog asks the running wrapper to rewrite the agent seelog configuration.
+func agentLog(args []string) int {
+	flags := flag.NewFlagSet(agentLogCommand, flag.ContinueOnError)
+	socket := flags.String("socket", controlSocket(), "wrapper control socket")
+	level := flags.String("level", "", "agent log level: trace, debug, info, warn, error, critical or off")
+	output := flags.String("output", "", "agent log output: stdout, file or both")
+	restart := flags.Bool("restart", false, "restart the agent after rewriting the configuration")
+
+	code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	resp, err := control.SetAgentLogging(context.Background(), *socket, control.LoggingRequest{
+		Level:   *level,
+		Output:  *output,
+		Restart: *restart,
+	})
+	if err != nil {
+		slog.Error("update agent logging", logging.Err(err))
+
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	err = encoder.Encode(resp)
+	if err != nil {
+		slog.Error("write response", logging.Err(err))
+
+		return 1
+	}
+
+	return 0
+}
+
+func controlSocket() string {
+	if path := env.GetString(internal.EnvControlSocket); path != "" {
+		return path
+	}
+
+	return internal.DefaultControlSocket
+}
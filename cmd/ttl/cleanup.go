@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/awsconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+)
+
+const cleanupCommand = "cleanup"
+
+// cleanup manually tears down an activation and managed instance left behind by a wrapper that
+// could not clean up after itself. Without --instance-id the registration file is consulted.
+func cleanup(args []string) int {
+	flags := flag.NewFlagSet(cleanupCommand, flag.ContinueOnError)
+	activationID := flags.String("activation-id", "", "SSM activation ID to delete")
+	instanceID := flags.String("instance-id", "", "managed instance ID (mi-...) to deregister")
+	registrationFile := flags.String("registration-file", internal.RegistrationFilePath,
+		"registration file to read the managed instance ID from when --instance-id is not set")
+	region := flags.String("region", defaultRegion(), "AWS region (default from AWS_REGION or AWS_DEFAULT_REGION)")
+
+	code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	// The cleaner's records rely on the correlation fields for the IDs, as in the run command.
+	logging.Annotate(logging.KeyActivationID, *activationID)
+
+	if *instanceID != "" {
+		logging.Annotate(logging.KeyManagedInstanceID, *instanceID)
+	}
+
+	ctx := context.Background()
+
+	px, err := proxy.FromEnvironment()
+	if err != nil {
+		slog.Error("read proxy settings", logging.Err(err))
+
+		return 1
+	}
+
+	// Endpoint, FIPS, dual-stack and CA bundle settings come from the SDK's own AWS_* variables.
+	client, err := awsconfig.NewSSMClient(ctx, *region, config.AWS{}, px)
+	if err != nil {
+		slog.Error("create ssm client", logging.Err(err))
+
+		return 1
+	}
+
+	var opts []ssmagent.CleanerOption
+	if *instanceID != "" {
+		opts = append(opts, ssmagent.WithInstanceID(*instanceID))
+	}
+
+	err = ssmagent.NewCleaner(client, *activationID, *registrationFile, opts...).Cleanup(ctx)
+	if err != nil {
+		slog.Error("cleanup failed", logging.Err(err))
+
+		return 1
+	}
+
+	slog.Info("cleanup complete")
+
+	return 0
+}
+
+func defaultRegion() string {
+	region := env.GetString(internal.EnvFallbackRegion)
+	if region == "" {
+		region = env.GetString(internal.EnvFallbackDefaultRegion)
+	}
+
+	return region
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/doctor"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+)
+
+const doctorCommand = "doctor"
+
+// runDoctor validates configuration, AWS permissions and the filesystem before a real start.
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet(doctorCommand, flag.ContinueOnError)
+	config.RegisterFlags(flags)
+	agentPath := flags.String("agent", internal.DefaultAgentPath, "amazon-ssm-agent binary to check")
+
+	code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	cfg, err := config.Load(runner.NewLookup(context.Background()), flags)
+	if err != nil {
+		report := doctor.Report{{Name: "configuration", Passed: false, Detail: err.Error()}}
+		_ = report.Write(os.Stdout)
+
+		return 1
+	}
+
+	report := append(doctor.Report{{Name: "configuration", Passed: true, Detail: "valid"}},
+		doctor.Run(context.Background(), doctor.Options{
+			Config:     cfg,
+			AgentPath:  *agentPath,
+			StatePaths: append(runner.StatePaths(), cfg.Agent.ConfigDir),
+		})...)
+
+	err = report.Write(os.Stdout)
+	if err != nil {
+		slog.Error("doctor failed", logging.Err(err))
+
+		return 1
+	}
+
+	if !report.Passed() {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const healthcheckCommand = "healthcheck"
+
+// healthcheck probes the running wrapper's health server, for use as a container health command.
+func healthcheck(args []string) int {
+	flags := flag.NewFlagSet(healthcheckCommand, flag.ContinueOnError)
+	addr := flags.String("addr", env.GetString(internal.EnvHealthListenAddr), "health server listen address")
+	live := flags.Bool("live", false, "check liveness instead of readiness")
+
+	code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	path := health.ReadinessPath
+	if *live {
+		path = health.LivenessPath
+	}
+
+	err := health.Probe(context.Background(), *addr, path)
+	if err != nil {
+		slog.Error("unhealthy", logging.Err(err))
+
+		return 1
+	}
+
+	return 0
+}
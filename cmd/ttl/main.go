@@ -2,21 +2,156 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"slices"
 
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
 	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
 )
 
+const (
+	helpCommand   = "help"
+	usageExitCode = 2
+)
+
+// command is a wrapper subcommand; run receives the arguments after the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
 func main() {
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+func commands() []command {
+	return []command{
+		{name: runner.RunCommand, summary: "register and supervise the agent (default)", run: runAgent},
+		{name: cleanupCommand, summary: "delete an activation and deregister a managed instance", run: cleanup},
+		{name: reapCommand, summary: "deregister managed instances left behind by killed wrappers", run: reap},
+		{name: doctorCommand, summary: "run preflight checks for configuration, IAM, filesystem and network", run: runDoctor},
+		{name: healthcheckCommand, summary: "probe the running wrapper's health server", run: healthcheck},
+		{name: agentLogCommand, summary: "change the running agent's log level or output", run: agentLog},
+		{name: wakeCommand, summary: "wake a lazy wrapper so it registers and starts the agent", run: wake},
+		{name: versionCommand, summary: "print the wrapper and embedded agent versions", run: printVersion},
+		{name: helpCommand, summary: "show this help or a command's flags", run: help},
+	}
+}
+
+// dispatch runs the subcommand args select. The run command configures logging from its resolved
+// configuration; the others from the environment.
+func dispatch(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			return help(nil)
+		}
+	}
+
+	cmd, rest := resolve(args)
+	if cmd.name != runner.RunCommand && !setupLogging() {
+		return 1
+	}
+
+	return cmd.run(rest)
+}
+
+// resolve returns the named subcommand and its arguments. Anything else is treated as the run
+// command's arguments so that the original "ttl [flags] <cmd> [args...]" invocation keeps working.
+func resolve(args []string) (command, []string) {
+	all := commands()
+
+	if len(args) > 0 {
+		for _, cmd := range all {
+			if cmd.name == args[0] {
+				return cmd, args[1:]
+			}
+		}
+	}
+
+	return all[slices.IndexFunc(all, func(cmd command) bool { return cmd.name == runner.RunCommand })], args
+}
+
+// setupLogging configures logging from the environment and reports whether it is valid.
+func setupLogging() bool {
+	err := logging.Setup(os.Stderr, env.GetString(internal.EnvLogFormat), env.GetString(internal.EnvLogLevel))
+	if err != nil {
+		slog.Error("invalid logging configuration", logging.Err(err))
+
+		return false
+	}
 
-	app := runner.NewApp()
+	return true
+}
+
+func runAgent(args []string) int {
+	code, err := runner.NewApp().Run(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+
+	if err != nil {
+		slog.Error("wrapper failed", logging.Err(err))
+	}
+
+	return code
+}
+
+func help(args []string) int {
+	if len(args) > 0 && args[0] != helpCommand {
+		for _, cmd := range commands() {
+			if cmd.name == args[0] {
+				return cmd.run([]string{"-h"})
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+
+		return usageExitCode
+	}
+
+	usage(os.Stdout)
+
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
+  ttl [run] [flags] [--] <command> [args...]
+  ttl <command> [flags]
+
+Commands:
+`)
+
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprint(w, `
+Run "ttl help <command>" for a command's flags.
+`)
+}
+
+// parseFlags parses args into fs and maps the outcome to an exit code for the caller to return,
+// with ok reporting whether the command should continue.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0, false
+	}
 
-	code, err := app.Run()
 	if err != nil {
-		log.Printf("error: %v", err)
+		return usageExitCode, false
 	}
 
-	os.Exit(code)
+	return 0, true
 }
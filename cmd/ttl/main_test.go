@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCmd  string
+		wantArgs []string
+	}{
+		{name: "no arguments", args: nil, wantCmd: runner.RunCommand, wantArgs: nil},
+		{
+			name:     "explicit run",
+			args:     []string{runner.RunCommand, "--", "/service/amazon-ssm-agent"},
+			wantCmd:  runner.RunCommand,
+			wantArgs: []string{"--", "/service/amazon-ssm-agent"},
+		},
+		{
+			name:     "legacy invocation",
+			args:     []string{"--ttl-seconds", "900", "/service/amazon-ssm-agent"},
+			wantCmd:  runner.RunCommand,
+			wantArgs: []string{"--ttl-seconds", "900", "/service/amazon-ssm-agent"},
+		},
+		{
+			name:     "agent path named like a command",
+			args:     []string{"/usr/bin/cleanup"},
+			wantCmd:  runner.RunCommand,
+			wantArgs: []string{"/usr/bin/cleanup"},
+		},
+		{
+			name:     "subcommand",
+			args:     []string{cleanupCommand, "--activation-id", "id"},
+			wantCmd:  cleanupCommand,
+			wantArgs: []string{"--activation-id", "id"},
+		},
+		{name: "reap", args: []string{reapCommand, "--dry-run"}, wantCmd: reapCommand, wantArgs: []string{"--dry-run"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd, args := resolve(tt.args)
+			if cmd.name != tt.wantCmd || !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("resolve(%q) = %s %q, want %s %q", tt.args, cmd.name, args, tt.wantCmd, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestHelp(t *testing.T) {
+	t.Parallel()
+
+	if code := help([]string{"no-such-command"}); code != usageExitCode {
+		t.Errorf("help for an unknown command = %d, want %d", code, usageExitCode)
+	}
+
+	if code := dispatch([]string{"--help"}); code != 0 {
+		t.Errorf("--help = %d, want 0", code)
+	}
+
+	if code := dispatch([]string{reapCommand, "-h"}); code != 0 {
+		t.Errorf("reap -h = %d, want 0", code)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/awsconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+)
+
+const (
+	reapCommand = "reap"
+
+	defaultReapAge = time.Hour
+)
+
+// reap deregisters the managed instances of wrappers that were killed before they could clean up,
+// such as tasks stopped with SIGKILL. Their activations expire on their own and are left alone.
+func reap(args []string) int {
+	flags := flag.NewFlagSet(reapCommand, flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", defaultReapAge,
+		"only reap instances that have not pinged SSM for this long")
+	dryRun := flags.Bool("dry-run", false, "list the stale instances without deregistering them")
+	region := flags.String("region", defaultRegion(), "AWS region (default from AWS_REGION or AWS_DEFAULT_REGION)")
+
+	code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	ctx := context.Background()
+
+	px, err := proxy.FromEnvironment()
+	if err != nil {
+		slog.Error("read proxy settings", logging.Err(err))
+
+		return 1
+	}
+
+	client, err := awsconfig.NewSSMClient(ctx, *region, config.AWS{}, px)
+	if err != nil {
+		slog.Error("create ssm client", logging.Err(err))
+
+		return 1
+	}
+
+	stale, err := ssmagent.Reap(ctx, client, time.Now().Add(-*olderThan), *dryRun)
+
+	for _, instance := range stale {
+		fmt.Fprintf(os.Stdout, "%s\tlast ping %s\n", instance.InstanceID, instance.LastPing.UTC().Format(time.RFC3339))
+	}
+
+	if err != nil {
+		slog.Error("reap failed", logging.Err(err))
+
+		return 1
+	}
+
+	slog.Info("reap complete", slog.Int("instances", len(stale)), slog.Bool("dryRun", *dryRun))
+
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+)
+
+const (
+	versionCommand      = "version"
+	agentVersionTimeout = 5 * time.Second
+)
+
+var errEmptyAgentVersion = errors.New("agent reported no version")
+
+// version is stamped at build time with -ldflags "-X main.version=<version>".
+var version = ""
+
+// printVersion reports the wrapper version and asks the embedded agent for its own.
+func printVersion(args []string) int {
+	flags := flag.NewFlagSet(versionCommand, flag.ContinueOnError)
+	agentPath := flags.String("agent", internal.DefaultAgentPath, "amazon-ssm-agent binary to query")
+
+	code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	fmt.Fprintf(os.Stdout, "ttl %s (%s)\n", wrapperVersion(), runtime.Version())
+
+	agentVersion, err := queryAgentVersion(*agentPath)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "amazon-ssm-agent unknown (%v)\n", err)
+
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "amazon-ssm-agent %s\n", agentVersion)
+
+	return 0
+}
+
+func wrapperVersion() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return "dev"
+}
+
+// queryAgentVersion runs "amazon-ssm-agent -version", which prints "SSM Agent version: X.Y.Z".
+func queryAgentVersion(agentPath string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), agentVersionTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, agentPath, "-version").Output() // #nosec G204 -- path configured by operator
+	if err != nil {
+		return "", fmt.Errorf("run %s -version: %w", agentPath, err)
+	}
+
+	text := strings.TrimSpace(string(output))
+	if _, after, found := strings.Cut(text, ":"); found {
+		text = strings.TrimSpace(after)
+	}
+
+	if text == "" {
+		return "", fmt.Errorf("%w: %s", errEmptyAgentVersion, agentPath)
+	}
+
+	return text, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/control"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const wakeCommand = "wake"
+
+// wake asks a wrapper running in lazy mode to activate, register and start the agent.
+func wake(args []string) int {
+	flags := flag.NewFlagSet(wakeCommand, flag.ContinueOnError)
+	socket := flags.String("socket", controlSocket(), "wrapper control socket")
+
+	code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	resp, err := control.Wake(context.Background(), *socket)
+	if err != nil {
+		slog.Error("wake wrapper", logging.Err(err))
+
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	err = encoder.Encode(resp)
+	if err != nil {
+		slog.Error("write response", logging.Err(err))
+
+		return 1
+	}
+
+	return 0
+}
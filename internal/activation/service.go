@@ -3,31 +3,51 @@ package activation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/benwsapp/aws-ssm-minimal/internal"
-	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"github.com/benwsapp/aws-ssm-minimal/internal/awserr"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
 	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
 )
 
-const (
-	extraTagDelimiter     = ","
-	extraTagKeyValueParts = 2
-	defaultTagCapacity    = 4
+const defaultTagCapacity = 4
+
+// DefaultExpiry is how long SSM keeps an activation created without an expiration date, as the
+// wrapper creates them.
+const DefaultExpiry = 24 * time.Hour
+
+var (
+	// ErrDenied reports that SSM refused to create the activation for lack of permission, such as
+	// ssm:CreateActivation or iam:PassRole on the managed instance role.
+	ErrDenied = errors.New("activation denied")
+	// ErrThrottled reports that SSM throttled CreateActivation.
+	ErrThrottled = errors.New("activation throttled")
 )
 
+// CreateAPI is the SSM operation Service needs; *ssm.Client satisfies it.
+type CreateAPI interface {
+	CreateActivation(ctx context.Context, params *ssm.CreateActivationInput, optFns ...func(*ssm.Options)) (
+		*ssm.CreateActivationOutput, error)
+}
+
 // Service creates SSM activations for the wrapped agent.
 type Service struct {
-	client *ssm.Client
+	client   CreateAPI
+	settings config.Activation
 }
 
-// NewService returns a Service backed by the provided SSM client.
-func NewService(client *ssm.Client) Service {
-	return Service{client: client}
+// NewService returns a Service backed by the provided SSM client and activation settings.
+func NewService(client CreateAPI, settings config.Activation) Service {
+	return Service{client: client, settings: settings}
 }
 
 // Result captures the activation credentials issued by SSM.
@@ -38,32 +58,39 @@ type Result struct {
 
 // Create provisions an activation using the supplied execution context and IAM role.
 func (s Service) Create(ctx context.Context, roleName string, execCtx execution.Context) (Result, error) {
+	output, err := s.client.CreateActivation(ctx, s.BuildInput(roleName, execCtx))
+	metrics.ObserveAWSCall("CreateActivation", err)
+
+	if err != nil {
+		return Result{}, fmt.Errorf("create activation: %w", classify(err))
+	}
+
+	return Result{
+		ActivationID:   aws.ToString(output.ActivationId),
+		ActivationCode: aws.ToString(output.ActivationCode),
+	}, nil
+}
+
+// BuildInput assembles the CreateActivation request without sending it.
+func (s Service) BuildInput(roleName string, execCtx execution.Context) *ssm.CreateActivationInput {
 	var input ssm.CreateActivationInput
 
 	limit := int32(1)
 	input.IamRole = aws.String(roleName)
 	input.RegistrationLimit = aws.Int32(limit)
 
-	input.Description = aws.String(activationDescription(execCtx.TaskARN))
-	input.Tags = buildTags(execCtx)
+	input.Description = aws.String(s.activationDescription(execCtx.TaskARN))
+	input.Tags = s.buildTags(execCtx)
 
 	if execCtx.TaskARN != "" {
 		input.DefaultInstanceName = aws.String(execCtx.TaskARN)
 	}
 
-	output, err := s.client.CreateActivation(ctx, &input)
-	if err != nil {
-		return Result{}, fmt.Errorf("create activation: %w", err)
-	}
-
-	return Result{
-		ActivationID:   aws.ToString(output.ActivationId),
-		ActivationCode: aws.ToString(output.ActivationCode),
-	}, nil
+	return &input
 }
 
-func activationDescription(taskARN string) string {
-	if desc := strings.TrimSpace(env.GetString(internal.EnvActivationDescription)); desc != "" {
+func (s Service) activationDescription(taskARN string) string {
+	if desc := strings.TrimSpace(s.settings.Description); desc != "" {
 		return desc
 	}
 
@@ -74,7 +101,7 @@ func activationDescription(taskARN string) string {
 	return "SSM agent sidecar for " + taskARN
 }
 
-func buildTags(execCtx execution.Context) []types.Tag {
+func (s Service) buildTags(execCtx execution.Context) []types.Tag {
 	tags := make([]types.Tag, 0, defaultTagCapacity)
 	if execCtx.AvailabilityZone != "" {
 		tags = append(tags, makeTag("ECS_TASK_AVAILABILITY_ZONE", execCtx.AvailabilityZone))
@@ -86,42 +113,31 @@ func buildTags(execCtx execution.Context) []types.Tag {
 
 	tags = append(tags, makeTag(internal.FaultInjectionSidecarTagKey, internal.FaultInjectionSidecarTagValue))
 
-	return append(tags, parseExtraTags(env.GetString(internal.EnvAdditionalActivationTags))...)
-}
-
-func parseExtraTags(raw string) []types.Tag {
-	trimmed := strings.TrimSpace(raw)
-	if trimmed == "" {
-		return nil
+	for _, tag := range s.settings.ExtraTags {
+		tags = append(tags, makeTag(tag.Key, tag.Value))
 	}
 
-	segments := strings.Split(trimmed, extraTagDelimiter)
-	result := make([]types.Tag, 0, len(segments))
-
-	for _, segment := range segments {
-		segment = strings.TrimSpace(segment)
-		if segment == "" {
-			continue
-		}
-
-		parts := strings.SplitN(segment, "=", extraTagKeyValueParts)
-		if len(parts) != extraTagKeyValueParts {
-			continue
-		}
+	return tags
+}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+func makeTag(key, value string) types.Tag {
+	return types.Tag{Key: aws.String(key), Value: aws.String(value)}
+}
 
-		if key == "" {
-			continue
-		}
+// classify marks err with ErrDenied or ErrThrottled when its API error code says so.
+func classify(err error) error {
+	if awserr.IsAccessDenied(err) {
+		return fmt.Errorf("%w: %w", ErrDenied, err)
+	}
 
-		result = append(result, makeTag(key, value))
+	var apiErr interface{ ErrorCode() string }
+	if !errors.As(err, &apiErr) {
+		return err
 	}
 
-	return result
-}
+	if _, ok := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]; ok {
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	}
 
-func makeTag(key, value string) types.Tag {
-	return types.Tag{Key: aws.String(key), Value: aws.String(value)}
+	return err
 }
@@ -0,0 +1,183 @@
+package activation_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+const taskARN = "arn:aws:ecs:us-east-1:123456789012:task/cluster/abc"
+
+func tagMap(tags []types.Tag) map[string]string {
+	out := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		out[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return out
+}
+
+func TestBuildInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		settings    config.Activation
+		execCtx     execution.Context
+		description string
+		instance    string
+		tags        map[string]string
+	}{
+		{
+			name:        "no task metadata",
+			settings:    config.Activation{Description: "", ExtraTags: nil},
+			execCtx:     execution.Context{Region: "us-east-1", RegionSource: "", AvailabilityZone: "", TaskARN: ""},
+			description: "",
+			instance:    "",
+			tags:        map[string]string{internal.FaultInjectionSidecarTagKey: internal.FaultInjectionSidecarTagValue},
+		},
+		{
+			name:        "task metadata",
+			settings:    config.Activation{Description: "", ExtraTags: nil},
+			execCtx:     execution.Context{Region: "us-east-1", RegionSource: "", AvailabilityZone: "us-east-1a", TaskARN: taskARN},
+			description: "SSM agent sidecar for " + taskARN,
+			instance:    taskARN,
+			tags: map[string]string{
+				"ECS_TASK_AVAILABILITY_ZONE":         "us-east-1a",
+				"ECS_TASK_ARN":                       taskARN,
+				internal.FaultInjectionSidecarTagKey: internal.FaultInjectionSidecarTagValue,
+			},
+		},
+		{
+			name: "configured description and tags",
+			settings: config.Activation{
+				Description: "  debug shell  ",
+				ExtraTags:   []config.Tag{{Key: "team", Value: "infra"}},
+			},
+			execCtx:     execution.Context{Region: "us-east-1", RegionSource: "", AvailabilityZone: "", TaskARN: taskARN},
+			description: "debug shell",
+			instance:    taskARN,
+			tags: map[string]string{
+				"ECS_TASK_ARN":                       taskARN,
+				internal.FaultInjectionSidecarTagKey: internal.FaultInjectionSidecarTagValue,
+				"team":                               "infra",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			input := activation.NewService(nil, test.settings).BuildInput("role", test.execCtx)
+
+			if aws.ToString(input.IamRole) != "role" || aws.ToInt32(input.RegistrationLimit) != 1 {
+				t.Fatalf("unexpected role or limit: %+v", input)
+			}
+
+			if got := aws.ToString(input.Description); got != test.description {
+				t.Errorf("description = %q, want %q", got, test.description)
+			}
+
+			if got := aws.ToString(input.DefaultInstanceName); got != test.instance {
+				t.Errorf("default instance name = %q, want %q", got, test.instance)
+			}
+
+			got := tagMap(input.Tags)
+			if len(got) != len(test.tags) {
+				t.Fatalf("tags = %v, want %v", got, test.tags)
+			}
+
+			for key, value := range test.tags {
+				if got[key] != value {
+					t.Errorf("tag %s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	service := activation.NewService(server.Client(), config.Activation{Description: "", ExtraTags: nil})
+	execCtx := execution.Context{Region: ssmtest.Region, RegionSource: "", AvailabilityZone: "", TaskARN: taskARN}
+
+	result, err := service.Create(t.Context(), "role", execCtx)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	recorded, ok := server.Activation(result.ActivationID)
+	if !ok {
+		t.Fatalf("activation %s not found on the server", result.ActivationID)
+	}
+
+	if recorded.Code != result.ActivationCode || recorded.IamRole != "role" ||
+		recorded.DefaultInstanceName != taskARN || recorded.Tags["ECS_TASK_ARN"] != taskARN {
+		t.Fatalf("unexpected activation %+v for result %+v", recorded, result)
+	}
+}
+
+func TestCreateFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		code      string
+		denied    bool
+		throttled bool
+	}{
+		{code: ssmtest.CodeAccessDenied, denied: true, throttled: false},
+		{code: ssmtest.CodeThrottling, denied: false, throttled: true},
+		{code: ssmtest.CodeInternalServerError, denied: false, throttled: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.code, func(t *testing.T) {
+			t.Parallel()
+
+			server := ssmtest.NewServer()
+			defer server.Close()
+
+			server.Inject(ssmtest.OpCreateActivation, ssmtest.Fault{
+				Status:  0,
+				Code:    test.code,
+				Message: "injected",
+				Delay:   0,
+				Times:   0,
+			})
+
+			service := activation.NewService(server.Client(), config.Activation{Description: "", ExtraTags: nil})
+
+			_, err := service.Create(t.Context(), "role", execution.Context{})
+			if err == nil {
+				t.Fatal("Create succeeded despite the injected fault")
+			}
+
+			var apiErr smithy.APIError
+			if !errors.As(err, &apiErr) || apiErr.ErrorCode() != test.code {
+				t.Fatalf("error = %v, want %s", err, test.code)
+			}
+
+			if errors.Is(err, activation.ErrDenied) != test.denied ||
+				errors.Is(err, activation.ErrThrottled) != test.throttled {
+				t.Errorf("error = %v; want denied %t, throttled %t", err, test.denied, test.throttled)
+			}
+
+			if server.Activations() != 0 {
+				t.Fatal("activation was created despite the fault")
+			}
+		})
+	}
+}
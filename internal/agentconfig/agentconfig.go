@@ -0,0 +1,219 @@
+// Package agentconfig renders the amazon-ssm-agent configuration files from wrapper settings.
+package agentconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+)
+
+const (
+	// AppConfigFileName is the file the agent reads its AppConfig from.
+	AppConfigFileName = "amazon-ssm-agent.json"
+
+	// ShareFile is where the agent reads the on-prem instance credentials shared by registration.
+	ShareFile = "/var/lib/amazon/ssm/runtimeconfig/managed-instance"
+
+	filePerm = 0o644
+)
+
+// AppConfig is the subset of the agent's AppConfig the wrapper manages.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type AppConfig struct {
+	Profile  Profile  `json:"Profile"`
+	Agent    Agent    `json:"Agent"`
+	Identity Identity `json:"Identity"`
+	Mds      Mds      `json:"Mds"`
+	Ssm      Ssm      `json:"Ssm"`
+	Mgs      Mgs      `json:"Mgs"`
+}
+
+// Profile holds credential profile settings.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type Profile struct {
+	ShareCreds        bool   `json:"ShareCreds"`
+	ShareProfile      string `json:"ShareProfile"`
+	ForceUpdateCreds  bool   `json:"ForceUpdateCreds"`
+	KeyAutoRotateDays int    `json:"KeyAutoRotateDays"`
+}
+
+// Agent holds core agent settings.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type Agent struct {
+	Audit                 bool `json:"Audit"`
+	TelemetryMetricsToSSM bool `json:"TelemetryMetricsToSSM"`
+	ContainerMode         bool `json:"ContainerMode"`
+	ForceFileIPC          bool `json:"ForceFileIPC"`
+}
+
+// Identity selects the on-prem identity written by registration.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type Identity struct {
+	ConsumptionOrder []string       `json:"ConsumptionOrder"`
+	EC2              EC2Identity    `json:"EC2"`
+	OnPrem           OnPremIdentity `json:"OnPrem"`
+}
+
+// EC2Identity controls the EC2 identity provider.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type EC2Identity struct {
+	Disabled bool `json:"Disabled"`
+}
+
+// OnPremIdentity locates the on-prem registration credentials.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type OnPremIdentity struct {
+	RegistrationKey string `json:"RegistrationKey"`
+	ShareProfile    string `json:"ShareProfile"`
+	ShareFile       string `json:"ShareFile"`
+}
+
+// Mds holds Run Command settings.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type Mds struct {
+	Endpoint            string `json:"Endpoint"`
+	CommandRetryLimit   int    `json:"CommandRetryLimit"`
+	CommandWorkersLimit int    `json:"CommandWorkersLimit"`
+}
+
+// Ssm holds Session Manager and log retention settings.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type Ssm struct {
+	Endpoint                              string `json:"Endpoint"`
+	AssociationLogsRetentionDurationHours int    `json:"AssociationLogsRetentionDurationHours"`
+	RunCommandLogsRetentionDurationHours  int    `json:"RunCommandLogsRetentionDurationHours"`
+	SessionLogsRetentionDurationHours     int    `json:"SessionLogsRetentionDurationHours"`
+	SessionHandshakeTimeoutSeconds        int    `json:"SessionHandshakeTimeoutSeconds"`
+	SessionLogsDestination                string `json:"SessionLogsDestination"`
+	LocalSessionDirectory                 string `json:"LocalSessionDirectory"`
+}
+
+// Mgs holds Session Manager message gateway settings.
+//
+//nolint:tagliatelle // JSON keys must match the agent's AppConfig schema exactly.
+type Mgs struct {
+	Endpoint string `json:"Endpoint"`
+}
+
+// Build returns the container defaults with the operator's validated settings applied. Endpoint
+// overrides are shared with the wrapper so both talk to the same endpoints; see agentEndpoint.
+func Build(settings config.Agent, endpoints config.Endpoints) AppConfig {
+	return AppConfig{
+		Profile: Profile{ShareCreds: false, ShareProfile: "", ForceUpdateCreds: false, KeyAutoRotateDays: 0},
+		Agent:   Agent{Audit: false, TelemetryMetricsToSSM: false, ContainerMode: true, ForceFileIPC: true},
+		Identity: Identity{
+			ConsumptionOrder: []string{"OnPrem"},
+			EC2:              EC2Identity{Disabled: true},
+			OnPrem:           OnPremIdentity{RegistrationKey: "RegistrationKey", ShareProfile: "", ShareFile: ShareFile},
+		},
+		Mds: Mds{
+			Endpoint:            agentEndpoint(endpoints.EC2Messages),
+			CommandRetryLimit:   settings.CommandRetryLimit,
+			CommandWorkersLimit: settings.CommandWorkersLimit,
+		},
+		Ssm: Ssm{
+			Endpoint:                              agentEndpoint(endpoints.SSM),
+			AssociationLogsRetentionDurationHours: settings.AssociationLogsRetentionHours,
+			RunCommandLogsRetentionDurationHours:  settings.RunCommandLogsRetentionHours,
+			SessionLogsRetentionDurationHours:     settings.SessionLogsRetentionHours,
+			SessionHandshakeTimeoutSeconds:        int(settings.SessionHandshakeTimeout.Std().Seconds()),
+			SessionLogsDestination:                settings.SessionLogsDestination,
+			LocalSessionDirectory:                 "/tmp",
+		},
+		Mgs: Mgs{Endpoint: agentEndpoint(endpoints.SSMMessages)},
+	}
+}
+
+// agentEndpoint converts an endpoint URL to the host[:port] form the agent documents for its
+// Endpoint fields. The agent's SDK assumes https for a bare host and its Session Manager client
+// keeps only the host, so plain http URLs are left whole rather than silently switched to TLS.
+func agentEndpoint(endpoint string) string {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return endpoint
+	}
+
+	return parsed.Host
+}
+
+// Environ returns the environment the agent needs for settings its AppConfig cannot express:
+// the agent's SDK reads FIPS, dual-stack and CA bundle selection from these variables.
+func Environ(settings config.AWS) []string {
+	var env []string
+
+	if settings.UseFIPS {
+		env = append(env, internal.EnvUseFIPSEndpoint+"=true")
+	}
+
+	if settings.UseDualStack {
+		env = append(env, internal.EnvUseDualStackEndpoint+"=true")
+	}
+
+	if settings.CABundle != "" {
+		env = append(env, internal.EnvCABundle+"="+settings.CABundle)
+	}
+
+	return env
+}
+
+// WriteAppConfig renders cfg into dir/amazon-ssm-agent.json.
+func WriteAppConfig(dir string, cfg AppConfig) (string, error) {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal agent config: %w", err)
+	}
+
+	path := filepath.Join(dir, AppConfigFileName)
+
+	return path, writeFile(path, append(data, '\n'))
+}
+
+// writeFile replaces path atomically so the agent never reads a partial file.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+
+	_, writeErr := tmp.Write(data)
+	chmodErr := tmp.Chmod(filePerm)
+	closeErr := tmp.Close()
+
+	if writeErr != nil || chmodErr != nil || closeErr != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("write %s: %w", path, firstError(writeErr, chmodErr, closeErr))
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("replace %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
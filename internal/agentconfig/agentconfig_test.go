@@ -0,0 +1,97 @@
+package agentconfig_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestBuildOverrides(t *testing.T) {
+	t.Parallel()
+
+	settings := config.Default().Agent
+	settings.CommandWorkersLimit = 2
+	settings.CommandRetryLimit = 3
+	settings.SessionHandshakeTimeout = config.Duration(45 * time.Second)
+	settings.SessionLogsDestination = "disk"
+	settings.SessionLogsRetentionHours = 48
+	settings.RunCommandLogsRetentionHours = 72
+	settings.AssociationLogsRetentionHours = 96
+
+	got := agentconfig.Build(settings, config.Endpoints{
+		SSM:            "https://vpce-1234.ssm.eu-west-1.vpce.amazonaws.com",
+		STS:            "https://sts.eu-west-1.amazonaws.com",
+		SecretsManager: "",
+		SSMMessages:    "https://ssmmessages.eu-west-1.amazonaws.com:8443/",
+		EC2Messages:    "http://localhost:4566",
+	})
+
+	if got.Mds.CommandWorkersLimit != 2 || got.Mds.CommandRetryLimit != 3 {
+		t.Errorf("Mds = %+v, want the worker and retry limits applied", got.Mds)
+	}
+
+	if got.Ssm.SessionHandshakeTimeoutSeconds != 45 || got.Ssm.SessionLogsDestination != "disk" ||
+		got.Ssm.SessionLogsRetentionDurationHours != 48 || got.Ssm.RunCommandLogsRetentionDurationHours != 72 ||
+		got.Ssm.AssociationLogsRetentionDurationHours != 96 {
+		t.Errorf("Ssm = %+v, want the session and retention settings applied", got.Ssm)
+	}
+
+	endpoints := map[string][2]string{
+		"Ssm": {got.Ssm.Endpoint, "vpce-1234.ssm.eu-west-1.vpce.amazonaws.com"},
+		"Mgs": {got.Mgs.Endpoint, "ssmmessages.eu-west-1.amazonaws.com:8443"},
+		// A bare host would switch the agent to https, so plain http stays a URL.
+		"Mds": {got.Mds.Endpoint, "http://localhost:4566"},
+	}
+
+	for field, pair := range endpoints {
+		if pair[0] != pair[1] {
+			t.Errorf("%s.Endpoint = %q, want %q", field, pair[0], pair[1])
+		}
+	}
+
+	if got.Identity.OnPrem.ShareFile != agentconfig.ShareFile {
+		t.Errorf("ShareFile = %q, want %q", got.Identity.OnPrem.ShareFile, agentconfig.ShareFile)
+	}
+}
+
+// TestBuildDefaults pins the file rendered from the default settings, which replaced the one
+// baked into the image.
+func TestBuildDefaults(t *testing.T) {
+	t.Parallel()
+
+	defaults := config.Default()
+
+	path, err := agentconfig.WriteAppConfig(t.TempDir(), agentconfig.Build(defaults.Agent, defaults.AWS.Endpoints))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "amazon-ssm-agent.golden.json")
+
+	if *update {
+		if err := os.WriteFile(golden, got, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("rendered config differs from %s (rerun with -update to accept):\n%s", golden, got)
+	}
+}
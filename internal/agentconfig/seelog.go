@@ -0,0 +1,135 @@
+package agentconfig
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+)
+
+const (
+	// SeelogFileName is the agent's seelog configuration file.
+	SeelogFileName = "seelog.xml"
+
+	// SessionLoggerDir holds the seelog configuration read by ssm-session-logger.
+	SessionLoggerDir = "sessionlogger"
+
+	logDir          = "/var/log/amazon/ssm"
+	agentLogFile    = logDir + "/amazon-ssm-agent.log"
+	errorLogFile    = logDir + "/errors.log"
+	sessionLogFile  = logDir + "/ssm-session-logger.log"
+	logMaxSize      = "30000000"
+	errorLogMaxSize = "10000000"
+	logMaxRolls     = "5"
+	dirPerm         = 0o755
+
+	// textFormat matches the layout parsed by the agentlog package. Seelog cannot escape %Msg, so
+	// structured output comes from the wrapper re-emitting parsed lines, not from seelog.
+	textFormat = "%Date %Time [%LEV] %Msg%n"
+)
+
+// seelogDocument is the seelog XML configuration.
+type seelogDocument struct {
+	XMLName  xml.Name      `xml:"seelog"`
+	MinLevel string        `xml:"minlevel,attr"`
+	Outputs  seelogOutputs `xml:"outputs"`
+	Formats  []seelogFmt   `xml:"formats>format"`
+}
+
+type seelogOutputs struct {
+	FormatID    string         `xml:"formatid,attr"`
+	Console     *struct{}      `xml:"console,omitempty"`
+	RollingFile *seelogRolling `xml:"rollingfile,omitempty"`
+	Filter      *seelogFilter  `xml:"filter,omitempty"`
+}
+
+type seelogRolling struct {
+	Type     string `xml:"type,attr"`
+	Filename string `xml:"filename,attr"`
+	MaxSize  string `xml:"maxsize,attr"`
+	MaxRolls string `xml:"maxrolls,attr"`
+}
+
+type seelogFilter struct {
+	Levels      string        `xml:"levels,attr"`
+	RollingFile seelogRolling `xml:"rollingfile"`
+}
+
+type seelogFmt struct {
+	ID     string `xml:"id,attr"`
+	Format string `xml:"format,attr"`
+}
+
+// RenderSeelog returns a seelog configuration for settings that writes files to logFile.
+func RenderSeelog(settings config.Agent, logFile string) ([]byte, error) {
+	doc := seelogDocument{
+		XMLName:  xml.Name{Space: "", Local: "seelog"},
+		MinLevel: settings.LogLevel,
+		Outputs:  seelogOutputs{FormatID: "fmt", Console: nil, RollingFile: nil, Filter: nil},
+		Formats:  []seelogFmt{{ID: "fmt", Format: textFormat}},
+	}
+
+	if settings.LogOutput != config.AgentLogOutputFile {
+		doc.Outputs.Console = &struct{}{}
+	}
+
+	if settings.LogOutput != config.AgentLogOutputStdout {
+		doc.Outputs.RollingFile = &seelogRolling{Type: "size", Filename: logFile, MaxSize: logMaxSize, MaxRolls: logMaxRolls}
+		doc.Outputs.Filter = &seelogFilter{
+			Levels:      "error,critical",
+			RollingFile: seelogRolling{Type: "size", Filename: errorLogFile, MaxSize: errorLogMaxSize, MaxRolls: logMaxRolls},
+		}
+	}
+
+	var buf bytes.Buffer
+
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+
+	err := encoder.Encode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encode seelog config: %w", err)
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// WriteSeelog renders dir/seelog.xml for the agent and dir/sessionlogger/seelog.xml for the
+// session logger. The agent watches its file and reloads it without a restart.
+func WriteSeelog(dir string, settings config.Agent) ([]string, error) {
+	targets := []struct {
+		path    string
+		logFile string
+	}{
+		{filepath.Join(dir, SeelogFileName), agentLogFile},
+		{filepath.Join(dir, SessionLoggerDir, SeelogFileName), sessionLogFile},
+	}
+
+	paths := make([]string, 0, len(targets))
+
+	for _, target := range targets {
+		data, err := RenderSeelog(settings, target.logFile)
+		if err != nil {
+			return paths, err
+		}
+
+		err = os.MkdirAll(filepath.Dir(target.path), dirPerm)
+		if err != nil {
+			return paths, fmt.Errorf("create %s: %w", filepath.Dir(target.path), err)
+		}
+
+		err = writeFile(target.path, data)
+		if err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, target.path)
+	}
+
+	return paths, nil
+}
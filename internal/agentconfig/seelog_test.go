@@ -0,0 +1,137 @@
+package agentconfig_test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+)
+
+// seelog mirrors the parts of the rendered document the tests inspect.
+type seelog struct {
+	MinLevel string `xml:"minlevel,attr"`
+	Outputs  struct {
+		Console     *struct{} `xml:"console"`
+		RollingFile *struct {
+			Filename string `xml:"filename,attr"`
+		} `xml:"rollingfile"`
+		Filter *struct {
+			Levels string `xml:"levels,attr"`
+		} `xml:"filter"`
+	} `xml:"outputs"`
+	Formats []struct {
+		Format string `xml:"format,attr"`
+	} `xml:"formats>format"`
+}
+
+func TestRenderSeelog(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		output  string
+		console bool
+		file    bool
+	}{
+		{
+			name:    "stdout",
+			output:  config.AgentLogOutputStdout,
+			console: true,
+			file:    false,
+		},
+		{
+			name:    "file",
+			output:  config.AgentLogOutputFile,
+			console: false,
+			file:    true,
+		},
+		{
+			name:    "both",
+			output:  config.AgentLogOutputBoth,
+			console: true,
+			file:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			settings := config.Default().Agent
+			settings.LogLevel = "debug"
+			settings.LogOutput = tt.output
+
+			data, err := agentconfig.RenderSeelog(settings, "/var/log/amazon/ssm/agent.log")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var doc seelog
+			if err := xml.Unmarshal(data, &doc); err != nil {
+				t.Fatalf("rendered config is not valid XML: %v\n%s", err, data)
+			}
+
+			if doc.MinLevel != "debug" {
+				t.Errorf("minlevel = %q, want debug", doc.MinLevel)
+			}
+
+			if len(doc.Formats) != 1 || doc.Formats[0].Format != "%Date %Time [%LEV] %Msg%n" {
+				t.Errorf("formats = %+v, want the layout the agentlog parser reads", doc.Formats)
+			}
+
+			if (doc.Outputs.Console != nil) != tt.console {
+				t.Errorf("console output = %t, want %t", doc.Outputs.Console != nil, tt.console)
+			}
+
+			if (doc.Outputs.RollingFile != nil) != tt.file || (doc.Outputs.Filter != nil) != tt.file {
+				t.Fatalf("file outputs = %+v, want present %t", doc.Outputs, tt.file)
+			}
+
+			if tt.file && doc.Outputs.RollingFile.Filename != "/var/log/amazon/ssm/agent.log" {
+				t.Errorf("rolling file = %q", doc.Outputs.RollingFile.Filename)
+			}
+		})
+	}
+}
+
+func TestWriteSeelog(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	settings := config.Default().Agent
+	settings.LogOutput = config.AgentLogOutputFile
+
+	paths, err := agentconfig.WriteSeelog(dir, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		filepath.Join(dir, "seelog.xml"):                  "/var/log/amazon/ssm/amazon-ssm-agent.log",
+		filepath.Join(dir, "sessionlogger", "seelog.xml"): "/var/log/amazon/ssm/ssm-session-logger.log",
+	}
+
+	if len(paths) != len(want) {
+		t.Fatalf("paths = %v, want %d files", paths, len(want))
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var doc seelog
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		if doc.Outputs.RollingFile == nil || doc.Outputs.RollingFile.Filename != want[path] {
+			t.Errorf("%s logs to %+v, want %s", path, doc.Outputs.RollingFile, want[path])
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package agentlog parses amazon-ssm-agent seelog output and re-emits it as structured events.
+package agentlog
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Line is a parsed amazon-ssm-agent log line.
+type Line struct {
+	Timestamp string
+	Level     slog.Level
+	Component string
+	Message   string
+	Parsed    bool
+}
+
+// seelogPattern matches both the image's "[LEV]" format and the agent's default "LEVEL" format.
+var seelogPattern = regexp.MustCompile(
+	`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) (?:\[([A-Za-z]+)\]|([A-Z]+)) ?(.*)$`,
+)
+
+var componentPattern = regexp.MustCompile(`^\[([^\]\s]+)\]\s*`)
+
+const (
+	seelogTimestampGroup = 1
+	seelogBracketGroup   = 2
+	seelogPlainGroup     = 3
+	seelogMessageGroup   = 4
+	componentNameGroup   = 1
+	componentSeparator   = "/"
+)
+
+// levelNames maps seelog short and long level names to slog levels.
+var levelNames = map[string]slog.Level{
+	"TRC":      slog.LevelDebug,
+	"TRACE":    slog.LevelDebug,
+	"DBG":      slog.LevelDebug,
+	"DEBUG":    slog.LevelDebug,
+	"INF":      slog.LevelInfo,
+	"INFO":     slog.LevelInfo,
+	"WRN":      slog.LevelWarn,
+	"WARN":     slog.LevelWarn,
+	"ERR":      slog.LevelError,
+	"ERROR":    slog.LevelError,
+	"CRT":      slog.LevelError,
+	"CRITICAL": slog.LevelError,
+}
+
+// Parse splits a seelog line into timestamp, level, component and message.
+// Lines that do not match the seelog layout are returned unparsed at info level.
+func Parse(raw string) Line {
+	text := strings.TrimRight(raw, "\r\n")
+
+	match := seelogPattern.FindStringSubmatch(text)
+	if match == nil {
+		return unparsed(text)
+	}
+
+	levelName := match[seelogBracketGroup]
+	if levelName == "" {
+		levelName = match[seelogPlainGroup]
+	}
+
+	return newLine(text, match[seelogTimestampGroup], levelName, match[seelogMessageGroup])
+}
+
+func newLine(text, timestamp, levelName, body string) Line {
+	level, ok := levelNames[strings.ToUpper(levelName)]
+	if !ok {
+		return unparsed(text)
+	}
+
+	component, message := splitComponents(body)
+
+	return Line{
+		Timestamp: timestamp,
+		Level:     level,
+		Component: component,
+		Message:   message,
+		Parsed:    true,
+	}
+}
+
+func unparsed(text string) Line {
+	return Line{Timestamp: "", Level: slog.LevelInfo, Component: "", Message: text, Parsed: false}
+}
+
+// splitComponents strips leading "[Component]" tokens and joins them into a path.
+func splitComponents(message string) (string, string) {
+	var components []string
+
+	for {
+		match := componentPattern.FindStringSubmatch(message)
+		if match == nil {
+			break
+		}
+
+		components = append(components, match[componentNameGroup])
+		message = message[len(match[0]):]
+	}
+
+	return strings.Join(components, componentSeparator), message
+}
@@ -0,0 +1,67 @@
+package agentlog_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentlog"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		raw  string
+		want agentlog.Line
+	}{
+		{
+			name: "image format",
+			raw:  "2026-10-18 12:00:00.123 [INF] [ssm-agent-worker] [MessageService] started\n",
+			want: agentlog.Line{
+				Timestamp: "2026-10-18 12:00:00.123",
+				Level:     slog.LevelInfo,
+				Component: "ssm-agent-worker/MessageService",
+				Message:   "started",
+				Parsed:    true,
+			},
+		},
+		{
+			name: "agent default format",
+			raw:  "2026-10-18 12:00:00 ERROR [CredentialRefresher] Retrieve credentials produced error: boom",
+			want: agentlog.Line{
+				Timestamp: "2026-10-18 12:00:00",
+				Level:     slog.LevelError,
+				Component: "CredentialRefresher",
+				Message:   "Retrieve credentials produced error: boom",
+				Parsed:    true,
+			},
+		},
+		{
+			name: "unknown level",
+			raw:  "2026-10-18 12:00:00 [XYZ] hello",
+			want: agentlog.Line{
+				Timestamp: "",
+				Level:     slog.LevelInfo,
+				Component: "",
+				Message:   "2026-10-18 12:00:00 [XYZ] hello",
+				Parsed:    false,
+			},
+		},
+		{
+			name: "plain output",
+			raw:  "Starting Agent\r\n",
+			want: agentlog.Line{Timestamp: "", Level: slog.LevelInfo, Component: "", Message: "Starting Agent", Parsed: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := agentlog.Parse(tt.raw); got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
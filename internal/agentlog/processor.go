@@ -0,0 +1,193 @@
+package agentlog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"sync"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/supervisor"
+)
+
+// Health signal kinds detected in agent output.
+const (
+	KindCredentialRefresh   = "credential_refresh_failed"
+	KindRegistrationInvalid = "registration_invalid"
+	KindMGSConnectionLost   = "mgs_connection_lost"
+
+	// The agent retries each of these with backoff, so a single failure is routine.
+	credentialRefreshThreshold   = 5
+	registrationInvalidThreshold = 2
+	mgsConnectionLossThreshold   = 3
+)
+
+// pattern describes agent output that indicates the agent can no longer serve sessions. A line
+// counts when it is logged at level or above and matches; a reset line zeroes the count.
+type pattern struct {
+	kind      string
+	level     slog.Level
+	match     *regexp.Regexp
+	reset     *regexp.Regexp
+	threshold int
+}
+
+// credentialsReady is logged by the agent's credential refresher after every successful refresh.
+var credentialsReady = regexp.MustCompile(`^Credentials ready`)
+
+// defaultPatterns lists the fatal conditions amazon-ssm-agent reports, anchored to the messages
+// its credential refresher and control channel log. Each is only reported after repeated failures
+// without an intervening recovery.
+var defaultPatterns = []pattern{
+	{
+		kind:      KindCredentialRefresh,
+		level:     slog.LevelError,
+		match:     regexp.MustCompile(`^Retrieve credentials produced error`),
+		reset:     credentialsReady,
+		threshold: credentialRefreshThreshold,
+	},
+	{
+		kind:      KindRegistrationInvalid,
+		level:     slog.LevelError,
+		match:     regexp.MustCompile(`^Retrieve credentials produced error:.*\b(InvalidInstanceId|MachineFingerprintDoesNotMatch)\b`),
+		reset:     credentialsReady,
+		threshold: registrationInvalidThreshold,
+	},
+	{
+		kind:  KindMGSConnectionLost,
+		level: slog.LevelError,
+		match: regexp.MustCompile(
+			`^(failed to reconnect to the control channel|failed to initialize websocket channel for controlchannel)`),
+		reset:     regexp.MustCompile(`^Successfully opened websocket connection`),
+		threshold: mgsConnectionLossThreshold,
+	},
+}
+
+// Processor parses agent output streams, re-emits them through slog and reports health signals.
+type Processor struct {
+	mu sync.Mutex
+
+	logger   *slog.Logger
+	signals  chan<- supervisor.HealthSignal
+	patterns []pattern
+	counts   map[string]int
+}
+
+// NewProcessor returns a Processor that sends detected health signals on signals, which may be nil.
+func NewProcessor(signals chan<- supervisor.HealthSignal) *Processor {
+	return &Processor{
+		mu:       sync.Mutex{},
+		logger:   slog.Default().With(slog.String("source", "amazon-ssm-agent")),
+		signals:  signals,
+		patterns: defaultPatterns,
+		counts:   make(map[string]int),
+	}
+}
+
+// Writer returns an io.Writer that processes the named output stream line by line.
+// Call Flush on the returned writer once the child has exited to emit any trailing partial line.
+func (p *Processor) Writer(stream string) *LineWriter {
+	return &LineWriter{mu: sync.Mutex{}, buf: nil, emit: func(text string) { p.process(stream, text) }}
+}
+
+func (p *Processor) process(stream, text string) {
+	line := Parse(text)
+	if line.Message == "" && !line.Parsed {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("stream", stream)}
+	if line.Component != "" {
+		attrs = append(attrs, slog.String("component", line.Component))
+	}
+
+	if line.Timestamp != "" {
+		attrs = append(attrs, slog.String("agentTime", line.Timestamp))
+	}
+
+	p.logger.LogAttrs(context.Background(), line.Level, line.Message, attrs...)
+	p.detect(line)
+}
+
+func (p *Processor) detect(line Line) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pat := range p.patterns {
+		if pat.reset != nil && pat.reset.MatchString(line.Message) {
+			p.counts[pat.kind] = 0
+
+			continue
+		}
+
+		if line.Level < pat.level || !pat.match.MatchString(line.Message) {
+			continue
+		}
+
+		p.counts[pat.kind]++
+		if p.counts[pat.kind] < pat.threshold {
+			continue
+		}
+
+		p.counts[pat.kind] = 0
+		p.send(supervisor.HealthSignal{Kind: pat.kind, Message: line.Message})
+	}
+}
+
+// send delivers a signal without blocking agent output if the supervisor is not reading.
+func (p *Processor) send(signal supervisor.HealthSignal) {
+	slog.Warn("detected agent health signal", slog.String("signal", signal.Kind))
+
+	if p.signals == nil {
+		return
+	}
+
+	select {
+	case p.signals <- signal:
+	default:
+		slog.Warn("dropping agent health signal; supervisor busy", slog.String("signal", signal.Kind))
+	}
+}
+
+// LineWriter splits written bytes into lines and hands each complete line to a callback.
+type LineWriter struct {
+	mu   sync.Mutex
+	buf  []byte
+	emit func(string)
+}
+
+var _ io.Writer = (*LineWriter)(nil)
+
+// Write buffers data and emits every complete line.
+func (w *LineWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, data...)
+
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+
+		w.emit(string(w.buf[:idx]))
+		w.buf = w.buf[idx+1:]
+	}
+
+	return len(data), nil
+}
+
+// Flush emits any buffered partial line.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) == 0 {
+		return
+	}
+
+	w.emit(string(w.buf))
+	w.buf = nil
+}
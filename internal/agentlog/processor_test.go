@@ -0,0 +1,103 @@
+package agentlog_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentlog"
+	"github.com/benwsapp/aws-ssm-minimal/internal/supervisor"
+)
+
+const (
+	retrieveFailed  = "2026-10-18 12:00:00 ERROR [CredentialRefresher] Retrieve credentials produced error: timeout"
+	instanceInvalid = "2026-10-18 12:00:00 ERROR [CredentialRefresher] Retrieve credentials produced error: " +
+		"InvalidInstanceId: instance mi-0123 is not registered"
+	credentialsReady = "2026-10-18 12:00:00 INFO [CredentialRefresher] Credentials ready"
+	reconnectFailed  = "2026-10-18 12:00:00 ERROR [MessageGatewayService] failed to reconnect to the control channel with error: eof"
+	websocketOpened  = "2026-10-18 12:00:00 INFO [MessageGatewayService] Successfully opened websocket connection to: 1.2.3.4:443"
+)
+
+// detected feeds lines to a processor and returns the kinds of the health signals it sent.
+func detected(t *testing.T, lines ...string) []string {
+	t.Helper()
+
+	signals := make(chan supervisor.HealthSignal, len(lines)*2)
+	writer := agentlog.NewProcessor(signals).Writer("stdout")
+
+	_, err := writer.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(signals)
+
+	var kinds []string
+	for signal := range signals {
+		kinds = append(kinds, signal.Kind)
+	}
+
+	return kinds
+}
+
+func TestProcessorHealthSignals(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name:  "single credential failure is retried",
+			lines: []string{retrieveFailed},
+			want:  nil,
+		},
+		{
+			name:  "repeated credential failures",
+			lines: slices.Repeat([]string{retrieveFailed}, 5),
+			want:  []string{agentlog.KindCredentialRefresh},
+		},
+		{
+			name: "credential failures reset by a refresh",
+			lines: slices.Concat(slices.Repeat([]string{retrieveFailed}, 4), []string{credentialsReady},
+				slices.Repeat([]string{retrieveFailed}, 4)),
+			want: nil,
+		},
+		{
+			name:  "invalid instance",
+			lines: []string{instanceInvalid, instanceInvalid},
+			want:  []string{agentlog.KindRegistrationInvalid},
+		},
+		{
+			name:  "deregistration mentioned in passing",
+			lines: slices.Repeat([]string{"2026-10-18 12:00:00 INFO [Agent] instance will be deregistered on shutdown"}, 3),
+			want:  nil,
+		},
+		{
+			name:  "error text below error level",
+			lines: slices.Repeat([]string{"2026-10-18 12:00:00 DEBUG Retrieve credentials produced error: timeout"}, 5),
+			want:  nil,
+		},
+		{
+			name:  "control channel lost",
+			lines: []string{reconnectFailed, reconnectFailed, reconnectFailed},
+			want:  []string{agentlog.KindMGSConnectionLost},
+		},
+		{
+			name:  "control channel reconnected",
+			lines: []string{reconnectFailed, reconnectFailed, websocketOpened, reconnectFailed, reconnectFailed},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := detected(t, tt.lines...); !slices.Equal(got, tt.want) {
+				t.Errorf("signals = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,19 +2,128 @@
 package awsconfig
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
 )
 
+// Load resolves the shared AWS configuration for the specified region with the FIPS, dual-stack
+// and CA bundle settings applied, sending requests through px. Endpoint overrides are per service;
+// see the *Client helpers. px must not be nil.
+func Load(ctx context.Context, region string, settings config.AWS, px *proxy.Proxy) (aws.Config, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(region),
+		awsconfig.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+			t.Proxy = px.Func
+		})),
+	}
+
+	if settings.UseFIPS {
+		opts = append(opts, awsconfig.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+
+	if settings.UseDualStack {
+		opts = append(opts, awsconfig.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+
+	if settings.CABundle != "" {
+		bundle, err := os.ReadFile(settings.CABundle)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("read CA bundle: %w", err)
+		}
+
+		opts = append(opts, awsconfig.WithCustomCABundle(bytes.NewReader(bundle)))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("load AWS config: %w", err)
+	}
+
+	return cfg, nil
+}
+
 // NewSSMClient builds an SSM client for the specified region.
-func NewSSMClient(ctx context.Context, region string) (*ssm.Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+func NewSSMClient(ctx context.Context, region string, settings config.AWS, px *proxy.Proxy) (*ssm.Client, error) {
+	cfg, err := Load(ctx, region, settings, px)
 	if err != nil {
-		return nil, fmt.Errorf("load AWS config: %w", err)
+		return nil, err
 	}
 
-	return ssm.NewFromConfig(cfg), nil
+	return SSMClient(cfg, settings), nil
+}
+
+// WarmSSMEndpoint opens a connection to the SSM endpoint for cfg's region through cfg's HTTP
+// client, so that an SSM client built from cfg can reuse it instead of paying for DNS, TCP and TLS
+// on its first call. The response itself is discarded.
+func WarmSSMEndpoint(ctx context.Context, cfg aws.Config, settings config.AWS) error {
+	params := ssm.EndpointParameters{
+		Region:       aws.String(cfg.Region),
+		UseFIPS:      aws.Bool(settings.UseFIPS),
+		UseDualStack: aws.Bool(settings.UseDualStack),
+		Endpoint:     nil,
+	}
+	setBaseEndpoint(&params.Endpoint, settings.Endpoints.SSM)
+
+	endpoint, err := ssm.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
+	if err != nil {
+		return fmt.Errorf("resolve SSM endpoint: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint.URI.String(), nil)
+	if err != nil {
+		return fmt.Errorf("build warmup request: %w", err)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", endpoint.URI.Host, err)
+	}
+
+	// Drain the body so the connection goes back to the pool.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+// SSMClient builds an SSM client from cfg, honoring the SSM endpoint override.
+func SSMClient(cfg aws.Config, settings config.AWS) *ssm.Client {
+	return ssm.NewFromConfig(cfg, func(o *ssm.Options) {
+		setBaseEndpoint(&o.BaseEndpoint, settings.Endpoints.SSM)
+	})
+}
+
+// STSClient builds an STS client from cfg, honoring the STS endpoint override.
+func STSClient(cfg aws.Config, settings config.AWS) *sts.Client {
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		setBaseEndpoint(&o.BaseEndpoint, settings.Endpoints.STS)
+	})
+}
+
+// SecretsManagerClient builds a Secrets Manager client from cfg, honoring its endpoint override.
+func SecretsManagerClient(cfg aws.Config, settings config.AWS) *secretsmanager.Client {
+	return secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+		setBaseEndpoint(&o.BaseEndpoint, settings.Endpoints.SecretsManager)
+	})
+}
+
+// setBaseEndpoint overrides target only when url is set, so endpoints the SDK resolved from its
+// own configuration are kept otherwise.
+func setBaseEndpoint(target **string, url string) {
+	if url != "" {
+		*target = aws.String(url)
+	}
 }
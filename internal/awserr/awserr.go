@@ -0,0 +1,23 @@
+// Package awserr classifies errors returned by AWS API calls.
+package awserr
+
+import "errors"
+
+// deniedCodes are the API error codes AWS uses for authorization failures.
+var deniedCodes = map[string]struct{}{
+	"AccessDeniedException": {},
+	"AccessDenied":          {},
+	"UnauthorizedOperation": {},
+}
+
+// IsAccessDenied reports whether err wraps an AWS API error refused for lack of permission.
+func IsAccessDenied(err error) bool {
+	var apiErr interface{ ErrorCode() string }
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	_, ok := deniedCodes[apiErr.ErrorCode()]
+
+	return ok
+}
@@ -0,0 +1,42 @@
+package awserr_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/benwsapp/aws-ssm-minimal/internal/awserr"
+)
+
+func TestIsAccessDenied(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "access denied exception", err: apiError("AccessDeniedException"), want: true},
+		{name: "access denied", err: apiError("AccessDenied"), want: true},
+		{name: "unauthorized operation", err: apiError("UnauthorizedOperation"), want: true},
+		{name: "wrapped", err: fmt.Errorf("create activation: %w", apiError("AccessDeniedException")), want: true},
+		{name: "throttled", err: apiError("ThrottlingException"), want: false},
+		{name: "not an API error", err: errors.New("connection refused"), want: false},
+		{name: "nil", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := awserr.IsAccessDenied(tt.err); got != tt.want {
+				t.Errorf("IsAccessDenied(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func apiError(code string) error {
+	return &smithy.GenericAPIError{Code: code, Message: "test", Fault: smithy.FaultClient}
+}
@@ -0,0 +1,190 @@
+// Package clocktest provides a fake clock.Clock whose time only moves when a test advances it.
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+)
+
+// Fake is a clock.Clock that starts at a fixed time and fires timers only from Advance. The zero
+// value is not usable; call NewFake.
+type Fake struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a pending timer or ticker.
+type waiter struct {
+	clock  *Fake
+	when   time.Time
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+// NewFake returns a Fake reading now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{mu: sync.Mutex{}, changed: nil, now: now, waiters: nil}
+	f.changed = sync.NewCond(&f.mu)
+
+	return f
+}
+
+var _ clock.Clock = (*Fake)(nil)
+
+// Now returns the fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// NewTimer returns a timer that fires once Advance reaches d from now.
+func (f *Fake) NewTimer(d time.Duration) clock.Timer {
+	return f.add(d, 0, make(chan time.Time, 1), nil)
+}
+
+// AfterFunc returns a timer that calls fn from Advance once it reaches d from now.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) clock.Timer {
+	return f.add(d, 0, nil, fn)
+}
+
+// NewTicker returns a ticker that fires every d of advanced time. Like time.Ticker it drops
+// ticks a slow receiver misses.
+func (f *Fake) NewTicker(d time.Duration) clock.Ticker {
+	return ticker{waiter: f.add(d, d, make(chan time.Time, 1), nil)}
+}
+
+// Advance moves the time forward by d, firing due timers in order. AfterFunc callbacks run on the
+// caller's goroutine before Advance returns.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	target := f.now.Add(d)
+
+	for {
+		next := f.nextDue(target)
+		if next == nil {
+			break
+		}
+
+		f.now = next.when
+		f.remove(next)
+
+		if next.period > 0 {
+			next.when = next.when.Add(next.period)
+			f.waiters = append(f.waiters, next)
+		}
+
+		f.changed.Broadcast()
+
+		if next.fn != nil {
+			f.mu.Unlock()
+			next.fn()
+			f.mu.Lock()
+
+			continue
+		}
+
+		select {
+		case next.ch <- f.now:
+		default:
+		}
+	}
+
+	f.now = target
+	f.mu.Unlock()
+}
+
+// Timers reports how many timers and tickers are pending.
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.waiters)
+}
+
+// BlockUntil waits until exactly n timers and tickers are pending, so a test can advance time
+// only once the code under test has armed what it is expected to.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.waiters) != n {
+		f.changed.Wait()
+	}
+}
+
+func (f *Fake) add(d, period time.Duration, ch chan time.Time, fn func()) *waiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &waiter{clock: f, when: f.now.Add(d), period: period, ch: ch, fn: fn}
+	f.waiters = append(f.waiters, w)
+	f.changed.Broadcast()
+
+	return w
+}
+
+// nextDue returns the earliest waiter due at or before target.
+func (f *Fake) nextDue(target time.Time) *waiter {
+	var next *waiter
+
+	for _, w := range f.waiters {
+		if !w.when.After(target) && (next == nil || w.when.Before(next.when)) {
+			next = w
+		}
+	}
+
+	return next
+}
+
+// remove drops w from the pending list, reporting whether it was pending.
+func (f *Fake) remove(w *waiter) bool {
+	for i, pending := range f.waiters {
+		if pending == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.changed.Broadcast()
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *waiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *waiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	return w.clock.remove(w)
+}
+
+// ticker adapts a periodic waiter to clock.Ticker.
+type ticker struct {
+	*waiter
+}
+
+func (t ticker) Stop() {
+	t.waiter.Stop()
+}
+
+func (w *waiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	active := w.clock.remove(w)
+	w.when = w.clock.now.Add(d)
+	w.clock.waiters = append(w.clock.waiters, w)
+	w.clock.changed.Broadcast()
+
+	return active
+}
@@ -0,0 +1,96 @@
+package clocktest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clocktest"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case at := <-c:
+		return at, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestTimer(t *testing.T) {
+	t.Parallel()
+
+	clock := clocktest.NewFake(start)
+	timer := clock.NewTimer(time.Minute)
+
+	clock.Advance(59 * time.Second)
+
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("timer fired early")
+	}
+
+	clock.Advance(2 * time.Second)
+
+	at, ok := fired(timer.C())
+	if !ok || !at.Equal(start.Add(time.Minute)) {
+		t.Fatalf("timer fired at %v (%t), want %v", at, ok, start.Add(time.Minute))
+	}
+
+	if !clock.Now().Equal(start.Add(61*time.Second)) || clock.Timers() != 0 {
+		t.Fatalf("now = %v with %d timers pending", clock.Now(), clock.Timers())
+	}
+
+	if timer.Reset(time.Second) {
+		t.Fatal("Reset reported a fired timer as active")
+	}
+
+	if !timer.Stop() || timer.Stop() {
+		t.Fatal("Stop should report the reset timer active exactly once")
+	}
+}
+
+func TestAfterFuncOrder(t *testing.T) {
+	t.Parallel()
+
+	clock := clocktest.NewFake(start)
+
+	var order []int
+
+	clock.AfterFunc(2*time.Second, func() { order = append(order, 2) })
+	clock.AfterFunc(time.Second, func() {
+		order = append(order, 1)
+		// Timers armed by a callback fire in the same Advance when due.
+		clock.AfterFunc(time.Second, func() { order = append(order, 3) })
+	})
+
+	clock.Advance(3 * time.Second)
+
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 3 {
+		t.Fatalf("callbacks ran in order %v", order)
+	}
+}
+
+func TestTickerDropsMissedTicks(t *testing.T) {
+	t.Parallel()
+
+	clock := clocktest.NewFake(start)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(5 * time.Second)
+
+	if at, ok := fired(ticker.C()); !ok || !at.Equal(start.Add(time.Second)) {
+		t.Fatalf("first tick at %v (%t)", at, ok)
+	}
+
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("ticker buffered more than one tick")
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+
+	if _, ok := fired(ticker.C()); ok || clock.Timers() != 0 {
+		t.Fatal("stopped ticker kept firing")
+	}
+}
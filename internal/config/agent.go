@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Session log destinations accepted by the agent.
+const (
+	SessionLogsNone = "none"
+	SessionLogsDisk = "disk"
+)
+
+// Defaults and bounds from the agent's appconfig package; the agent silently falls back to its
+// defaults for out-of-range values, so they are rejected here instead.
+const (
+	defaultCommandWorkersLimit           = 5
+	minCommandWorkersLimit               = 1
+	defaultCommandRetryLimit             = 15
+	minCommandRetryLimit                 = 1
+	maxCommandRetryLimit                 = 100
+	defaultSessionHandshakeSeconds       = 15
+	minSessionHandshakeTimeout           = 1 * time.Second
+	maxSessionHandshakeTimeout           = 60 * time.Second
+	defaultSessionLogsRetentionHours     = 336
+	defaultRunCommandLogsRetentionHours  = 336
+	defaultAssociationLogsRetentionHours = 24
+	minLogsRetentionHours                = 8
+)
+
+// Agent seelog settings.
+const (
+	AgentLogLevelInfo    = "info"
+	AgentLogOutputStdout = "stdout"
+	AgentLogOutputFile   = "file"
+	AgentLogOutputBoth   = "both"
+)
+
+var agentLogLevels = []string{"trace", "debug", AgentLogLevelInfo, "warn", "error", "critical", "off"}
+
+var errInvalidAgentSetting = errors.New("invalid agent setting")
+
+func (c *Config) validateAgent() []error {
+	agent := c.Agent
+
+	var errs []error
+
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{errInvalidAgentSetting}, args...)...))
+	}
+
+	if !filepath.IsAbs(agent.ConfigDir) {
+		invalid("configDir %q must be an absolute path", agent.ConfigDir)
+	}
+
+	if agent.CommandWorkersLimit < minCommandWorkersLimit {
+		invalid("commandWorkersLimit %d below %d", agent.CommandWorkersLimit, minCommandWorkersLimit)
+	}
+
+	if agent.CommandRetryLimit < minCommandRetryLimit || agent.CommandRetryLimit > maxCommandRetryLimit {
+		invalid("commandRetryLimit %d outside %d-%d", agent.CommandRetryLimit, minCommandRetryLimit, maxCommandRetryLimit)
+	}
+
+	timeout := agent.SessionHandshakeTimeout.Std()
+	if timeout < minSessionHandshakeTimeout || timeout > maxSessionHandshakeTimeout {
+		invalid("sessionHandshakeTimeout %s outside %s-%s", timeout, minSessionHandshakeTimeout, maxSessionHandshakeTimeout)
+	}
+
+	switch agent.SessionLogsDestination {
+	case SessionLogsNone, SessionLogsDisk:
+	default:
+		invalid("sessionLogsDestination %q: want %s or %s", agent.SessionLogsDestination, SessionLogsNone, SessionLogsDisk)
+	}
+
+	errs = append(errs, ValidateAgentLogging(agent))
+
+	for _, retention := range []struct {
+		name  string
+		hours int
+	}{
+		{"sessionLogsRetentionHours", agent.SessionLogsRetentionHours},
+		{"runCommandLogsRetentionHours", agent.RunCommandLogsRetentionHours},
+		{"associationLogsRetentionHours", agent.AssociationLogsRetentionHours},
+	} {
+		if retention.hours < minLogsRetentionHours {
+			invalid("%s %d below %d", retention.name, retention.hours, minLogsRetentionHours)
+		}
+	}
+
+	return errs
+}
+
+// ValidateAgentLogging checks the agent seelog level and output.
+func ValidateAgentLogging(agent Agent) error {
+	var errs []error
+
+	if !slices.Contains(agentLogLevels, agent.LogLevel) {
+		errs = append(errs, fmt.Errorf("%w: logLevel %q: want one of %s",
+			errInvalidAgentSetting, agent.LogLevel, strings.Join(agentLogLevels, ", ")))
+	}
+
+	switch agent.LogOutput {
+	case AgentLogOutputStdout, AgentLogOutputFile, AgentLogOutputBoth:
+	default:
+		errs = append(errs, fmt.Errorf("%w: logOutput %q: want %s, %s or %s",
+			errInvalidAgentSetting, agent.LogOutput, AgentLogOutputStdout, AgentLogOutputFile, AgentLogOutputBoth))
+	}
+
+	return errors.Join(errs...)
+}
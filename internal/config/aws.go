@@ -0,0 +1,46 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+var errInvalidAWSSetting = errors.New("invalid aws setting")
+
+// validateAWS checks endpoint URLs are absolute http(s) URLs and that the CA bundle exists.
+func (c *Config) validateAWS() []error {
+	var errs []error
+
+	for _, endpoint := range []struct {
+		name string
+		url  string
+	}{
+		{"endpoints.ssm", c.AWS.Endpoints.SSM},
+		{"endpoints.sts", c.AWS.Endpoints.STS},
+		{"endpoints.secretsManager", c.AWS.Endpoints.SecretsManager},
+		{"endpoints.ssmMessages", c.AWS.Endpoints.SSMMessages},
+		{"endpoints.ec2Messages", c.AWS.Endpoints.EC2Messages},
+	} {
+		if endpoint.url == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(endpoint.url)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%w: %s %q must be an http or https URL", errInvalidAWSSetting, endpoint.name, endpoint.url))
+		}
+	}
+
+	if c.AWS.CABundle != "" {
+		if !filepath.IsAbs(c.AWS.CABundle) {
+			errs = append(errs, fmt.Errorf("%w: caBundle %q must be an absolute path", errInvalidAWSSetting, c.AWS.CABundle))
+		} else if _, err := os.Stat(c.AWS.CABundle); err != nil {
+			errs = append(errs, fmt.Errorf("%w: caBundle: %w", errInvalidAWSSetting, err))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,226 @@
+// Package config loads, merges and validates the wrapper configuration.
+//
+// Settings are resolved in increasing order of precedence: built-in defaults, an optional
+// YAML or JSON file, environment variables and command-line flags.
+package config
+
+import (
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+// Config is the complete wrapper configuration.
+type Config struct {
+	ManagedInstanceRole string     `json:"managedInstanceRole" yaml:"managedInstanceRole"`
+	TTL                 Duration   `json:"ttl"                 yaml:"ttl"`
+	Deadline            Deadline   `json:"deadline"            yaml:"deadline"`
+	ShutdownGrace       Duration   `json:"shutdownGrace"       yaml:"shutdownGrace"`
+	OnlineTimeout       Duration   `json:"onlineTimeout"       yaml:"onlineTimeout"`
+	RegistrationFile    string     `json:"registrationFile"    yaml:"registrationFile"`
+	TerminationReport   string     `json:"terminationReport"   yaml:"terminationReport"`
+	Metadata            Metadata   `json:"metadata"            yaml:"metadata"`
+	Watch               Watch      `json:"watch"               yaml:"watch"`
+	Ready               Ready      `json:"ready"               yaml:"ready"`
+	Lazy                Lazy       `json:"lazy"                yaml:"lazy"`
+	Activation          Activation `json:"activation"          yaml:"activation"`
+	Health              Health     `json:"health"              yaml:"health"`
+	Logging             Logging    `json:"logging"             yaml:"logging"`
+	Hooks               Hooks      `json:"hooks"               yaml:"hooks"`
+	Agent               Agent      `json:"agent"               yaml:"agent"`
+	Control             Control    `json:"control"             yaml:"control"`
+	AWS                 AWS        `json:"aws"                 yaml:"aws"`
+	Proxy               Proxy      `json:"proxy"               yaml:"proxy"`
+}
+
+// Metadata configures execution context discovery and its fallbacks.
+type Metadata struct {
+	URI              string `json:"uri"              yaml:"uri"`
+	Region           string `json:"region"           yaml:"region"`
+	AvailabilityZone string `json:"availabilityZone" yaml:"availabilityZone"`
+	TaskARN          string `json:"taskArn"          yaml:"taskArn"`
+}
+
+// Watch configures stopping the agent once the containers it runs alongside have stopped. At most
+// one of Containers, Process and File is set; none disables the watch.
+type Watch struct {
+	// Containers names ECS containers of the same task.
+	Containers []string `json:"containers" yaml:"containers"`
+	// Process names a process of another container in a pod sharing its process namespace.
+	Process string `json:"process" yaml:"process"`
+	// File is created by another container, typically in a shared emptyDir, when it finishes.
+	File     string   `json:"file"     yaml:"file"`
+	Interval Duration `json:"interval" yaml:"interval"`
+}
+
+// Ready policies: what happens when the application is not ready in time.
+const (
+	ReadyPolicyFail     = "fail"
+	ReadyPolicyContinue = "continue"
+)
+
+// Ready configures waiting for the application to be ready before activating, polled every
+// Watch.Interval. At most one of Containers and File is set; neither skips the wait.
+type Ready struct {
+	// Containers names ECS containers of the same task that must be HEALTHY.
+	Containers []string `json:"containers" yaml:"containers"`
+	// File is created by another container, typically in a shared emptyDir, once it is ready.
+	File    string   `json:"file"    yaml:"file"`
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+	Policy  string   `json:"policy"  yaml:"policy"`
+}
+
+// Lazy configures on-demand registration: the wrapper stays dormant until woken through the
+// control socket or the health server, and returns to dormant once the agent has been idle.
+type Lazy struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// IdleTimeout is how long a woken agent may run without a Session Manager session.
+	IdleTimeout Duration `json:"idleTimeout" yaml:"idleTimeout"`
+	// Token authenticates wake requests to the health server, which refuses them when it is empty.
+	Token string `json:"token" yaml:"token"`
+}
+
+// Activation configures the SSM activation request.
+type Activation struct {
+	Description string `json:"description" yaml:"description"`
+	ExtraTags   []Tag  `json:"extraTags"   yaml:"extraTags"`
+}
+
+// Tag is an additional activation tag.
+type Tag struct {
+	Key   string `json:"key"   yaml:"key"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// Health configures the HTTP health and metrics server.
+type Health struct {
+	ListenAddr string `json:"listenAddr" yaml:"listenAddr"`
+}
+
+// Logging configures wrapper log output.
+type Logging struct {
+	Format string `json:"format" yaml:"format"`
+	Level  string `json:"level"  yaml:"level"`
+}
+
+// Hooks configures lifecycle hooks.
+type Hooks struct {
+	PostRegistration Hook   `json:"postRegistration" yaml:"postRegistration"`
+	PreShutdown      Hook   `json:"preShutdown"      yaml:"preShutdown"`
+	PostCleanup      Hook   `json:"postCleanup"      yaml:"postCleanup"`
+	WebhookSecret    string `json:"webhookSecret"    yaml:"webhookSecret"`
+}
+
+// Hook configures a single lifecycle hook.
+type Hook struct {
+	Target  string   `json:"target"  yaml:"target"`
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+	Policy  string   `json:"policy"  yaml:"policy"`
+}
+
+// Agent configures the rendered amazon-ssm-agent.json. Field bounds mirror the agent's AppConfig.
+type Agent struct {
+	ConfigDir                     string   `json:"configDir"                     yaml:"configDir"`
+	CommandWorkersLimit           int      `json:"commandWorkersLimit"           yaml:"commandWorkersLimit"`
+	CommandRetryLimit             int      `json:"commandRetryLimit"             yaml:"commandRetryLimit"`
+	SessionHandshakeTimeout       Duration `json:"sessionHandshakeTimeout"       yaml:"sessionHandshakeTimeout"`
+	SessionLogsDestination        string   `json:"sessionLogsDestination"        yaml:"sessionLogsDestination"`
+	SessionLogsRetentionHours     int      `json:"sessionLogsRetentionHours"     yaml:"sessionLogsRetentionHours"`
+	RunCommandLogsRetentionHours  int      `json:"runCommandLogsRetentionHours"  yaml:"runCommandLogsRetentionHours"`
+	AssociationLogsRetentionHours int      `json:"associationLogsRetentionHours" yaml:"associationLogsRetentionHours"`
+	LogLevel                      string   `json:"logLevel"                      yaml:"logLevel"`
+	LogOutput                     string   `json:"logOutput"                     yaml:"logOutput"`
+}
+
+// Control configures the local control socket.
+type Control struct {
+	Socket string `json:"socket" yaml:"socket"`
+}
+
+// AWS configures how the wrapper and the agent reach AWS APIs.
+type AWS struct {
+	Endpoints    Endpoints `json:"endpoints"    yaml:"endpoints"`
+	UseFIPS      bool      `json:"useFips"      yaml:"useFips"`
+	UseDualStack bool      `json:"useDualStack" yaml:"useDualStack"`
+	CABundle     string    `json:"caBundle"     yaml:"caBundle"`
+}
+
+// Endpoints overrides service endpoint URLs, for example VPC interface endpoints or a local
+// stand-in. Empty values use the SDK's regional resolution.
+type Endpoints struct {
+	SSM            string `json:"ssm"            yaml:"ssm"`
+	STS            string `json:"sts"            yaml:"sts"`
+	SecretsManager string `json:"secretsManager" yaml:"secretsManager"`
+	SSMMessages    string `json:"ssmMessages"    yaml:"ssmMessages"`
+	EC2Messages    string `json:"ec2Messages"    yaml:"ec2Messages"`
+}
+
+// Proxy configures the outbound HTTP proxy shared by the wrapper and the agent.
+type Proxy struct {
+	HTTPS   string `json:"https"   yaml:"https"`
+	HTTP    string `json:"http"    yaml:"http"`
+	NoProxy string `json:"noProxy" yaml:"noProxy"`
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	defaultHook := Hook{Target: "", Timeout: seconds(internal.DefaultHookTimeoutSeconds), Policy: ""}
+
+	return Config{
+		ManagedInstanceRole: "",
+		TTL:                 seconds(internal.DefaultTTLSeconds),
+		Deadline:            Deadline{},
+		ShutdownGrace:       seconds(internal.DefaultShutdownGraceSeconds),
+		OnlineTimeout:       seconds(internal.DefaultOnlineTimeoutSeconds),
+		RegistrationFile:    internal.RegistrationFilePath,
+		TerminationReport:   "",
+		Metadata:            Metadata{URI: "", Region: "", AvailabilityZone: "", TaskARN: ""},
+		Watch: Watch{
+			Containers: nil,
+			Process:    "",
+			File:       "",
+			Interval:   seconds(internal.DefaultWatchIntervalSeconds),
+		},
+		Ready: Ready{
+			Containers: nil,
+			File:       "",
+			Timeout:    seconds(internal.DefaultReadyTimeoutSeconds),
+			Policy:     ReadyPolicyFail,
+		},
+		Lazy:       Lazy{Enabled: false, IdleTimeout: seconds(internal.DefaultLazyIdleSeconds), Token: ""},
+		Activation: Activation{Description: "", ExtraTags: nil},
+		Health:     Health{ListenAddr: ""},
+		Logging:    Logging{Format: logging.FormatText, Level: "info"},
+		Hooks: Hooks{
+			PostRegistration: defaultHook,
+			PreShutdown:      defaultHook,
+			PostCleanup:      defaultHook,
+			WebhookSecret:    "",
+		},
+		Agent: Agent{
+			ConfigDir:                     internal.DefaultAgentConfigDir,
+			CommandWorkersLimit:           defaultCommandWorkersLimit,
+			CommandRetryLimit:             defaultCommandRetryLimit,
+			SessionHandshakeTimeout:       seconds(defaultSessionHandshakeSeconds),
+			SessionLogsDestination:        SessionLogsNone,
+			SessionLogsRetentionHours:     defaultSessionLogsRetentionHours,
+			RunCommandLogsRetentionHours:  defaultRunCommandLogsRetentionHours,
+			AssociationLogsRetentionHours: defaultAssociationLogsRetentionHours,
+			LogLevel:                      AgentLogLevelInfo,
+			LogOutput:                     AgentLogOutputStdout,
+		},
+		Control: Control{Socket: internal.DefaultControlSocket},
+		AWS: AWS{
+			Endpoints:    Endpoints{SSM: "", STS: "", SecretsManager: "", SSMMessages: "", EC2Messages: ""},
+			UseFIPS:      false,
+			UseDualStack: false,
+			CABundle:     "",
+		},
+		Proxy: Proxy{HTTPS: "", HTTP: "", NoProxy: ""},
+	}
+}
+
+func seconds(n int) Duration {
+	return Duration(time.Duration(n) * time.Second)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	errDurationType = errors.New("duration must be a number of seconds or a Go duration string")
+	errDeadlineType = errors.New("deadline must be an RFC3339 string or a Unix timestamp")
+)
+
+// Duration is a time.Duration expressed in configuration files as whole seconds or a Go
+// duration string such as "90m".
+type Duration time.Duration
+
+// Std returns the value as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// MarshalJSON renders the duration as whole seconds.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(int64(time.Duration(d) / time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("marshal duration: %w", err)
+	}
+
+	return data, nil
+}
+
+// UnmarshalJSON accepts a number of seconds or a Go duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var secs int64
+
+	err := json.Unmarshal(data, &secs)
+	if err == nil {
+		*d = Duration(time.Duration(secs) * time.Second)
+
+		return nil
+	}
+
+	var text string
+
+	err = json.Unmarshal(data, &text)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errDurationType, data)
+	}
+
+	parsed, err := env.ParseDuration("duration", text)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errDurationType, err)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// UnmarshalYAML accepts a number of seconds or a Go duration string.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("%w: line %d", errDurationType, node.Line)
+	}
+
+	parsed, err := env.ParseDuration(fmt.Sprintf("line %d", node.Line), node.Value)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errDurationType, err)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// Deadline is an absolute end time expressed in configuration files as RFC3339 with an offset or
+// a Unix timestamp in seconds. The zero value means no deadline.
+type Deadline time.Time
+
+// Std returns the value as a time.Time.
+func (d Deadline) Std() time.Time {
+	return time.Time(d)
+}
+
+// IsZero reports whether no deadline is set.
+func (d Deadline) IsZero() bool {
+	return time.Time(d).IsZero()
+}
+
+// MarshalJSON renders the deadline as RFC3339, or null when unset.
+func (d Deadline) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+
+	data, err := json.Marshal(time.Time(d).Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("marshal deadline: %w", err)
+	}
+
+	return data, nil
+}
+
+// UnmarshalJSON accepts an RFC3339 string or a Unix timestamp.
+func (d *Deadline) UnmarshalJSON(data []byte) error {
+	var text string
+
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		var unix json.Number
+
+		err = json.Unmarshal(data, &unix)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errDeadlineType, data)
+		}
+
+		text = unix.String()
+	}
+
+	parsed, err := env.ParseDeadline("deadline", text)
+	if err != nil {
+		return err
+	}
+
+	*d = Deadline(parsed)
+
+	return nil
+}
+
+// UnmarshalYAML accepts an RFC3339 string or a Unix timestamp.
+func (d *Deadline) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("%w: line %d", errDeadlineType, node.Line)
+	}
+
+	parsed, err := env.ParseDeadline(fmt.Sprintf("line %d", node.Line), node.Value)
+	if err != nil {
+		return err
+	}
+
+	*d = Deadline(parsed)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package config_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDurationUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		json    string
+		yaml    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "seconds", json: `600`, yaml: `600`, want: 10 * time.Minute},
+		{name: "seconds as a string", json: `"600"`, yaml: `"600"`, want: 10 * time.Minute},
+		{name: "go duration", json: `"1h30m"`, yaml: `1h30m`, want: 90 * time.Minute},
+		{name: "invalid", json: `"soon"`, yaml: `soon`, wantErr: true},
+		{name: "not a scalar", json: `[600]`, yaml: `[600]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fromJSON, fromYAML config.Duration
+
+			jsonErr := json.Unmarshal([]byte(tt.json), &fromJSON)
+			yamlErr := yaml.Unmarshal([]byte(tt.yaml), &fromYAML)
+
+			if (jsonErr != nil) != tt.wantErr || (yamlErr != nil) != tt.wantErr {
+				t.Fatalf("errors json %v, yaml %v; want error %t", jsonErr, yamlErr, tt.wantErr)
+			}
+
+			if fromJSON.Std() != tt.want || fromYAML.Std() != tt.want {
+				t.Errorf("json %s, yaml %s; want %s", fromJSON.Std(), fromYAML.Std(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(config.Duration(90 * time.Minute))
+	if err != nil || string(data) != "5400" {
+		t.Errorf("Marshal = %s, %v; want 5400", data, err)
+	}
+}
+
+func TestDeadlineUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	noon := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		json    string
+		yaml    string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "rfc3339", json: `"2026-10-18T12:00:00Z"`, yaml: `2026-10-18T12:00:00Z`, want: noon},
+		{name: "offset", json: `"2026-10-18T13:00:00+01:00"`, yaml: `"2026-10-18T13:00:00+01:00"`, want: noon},
+		{name: "unix seconds", json: `1792324800`, yaml: `1792324800`, want: time.Unix(1792324800, 0)},
+		{name: "unix milliseconds", json: `1792324800000`, yaml: `1792324800000`, wantErr: true},
+		{name: "zoneless", json: `"2026-10-18T12:00:00"`, yaml: `2026-10-18T12:00:00`, wantErr: true},
+		{name: "not a scalar", json: `{}`, yaml: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fromJSON, fromYAML config.Deadline
+
+			jsonErr := json.Unmarshal([]byte(tt.json), &fromJSON)
+			yamlErr := yaml.Unmarshal([]byte(tt.yaml), &fromYAML)
+
+			if (jsonErr != nil) != tt.wantErr || (yamlErr != nil) != tt.wantErr {
+				t.Fatalf("errors json %v, yaml %v; want error %t", jsonErr, yamlErr, tt.wantErr)
+			}
+
+			if !fromJSON.Std().Equal(tt.want) || !fromYAML.Std().Equal(tt.want) {
+				t.Errorf("json %s, yaml %s; want %s", fromJSON.Std(), fromYAML.Std(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadlineMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		deadline config.Deadline
+		want     string
+	}{
+		{deadline: config.Deadline{}, want: "null"},
+		{deadline: config.Deadline(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)), want: `"2026-10-18T12:00:00Z"`},
+	} {
+		data, err := json.Marshal(tt.deadline)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("Marshal = %s, %v; want %s", data, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,549 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FlagConfigFile names the flag that selects the configuration file.
+	FlagConfigFile = "config"
+
+	flagTTLSeconds = "ttl-seconds"
+
+	extraTagDelimiter = ","
+	listDelimiter     = ","
+)
+
+var errTTLAndDeadline = errors.New("ttl and deadline are mutually exclusive")
+
+// LookupFunc reports the value of an environment variable and whether it was set. It fails when
+// the value is indirected through a file or secret reference that cannot be resolved.
+type LookupFunc func(key string) (string, bool, error)
+
+// setting binds one configuration field to its environment variable and flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	// clearable applies an explicitly empty environment value instead of ignoring it.
+	clearable bool
+	apply     func(cfg *Config, value string) error
+}
+
+// clearable lets an empty environment value override the default, for settings where empty
+// disables a feature.
+func clearable(s setting) setting {
+	s.clearable = true
+
+	return s
+}
+
+func stringSetting(envKey, flagName, usage string, field func(*Config) *string) setting {
+	return setting{
+		env:       envKey,
+		flag:      flagName,
+		usage:     usage,
+		clearable: false,
+		apply: func(cfg *Config, value string) error {
+			*field(cfg) = value
+
+			return nil
+		},
+	}
+}
+
+func durationSetting(envKey, flagName, usage string, field func(*Config) *Duration) setting {
+	return setting{
+		env:       envKey,
+		flag:      flagName,
+		usage:     usage,
+		clearable: false,
+		apply: func(cfg *Config, value string) error {
+			parsed, err := env.ParseDuration(envKey, value)
+			if err != nil {
+				return err
+			}
+
+			*field(cfg) = Duration(parsed)
+
+			return nil
+		},
+	}
+}
+
+func deadlineSetting(envKey, flagName, usage string, field func(*Config) *Deadline) setting {
+	return setting{
+		env:       envKey,
+		flag:      flagName,
+		usage:     usage,
+		clearable: false,
+		apply: func(cfg *Config, value string) error {
+			parsed, err := env.ParseDeadline(envKey, value)
+			if err != nil {
+				return err
+			}
+
+			*field(cfg) = Deadline(parsed)
+
+			return nil
+		},
+	}
+}
+
+func intSetting(envKey, flagName, usage string, field func(*Config) *int) setting {
+	return setting{
+		env:       envKey,
+		flag:      flagName,
+		usage:     usage,
+		clearable: false,
+		apply: func(cfg *Config, value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("parse %s (%q): %w", envKey, value, err)
+			}
+
+			*field(cfg) = parsed
+
+			return nil
+		},
+	}
+}
+
+func boolSetting(envKey, flagName, usage string, field func(*Config) *bool) setting {
+	return setting{
+		env:       envKey,
+		flag:      flagName,
+		usage:     usage,
+		clearable: false,
+		apply: func(cfg *Config, value string) error {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("parse %s (%q): %w", envKey, value, err)
+			}
+
+			*field(cfg) = parsed
+
+			return nil
+		},
+	}
+}
+
+// listSetting splits a comma-separated value, dropping blank entries.
+func listSetting(envKey, flagName, usage string, field func(*Config) *[]string) setting {
+	return setting{
+		env:       envKey,
+		flag:      flagName,
+		usage:     usage,
+		clearable: false,
+		apply: func(cfg *Config, value string) error {
+			var items []string
+
+			for item := range strings.SplitSeq(value, listDelimiter) {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+
+			*field(cfg) = items
+
+			return nil
+		},
+	}
+}
+
+func hookSettings(event, flagPrefix string, field func(*Config) *Hook) []setting {
+	key := internal.EnvHookPrefix + event
+
+	return []setting{
+		stringSetting(key, flagPrefix, "executable or webhook URL for the "+flagPrefix,
+			func(c *Config) *string { return &field(c).Target }),
+		durationSetting(key+internal.EnvHookTimeoutSuffix, flagPrefix+"-timeout", "timeout in seconds for the "+flagPrefix,
+			func(c *Config) *Duration { return &field(c).Timeout }),
+		stringSetting(key+internal.EnvHookPolicySuffix, flagPrefix+"-policy", "fail-open or fail-closed for the "+flagPrefix,
+			func(c *Config) *string { return &field(c).Policy }),
+	}
+}
+
+// settings lists every environment variable and flag override in the order they are applied.
+// AWS_DEFAULT_REGION precedes AWS_REGION, and lowercase proxy variables precede uppercase ones, so
+// the latter win when both are set.
+var settings = append([]setting{
+	stringSetting(internal.EnvManagedInstanceRole, "role", "IAM role name for the activation",
+		func(c *Config) *string { return &c.ManagedInstanceRole }),
+	durationSetting(internal.EnvTTLSeconds, flagTTLSeconds, "lifetime of the wrapped service in seconds or as a Go duration",
+		func(c *Config) *Duration { return &c.TTL }),
+	deadlineSetting(internal.EnvTTLDeadline, "ttl-deadline", "absolute end time (RFC3339 or Unix seconds) replacing the ttl",
+		func(c *Config) *Deadline { return &c.Deadline }),
+	durationSetting(internal.EnvTTLShutdownGraceSeconds, "shutdown-grace-seconds", "wait after SIGTERM before SIGKILL",
+		func(c *Config) *Duration { return &c.ShutdownGrace }),
+	durationSetting(internal.EnvOnlineTimeoutSeconds, "online-timeout-seconds",
+		"wait for SSM to report the agent Online before failing; 0 skips the check",
+		func(c *Config) *Duration { return &c.OnlineTimeout }),
+	stringSetting(internal.EnvRegistrationFileOverride, "registration-file", "amazon-ssm-agent registration file",
+		func(c *Config) *string { return &c.RegistrationFile }),
+	stringSetting(internal.EnvTerminationReport, "termination-report",
+		"write a JSON termination report to this path on exit, such as /dev/termination-log",
+		func(c *Config) *string { return &c.TerminationReport }),
+	stringSetting(internal.MetadataEnvKey, "metadata-uri", "ECS task metadata v4 base URI",
+		func(c *Config) *string { return &c.Metadata.URI }),
+	listSetting(internal.EnvWatchContainers, "watch-containers",
+		"comma-separated ECS containers of this task; stop the agent once any of them stops",
+		func(c *Config) *[]string { return &c.Watch.Containers }),
+	stringSetting(internal.EnvWatchProcess, "watch-process",
+		"process of another container in the pod; stop the agent once it exits (needs shareProcessNamespace)",
+		func(c *Config) *string { return &c.Watch.Process }),
+	stringSetting(internal.EnvWatchFile, "watch-file", "stop the agent once this file exists, e.g. in a shared emptyDir",
+		func(c *Config) *string { return &c.Watch.File }),
+	durationSetting(internal.EnvWatchIntervalSeconds, "watch-interval-seconds", "how often the watched containers are checked",
+		func(c *Config) *Duration { return &c.Watch.Interval }),
+	listSetting(internal.EnvReadyContainers, "ready-containers",
+		"comma-separated ECS containers of this task that must be HEALTHY before activation",
+		func(c *Config) *[]string { return &c.Ready.Containers }),
+	stringSetting(internal.EnvReadyFile, "ready-file", "file that must exist before activation, e.g. in a shared emptyDir",
+		func(c *Config) *string { return &c.Ready.File }),
+	durationSetting(internal.EnvReadyTimeoutSeconds, "ready-timeout-seconds", "wait for the ready containers or file",
+		func(c *Config) *Duration { return &c.Ready.Timeout }),
+	stringSetting(internal.EnvReadyPolicy, "ready-policy", "fail or continue when the application is not ready in time",
+		func(c *Config) *string { return &c.Ready.Policy }),
+	boolSetting(internal.EnvLazy, "lazy", "stay dormant until a wake request instead of registering at startup",
+		func(c *Config) *bool { return &c.Lazy.Enabled }),
+	durationSetting(internal.EnvLazyIdleSeconds, "lazy-idle-seconds",
+		"go dormant again once the woken agent has had no session for this long",
+		func(c *Config) *Duration { return &c.Lazy.IdleTimeout }),
+	stringSetting(internal.EnvLazyToken, "", "",
+		func(c *Config) *string { return &c.Lazy.Token }),
+	stringSetting(internal.EnvFallbackDefaultRegion, "", "",
+		func(c *Config) *string { return &c.Metadata.Region }),
+	stringSetting(internal.EnvFallbackRegion, "region", "region used when metadata is unavailable",
+		func(c *Config) *string { return &c.Metadata.Region }),
+	stringSetting(internal.EnvFallbackAvailabilityZone, "availability-zone", "availability zone used when metadata is unavailable",
+		func(c *Config) *string { return &c.Metadata.AvailabilityZone }),
+	stringSetting(internal.EnvFallbackTaskARN, "task-arn", "task ARN used when metadata is unavailable",
+		func(c *Config) *string { return &c.Metadata.TaskARN }),
+	stringSetting(internal.EnvActivationDescription, "activation-description", "activation description",
+		func(c *Config) *string { return &c.Activation.Description }),
+	{
+		env:       internal.EnvAdditionalActivationTags,
+		flag:      "activation-tags",
+		usage:     "extra activation tags as key=value,key=value",
+		clearable: false,
+		apply: func(c *Config, value string) error {
+			c.Activation.ExtraTags = ParseTags(value)
+
+			return nil
+		},
+	},
+	stringSetting(internal.EnvHealthListenAddr, "health-addr", "health server listen address",
+		func(c *Config) *string { return &c.Health.ListenAddr }),
+	stringSetting(internal.EnvLogFormat, "log-format", "log format: text or json",
+		func(c *Config) *string { return &c.Logging.Format }),
+	stringSetting(internal.EnvLogLevel, "log-level", "log level: debug, info, warn or error",
+		func(c *Config) *string { return &c.Logging.Level }),
+	stringSetting(internal.EnvHookWebhookSecret, "", "",
+		func(c *Config) *string { return &c.Hooks.WebhookSecret }),
+	stringSetting(internal.EnvAgentConfigDir, "agent-config-dir", "directory the agent configuration is rendered into",
+		func(c *Config) *string { return &c.Agent.ConfigDir }),
+	intSetting(internal.EnvAgentCommandWorkersLimit, "agent-command-workers-limit", "agent Mds.CommandWorkersLimit",
+		func(c *Config) *int { return &c.Agent.CommandWorkersLimit }),
+	intSetting(internal.EnvAgentCommandRetryLimit, "agent-command-retry-limit", "agent Mds.CommandRetryLimit",
+		func(c *Config) *int { return &c.Agent.CommandRetryLimit }),
+	durationSetting(internal.EnvAgentSessionHandshakeTimeout, "agent-session-handshake-timeout",
+		"agent Ssm.SessionHandshakeTimeoutSeconds",
+		func(c *Config) *Duration { return &c.Agent.SessionHandshakeTimeout }),
+	stringSetting(internal.EnvAgentSessionLogsDestination, "agent-session-logs-destination",
+		"agent Ssm.SessionLogsDestination: none or disk",
+		func(c *Config) *string { return &c.Agent.SessionLogsDestination }),
+	intSetting(internal.EnvAgentSessionLogsRetentionHours, "agent-session-logs-retention-hours",
+		"agent Ssm.SessionLogsRetentionDurationHours",
+		func(c *Config) *int { return &c.Agent.SessionLogsRetentionHours }),
+	intSetting(internal.EnvAgentRunCommandLogsRetentionHours, "agent-run-command-logs-retention-hours",
+		"agent Ssm.RunCommandLogsRetentionDurationHours",
+		func(c *Config) *int { return &c.Agent.RunCommandLogsRetentionHours }),
+	intSetting(internal.EnvAgentAssociationLogsRetentionHours, "agent-association-logs-retention-hours",
+		"agent Ssm.AssociationLogsRetentionDurationHours",
+		func(c *Config) *int { return &c.Agent.AssociationLogsRetentionHours }),
+	stringSetting(internal.EnvAgentLogLevel, "agent-log-level",
+		"agent log level: trace, debug, info, warn, error, critical or off",
+		func(c *Config) *string { return &c.Agent.LogLevel }),
+	stringSetting(internal.EnvAgentLogOutput, "agent-log-output", "agent log output: stdout, file or both",
+		func(c *Config) *string { return &c.Agent.LogOutput }),
+	clearable(stringSetting(internal.EnvControlSocket, "control-socket", "wrapper control socket path; empty disables it",
+		func(c *Config) *string { return &c.Control.Socket })),
+	stringSetting(internal.EnvEndpointSSM, "ssm-endpoint", "SSM endpoint URL for the wrapper and the agent",
+		func(c *Config) *string { return &c.AWS.Endpoints.SSM }),
+	stringSetting(internal.EnvEndpointSTS, "sts-endpoint", "STS endpoint URL",
+		func(c *Config) *string { return &c.AWS.Endpoints.STS }),
+	stringSetting(internal.EnvEndpointSecretsManager, "secretsmanager-endpoint", "Secrets Manager endpoint URL",
+		func(c *Config) *string { return &c.AWS.Endpoints.SecretsManager }),
+	stringSetting(internal.EnvEndpointSSMMessages, "ssmmessages-endpoint", "agent Session Manager endpoint URL",
+		func(c *Config) *string { return &c.AWS.Endpoints.SSMMessages }),
+	stringSetting(internal.EnvEndpointEC2Messages, "ec2messages-endpoint", "agent message delivery endpoint URL",
+		func(c *Config) *string { return &c.AWS.Endpoints.EC2Messages }),
+	boolSetting(internal.EnvUseFIPSEndpoint, "use-fips-endpoint", "use FIPS endpoints",
+		func(c *Config) *bool { return &c.AWS.UseFIPS }),
+	boolSetting(internal.EnvUseDualStackEndpoint, "use-dualstack-endpoint", "use dual-stack endpoints",
+		func(c *Config) *bool { return &c.AWS.UseDualStack }),
+	stringSetting(strings.ToLower(internal.EnvHTTPSProxy), "", "",
+		func(c *Config) *string { return &c.Proxy.HTTPS }),
+	stringSetting(internal.EnvHTTPSProxy, "https-proxy", "proxy URL for HTTPS requests",
+		func(c *Config) *string { return &c.Proxy.HTTPS }),
+	stringSetting(strings.ToLower(internal.EnvHTTPProxy), "", "",
+		func(c *Config) *string { return &c.Proxy.HTTP }),
+	stringSetting(internal.EnvHTTPProxy, "http-proxy", "proxy URL for plain HTTP requests",
+		func(c *Config) *string { return &c.Proxy.HTTP }),
+	stringSetting(strings.ToLower(internal.EnvNoProxy), "", "",
+		func(c *Config) *string { return &c.Proxy.NoProxy }),
+	stringSetting(internal.EnvNoProxy, "no-proxy", "comma-separated hosts, domains and CIDRs reached directly",
+		func(c *Config) *string { return &c.Proxy.NoProxy }),
+	stringSetting(internal.EnvCABundle, "ca-bundle", "PEM bundle of additional trusted CAs",
+		func(c *Config) *string { return &c.AWS.CABundle }),
+}, slices.Concat(
+	hookSettings("POST_REGISTRATION", "post-registration-hook", func(c *Config) *Hook { return &c.Hooks.PostRegistration }),
+	hookSettings("PRE_SHUTDOWN", "pre-shutdown-hook", func(c *Config) *Hook { return &c.Hooks.PreShutdown }),
+	hookSettings("POST_CLEANUP", "post-cleanup-hook", func(c *Config) *Hook { return &c.Hooks.PostCleanup }),
+)...)
+
+// RegisterFlags adds the configuration file flag and one flag per overridable setting to fs.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.String(FlagConfigFile, "", "path to a YAML or JSON configuration file (env "+internal.EnvConfigFile+")")
+
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+
+		fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+}
+
+// Load resolves the configuration from defaults, the optional file, the environment reported by
+// lookup and any flags explicitly set on fs, which may be nil. Every problem is reported together.
+func Load(lookup LookupFunc, fs *flag.FlagSet) (Config, error) {
+	if lookup == nil {
+		lookup = EnvLookup(context.Background(), nil, nil)
+	}
+
+	cfg := Default()
+
+	var errs []error
+
+	path := flagValue(fs, FlagConfigFile)
+	if path == "" {
+		var err error
+
+		path, _, err = trimmedLookup(lookup, internal.EnvConfigFile)
+		errs = append(errs, err)
+	}
+
+	var fromFile fileSettings
+
+	if path != "" {
+		var err error
+
+		fromFile, err = loadFile(path, &cfg)
+		errs = append(errs, err)
+	}
+
+	ttlSet := fromFile.TTL != nil
+
+	for _, s := range settings {
+		value, set, err := trimmedLookup(lookup, s.env)
+		if err != nil || (value == "" && !(set && s.clearable)) {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		ttlSet = ttlSet || s.env == internal.EnvTTLSeconds
+		errs = append(errs, s.apply(&cfg, value))
+	}
+
+	errs = append(errs, applyFlags(fs, &cfg)...)
+	ttlSet = ttlSet || flagSet(fs, flagTTLSeconds)
+
+	if ttlSet && !cfg.Deadline.IsZero() {
+		errs = append(errs, fmt.Errorf("%w: set %s or %s, not both",
+			errTTLAndDeadline, internal.EnvTTLSeconds, internal.EnvTTLDeadline))
+	}
+
+	errs = append(errs, cfg.Validate())
+
+	err := errors.Join(errs...)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+func applyFlags(fs *flag.FlagSet, cfg *Config) []error {
+	if fs == nil {
+		return nil
+	}
+
+	byFlag := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		if s.flag != "" {
+			byFlag[s.flag] = s
+		}
+	}
+
+	var errs []error
+
+	fs.Visit(func(f *flag.Flag) {
+		s, ok := byFlag[f.Name]
+		if !ok {
+			return
+		}
+
+		errs = append(errs, s.apply(cfg, strings.TrimSpace(f.Value.String())))
+	})
+
+	return errs
+}
+
+func flagSet(fs *flag.FlagSet, name string) bool {
+	if fs == nil {
+		return false
+	}
+
+	set := false
+
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
+
+func flagValue(fs *flag.FlagSet, name string) string {
+	if fs == nil {
+		return ""
+	}
+
+	f := fs.Lookup(name)
+	if f == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(f.Value.String())
+}
+
+func trimmedLookup(lookup LookupFunc, key string) (string, bool, error) {
+	value, set, err := lookup(key)
+
+	return strings.TrimSpace(value), set, err
+}
+
+// EnvLookup reads variables from lookup, or the process environment when it is nil, honoring
+// KEY_FILE indirection and resolving ssm-parameter:// and secretsmanager:// references through
+// store, which may be nil.
+func EnvLookup(ctx context.Context, lookup env.LookupFunc, store env.SecretStore) LookupFunc {
+	resolver := env.NewResolver(lookup, store)
+
+	return func(key string) (string, bool, error) {
+		return resolver.Lookup(ctx, key)
+	}
+}
+
+// fileSettings records settings a configuration file sets explicitly, where the decoded value
+// cannot tell them apart from the default.
+type fileSettings struct {
+	TTL *Duration `json:"ttl" yaml:"ttl"`
+}
+
+// loadFile decodes a JSON (by .json extension) or YAML file over cfg, rejecting unknown keys, and
+// reports which settings it set explicitly.
+func loadFile(path string, cfg *Config) (fileSettings, error) {
+	var set fileSettings
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path supplied by operator
+	if err != nil {
+		return set, fmt.Errorf("read config file: %w", err)
+	}
+
+	isJSON := strings.EqualFold(filepath.Ext(path), ".json")
+
+	err = decodeFile(data, isJSON, true, cfg)
+	if err != nil {
+		return set, fmt.Errorf("decode config file %s: %w", path, err)
+	}
+
+	err = decodeFile(data, isJSON, false, &set)
+	if err != nil {
+		return set, fmt.Errorf("decode config file %s: %w", path, err)
+	}
+
+	return set, nil
+}
+
+// decodeFile decodes data into out as JSON or YAML; strict rejects unknown keys. An empty file
+// leaves out unchanged.
+func decodeFile(data []byte, isJSON, strict bool, out any) error {
+	var err error
+
+	if isJSON {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+
+		err = decoder.Decode(out)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(strict)
+		err = decoder.Decode(out)
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err //nolint:wrapcheck // Wrapped with the file path by loadFile.
+	}
+
+	return nil
+}
+
+// ParseTags parses "key=value,key=value" into tags. Segments without "=" or with an empty key
+// are skipped with a warning, as they always have been.
+func ParseTags(raw string) []Tag {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil
+	}
+
+	segments := strings.Split(trimmed, extraTagDelimiter)
+	tags := make([]Tag, 0, len(segments))
+
+	for _, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(segment, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			slog.Warn("skipping malformed activation tag (want key=value)", slog.String("tag", segment))
+
+			continue
+		}
+
+		tags = append(tags, Tag{Key: key, Value: strings.TrimSpace(value)})
+	}
+
+	return tags
+}
@@ -0,0 +1,277 @@
+package config_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+)
+
+// load resolves the configuration from environ, a list of KEY=value entries, and args.
+func load(t *testing.T, environ []string, args ...string) (config.Config, error) {
+	t.Helper()
+
+	fs := flag.NewFlagSet("ttl", flag.ContinueOnError)
+	config.RegisterFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return config.Load(config.EnvLookup(t.Context(), env.EnvironLookup(environ), nil), fs)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Parallel()
+
+	file := writeFile(t, "ttl.yaml", "managedInstanceRole: from-file\nttl: 600\nactivation:\n  description: from file\n")
+
+	cfg, err := load(t,
+		[]string{"TTL_CONFIG_FILE=" + file, "SSM_ACTIVATION_DESCRIPTION=from env", "TTL_SECONDS=90m"},
+		"--ttl-seconds", "45")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.ManagedInstanceRole != "from-file" || cfg.Activation.Description != "from env" || cfg.TTL.Std() != 45*time.Second {
+		t.Errorf("role %q, description %q, ttl %s; want the file's role, the env description and the flag's TTL",
+			cfg.ManagedInstanceRole, cfg.Activation.Description, cfg.TTL.Std())
+	}
+}
+
+func TestLoadSkipsMalformedTags(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := load(t, []string{
+		"MANAGED_INSTANCE_ROLE_NAME=role",
+		"SSM_ACTIVATION_EXTRA_TAGS=team=platform, broken ,=orphan, env = dev ,",
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := []config.Tag{{Key: "team", Value: "platform"}, {Key: "env", Value: "dev"}}
+	if !slices.Equal(cfg.Activation.ExtraTags, want) {
+		t.Errorf("tags = %+v, want %+v", cfg.Activation.ExtraTags, want)
+	}
+}
+
+func TestLoadEmptyControlSocketDisablesIt(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := load(t, []string{"MANAGED_INSTANCE_ROLE_NAME=role", "TTL_CONTROL_SOCKET="})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Control.Socket != "" {
+		t.Errorf("control socket = %q, want it disabled", cfg.Control.Socket)
+	}
+
+	cfg, err = load(t, []string{"MANAGED_INSTANCE_ROLE_NAME=role", "LOG_LEVEL="})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Logging.Level != config.Default().Logging.Level {
+		t.Errorf("log level = %q, want the default kept for an empty variable", cfg.Logging.Level)
+	}
+}
+
+func TestLoadTTLAndDeadline(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		file     string
+		environ  []string
+		args     []string
+		conflict bool
+	}{
+		{name: "deadline alone", environ: []string{"TTL_DEADLINE=" + deadline}},
+		{name: "env ttl", environ: []string{"TTL_SECONDS=600", "TTL_DEADLINE=" + deadline}, conflict: true},
+		{name: "env ttl equal to the default", environ: []string{"TTL_SECONDS=3600", "TTL_DEADLINE=" + deadline}, conflict: true},
+		{name: "flag ttl", environ: []string{"TTL_DEADLINE=" + deadline}, args: []string{"--ttl-seconds", "600"}, conflict: true},
+		{name: "file ttl", file: "ttl: 600\n", environ: []string{"TTL_DEADLINE=" + deadline}, conflict: true},
+		{name: "file ttl equal to the default", file: "ttl: 1h\n", environ: []string{"TTL_DEADLINE=" + deadline}, conflict: true},
+		{name: "file deadline", file: "deadline: " + deadline + "\n"},
+		{name: "file without a ttl", file: "managedInstanceRole: role\n", environ: []string{"TTL_DEADLINE=" + deadline}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			environ := append([]string{"MANAGED_INSTANCE_ROLE_NAME=role"}, tt.environ...)
+			if tt.file != "" {
+				environ = append(environ, "TTL_CONFIG_FILE="+writeFile(t, "ttl.yaml", tt.file))
+			}
+
+			_, err := load(t, environ, tt.args...)
+			if conflict := err != nil && strings.Contains(err.Error(), "mutually exclusive"); conflict != tt.conflict {
+				t.Errorf("Load error = %v, want conflict %t", err, tt.conflict)
+			}
+
+			if !tt.conflict && err != nil {
+				t.Errorf("Load: %v", err)
+			}
+		})
+	}
+}
+
+// imageEnviron returns the KEY=value entries the runtime stage of the Dockerfile sets with ENV.
+func imageEnviron(t *testing.T) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, runtime, ok := strings.Cut(string(data), "AS runtime")
+	if !ok {
+		t.Fatal("Dockerfile has no runtime stage")
+	}
+
+	var environ []string
+
+	inEnv := false
+
+	for line := range strings.Lines(runtime) {
+		line = strings.TrimSpace(line)
+
+		rest, isEnv := strings.CutPrefix(line, "ENV ")
+		if !isEnv && !inEnv {
+			continue
+		}
+
+		inEnv = strings.HasSuffix(rest, "\\")
+		environ = append(environ, strings.Fields(strings.TrimSuffix(rest, "\\"))...)
+	}
+
+	return environ
+}
+
+// TestLoadImageDefaultsWithDeadline checks that the environment the image ships with leaves
+// TTL_DEADLINE usable.
+func TestLoadImageDefaultsWithDeadline(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	environ := append(imageEnviron(t), "MANAGED_INSTANCE_ROLE_NAME=role", "TTL_DEADLINE="+deadline)
+
+	cfg, err := load(t, environ)
+	if err != nil {
+		t.Fatalf("Load with the image environment %q: %v", environ, err)
+	}
+
+	if cfg.Deadline.IsZero() {
+		t.Error("deadline not applied")
+	}
+}
+
+func TestLoadRejectsPastDeadline(t *testing.T) {
+	t.Parallel()
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	_, err := load(t, []string{"MANAGED_INSTANCE_ROLE_NAME=role", "TTL_DEADLINE=" + past})
+	if err == nil || !strings.Contains(err.Error(), "deadline is in the past") {
+		t.Errorf("Load = %v, want the past deadline rejected", err)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	file := writeFile(t, "ttl.json", `{"unknownKey": true}`)
+
+	_, err := load(t, []string{"TTL_CONFIG_FILE=" + file, "TTL_SHUTDOWN_GRACE_SECONDS=soon"}, "--log-level", "loud")
+	if err == nil {
+		t.Fatal("Load accepted an invalid configuration")
+	}
+
+	for _, want := range []string{"unknownKey", "TTL_SHUTDOWN_GRACE_SECONDS", "loud", "MANAGED_INSTANCE_ROLE_NAME"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := func() config.Config {
+		cfg := config.Default()
+		cfg.ManagedInstanceRole = "role"
+
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*config.Config)
+		want   string
+	}{
+		{name: "defaults with a role", modify: func(*config.Config) {}, want: ""},
+		{name: "missing role", modify: func(c *config.Config) { c.ManagedInstanceRole = "" }, want: "MANAGED_INSTANCE_ROLE_NAME"},
+		{name: "zero ttl", modify: func(c *config.Config) { c.TTL = 0 }, want: "TTL_SECONDS"},
+		{
+			name:   "registration file outside the agent directory",
+			modify: func(c *config.Config) { c.RegistrationFile = "/var/lib/amazon/ssm/../../../etc/passwd" },
+			want:   config.RegistrationBase,
+		},
+		{name: "listen address", modify: func(c *config.Config) { c.Health.ListenAddr = "8080" }, want: "8080"},
+		{
+			name:   "reserved tag prefix",
+			modify: func(c *config.Config) { c.Activation.ExtraTags = []config.Tag{{Key: "aws:owner", Value: "me"}} },
+			want:   "reserved prefix",
+		},
+		{name: "blank hook target", modify: func(c *config.Config) { c.Hooks.PreShutdown.Target = " \t" }, want: "blank"},
+		{
+			name: "unreachable lazy mode",
+			modify: func(c *config.Config) {
+				c.Lazy.Enabled = true
+				c.Control.Socket = ""
+			},
+			want: "lazy mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := valid()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate = %v, want nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
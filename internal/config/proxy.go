@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var errInvalidProxy = errors.New("invalid proxy setting")
+
+// validateProxy checks proxy URLs have a supported scheme and a host. Values are reported without
+// their credentials.
+func (c *Config) validateProxy() []error {
+	var errs []error
+
+	for _, proxy := range []struct {
+		name  string
+		value string
+	}{
+		{"https", c.Proxy.HTTPS},
+		{"http", c.Proxy.HTTP},
+	} {
+		if proxy.value == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(proxy.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s proxy is not a URL", errInvalidProxy, proxy.name))
+
+			continue
+		}
+
+		switch strings.ToLower(parsed.Scheme) {
+		case "http", "https", "socks5":
+		default:
+			errs = append(errs, fmt.Errorf("%w: %s proxy %q: scheme must be http, https or socks5",
+				errInvalidProxy, proxy.name, parsed.Redacted()))
+
+			continue
+		}
+
+		if parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%w: %s proxy %q has no host", errInvalidProxy, proxy.name, parsed.Redacted()))
+		}
+	}
+
+	return errs
+}
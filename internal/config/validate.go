@@ -0,0 +1,262 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/hooks"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	// RegistrationBase is the directory the registration file must live under.
+	RegistrationBase = "/var/lib/amazon/ssm"
+
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+	reservedTagPrefix = "aws:"
+)
+
+var (
+	errMissingRole             = errors.New("managed instance role is required")
+	errNonPositiveTTL          = errors.New("ttl must be greater than zero")
+	errRegistrationPathInvalid = errors.New("registration path outside allowed base")
+	errInvalidListenAddr       = errors.New("invalid health listen address")
+	errInvalidTag              = errors.New("invalid activation tag")
+	errNonPositiveHookTimeout  = errors.New("hook timeout must be greater than zero")
+	errBlankHookTarget         = errors.New("hook target is blank")
+	errInvalidControlSocket    = errors.New("control socket must be an absolute path")
+	errDeadlinePassed          = errors.New("deadline is in the past")
+	errWatchWithoutMetadata    = errors.New("watching containers requires the ECS task metadata endpoint")
+	errNonPositiveInterval     = errors.New("watch interval must be greater than zero")
+	errWatchConflict           = errors.New("watch containers, a process or a file, not more than one")
+	errRelativeWatchFile       = errors.New("watch file must be an absolute path")
+	errReadyConflict           = errors.New("wait for ready containers or a ready file, not both")
+	errRelativeReadyFile       = errors.New("ready file must be an absolute path")
+	errReadyWithoutMetadata    = errors.New("waiting for healthy containers requires the ECS task metadata endpoint")
+	errNonPositiveReadyTimeout = errors.New("ready timeout must be greater than zero")
+	errInvalidReadyPolicy      = errors.New("invalid ready policy")
+	errLazyUnreachable         = errors.New("lazy mode needs the control socket or a health server with a wake token")
+	errNonPositiveIdleTimeout  = errors.New("lazy idle timeout must be greater than zero")
+)
+
+// Validate checks every setting and returns all problems joined together.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.ManagedInstanceRole == "" {
+		errs = append(errs, fmt.Errorf("%w: set %s", errMissingRole, internal.EnvManagedInstanceRole))
+	}
+
+	if c.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errNonPositiveTTL, internal.EnvTTLSeconds))
+	}
+
+	if !c.Deadline.IsZero() && !c.Deadline.Std().After(time.Now()) {
+		errs = append(errs, fmt.Errorf("%w: %s %s", errDeadlinePassed,
+			internal.EnvTTLDeadline, c.Deadline.Std().Format(time.RFC3339)))
+	}
+
+	if c.ShutdownGrace < 0 {
+		c.ShutdownGrace = 0
+	}
+
+	if c.OnlineTimeout < 0 {
+		c.OnlineTimeout = 0
+	}
+
+	errs = append(errs, c.validateRegistrationFile(), c.validateHealth(), c.validateLogging())
+	errs = append(errs, c.validateTags()...)
+	errs = append(errs, c.validateAgent()...)
+	errs = append(errs, c.validateControl(), c.validateWatch(), c.validateReady(), c.validateLazy())
+	errs = append(errs, c.validateAWS()...)
+	errs = append(errs, c.validateProxy()...)
+	errs = append(errs,
+		validateHook("post-registration", c.Hooks.PostRegistration),
+		validateHook("pre-shutdown", c.Hooks.PreShutdown),
+		validateHook("post-cleanup", c.Hooks.PostCleanup),
+	)
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateRegistrationFile() error {
+	clean := filepath.Clean(c.RegistrationFile)
+	if !strings.HasPrefix(clean, RegistrationBase) {
+		return fmt.Errorf("%w: %s within %s", errRegistrationPathInvalid, clean, RegistrationBase)
+	}
+
+	c.RegistrationFile = clean
+
+	return nil
+}
+
+func (c *Config) validateHealth() error {
+	if c.Health.ListenAddr == "" {
+		return nil
+	}
+
+	_, _, err := net.SplitHostPort(c.Health.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", errInvalidListenAddr, c.Health.ListenAddr, err)
+	}
+
+	return nil
+}
+
+func (c *Config) validateLogging() error {
+	var errs []error
+
+	switch strings.ToLower(c.Logging.Format) {
+	case "", logging.FormatText, logging.FormatJSON:
+	default:
+		errs = append(errs, fmt.Errorf("log format %q: want %s or %s", c.Logging.Format, logging.FormatText, logging.FormatJSON))
+	}
+
+	_, err := logging.ParseLevel(c.Logging.Level)
+
+	return errors.Join(append(errs, err)...)
+}
+
+func (c *Config) validateTags() []error {
+	var errs []error
+
+	for _, tag := range c.Activation.ExtraTags {
+		switch {
+		case tag.Key == "":
+			errs = append(errs, fmt.Errorf("%w: empty key for value %q", errInvalidTag, tag.Value))
+		case len(tag.Key) > maxTagKeyLength:
+			errs = append(errs, fmt.Errorf("%w: key %q longer than %d", errInvalidTag, tag.Key, maxTagKeyLength))
+		case len(tag.Value) > maxTagValueLength:
+			errs = append(errs, fmt.Errorf("%w: value for %q longer than %d", errInvalidTag, tag.Key, maxTagValueLength))
+		case strings.HasPrefix(strings.ToLower(tag.Key), reservedTagPrefix):
+			errs = append(errs, fmt.Errorf("%w: key %q uses reserved prefix %s", errInvalidTag, tag.Key, reservedTagPrefix))
+		}
+	}
+
+	return errs
+}
+
+func validateHook(name string, hook Hook) error {
+	var errs []error
+
+	_, err := hooks.ParsePolicy(hook.Policy)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s hook: %w", name, err))
+	}
+
+	if hook.Target != "" && strings.TrimSpace(hook.Target) == "" {
+		errs = append(errs, fmt.Errorf("%s hook: %w", name, errBlankHookTarget))
+	}
+
+	if hook.Target != "" && hook.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("%s hook: %w", name, errNonPositiveHookTimeout))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateWatch() error {
+	watches := 0
+
+	for _, set := range []bool{len(c.Watch.Containers) > 0, c.Watch.Process != "", c.Watch.File != ""} {
+		if set {
+			watches++
+		}
+	}
+
+	if watches == 0 {
+		return nil
+	}
+
+	var errs []error
+
+	if watches > 1 {
+		errs = append(errs, fmt.Errorf("%w: set one of %s, %s and %s", errWatchConflict,
+			internal.EnvWatchContainers, internal.EnvWatchProcess, internal.EnvWatchFile))
+	}
+
+	if len(c.Watch.Containers) > 0 && c.Metadata.URI == "" {
+		errs = append(errs, fmt.Errorf("%w: set %s", errWatchWithoutMetadata, internal.MetadataEnvKey))
+	}
+
+	if c.Watch.File != "" && !filepath.IsAbs(c.Watch.File) {
+		errs = append(errs, fmt.Errorf("%w: %q", errRelativeWatchFile, c.Watch.File))
+	}
+
+	if c.Watch.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errNonPositiveInterval, internal.EnvWatchIntervalSeconds))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateReady() error {
+	ready := c.Ready
+
+	var errs []error
+
+	if ready.Policy != ReadyPolicyFail && ready.Policy != ReadyPolicyContinue {
+		errs = append(errs, fmt.Errorf("%w %q: want %s or %s", errInvalidReadyPolicy, ready.Policy,
+			ReadyPolicyFail, ReadyPolicyContinue))
+	}
+
+	if len(ready.Containers) == 0 && ready.File == "" {
+		return errors.Join(errs...)
+	}
+
+	if len(ready.Containers) > 0 && ready.File != "" {
+		errs = append(errs, fmt.Errorf("%w: set %s or %s", errReadyConflict,
+			internal.EnvReadyContainers, internal.EnvReadyFile))
+	}
+
+	if len(ready.Containers) > 0 && c.Metadata.URI == "" {
+		errs = append(errs, fmt.Errorf("%w: set %s", errReadyWithoutMetadata, internal.MetadataEnvKey))
+	}
+
+	if ready.File != "" && !filepath.IsAbs(ready.File) {
+		errs = append(errs, fmt.Errorf("%w: %q", errRelativeReadyFile, ready.File))
+	}
+
+	if ready.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errNonPositiveReadyTimeout, internal.EnvReadyTimeoutSeconds))
+	}
+
+	if c.Watch.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errNonPositiveInterval, internal.EnvWatchIntervalSeconds))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateLazy() error {
+	if !c.Lazy.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if c.Control.Socket == "" && (c.Health.ListenAddr == "" || c.Lazy.Token == "") {
+		errs = append(errs, fmt.Errorf("%w: set %s, or %s and %s", errLazyUnreachable,
+			internal.EnvControlSocket, internal.EnvHealthListenAddr, internal.EnvLazyToken))
+	}
+
+	if c.Lazy.IdleTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errNonPositiveIdleTimeout, internal.EnvLazyIdleSeconds))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateControl() error {
+	if c.Control.Socket == "" || filepath.IsAbs(c.Control.Socket) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q", errInvalidControlSocket, c.Control.Socket)
+}
@@ -8,6 +8,12 @@ const (
 	// DefaultShutdownGraceSeconds defines the default graceful shutdown window.
 	DefaultShutdownGraceSeconds = 15
 
+	// DefaultOnlineTimeoutSeconds bounds how long the wrapper waits for SSM to report the agent Online.
+	DefaultOnlineTimeoutSeconds = 300
+
+	// DefaultAgentPath is the amazon-ssm-agent binary shipped in the image.
+	DefaultAgentPath = "/service/amazon-ssm-agent"
+
 	// RegistrationFilePath is the default location for the SSM registration file.
 	RegistrationFilePath = "/var/lib/amazon/ssm/registration"
 
@@ -17,15 +23,75 @@ const (
 	// EnvManagedInstanceRole identifies the IAM role name for activation.
 	EnvManagedInstanceRole = "MANAGED_INSTANCE_ROLE_NAME"
 
-	// EnvTTLSeconds controls the TTL duration for the service.
+	// EnvConfigFile points at an optional YAML or JSON configuration file.
+	EnvConfigFile = "TTL_CONFIG_FILE"
+
+	// EnvTTLSeconds controls the TTL duration for the service, in seconds or as a Go duration.
 	EnvTTLSeconds = "TTL_SECONDS"
 
+	// EnvTTLDeadline sets an absolute end time (RFC3339 or Unix seconds) instead of a TTL.
+	EnvTTLDeadline = "TTL_DEADLINE"
+
 	// EnvTTLShutdownGraceSeconds controls how long to wait after SIGTERM.
 	EnvTTLShutdownGraceSeconds = "TTL_SHUTDOWN_GRACE_SECONDS"
 
+	// EnvOnlineTimeoutSeconds controls how long to wait for SSM to report the agent Online; zero skips the check.
+	EnvOnlineTimeoutSeconds = "SSM_ONLINE_TIMEOUT_SECONDS"
+
 	// EnvRegistrationFileOverride overrides the default SSM registration path.
 	EnvRegistrationFileOverride = "SSM_REGISTRATION_FILE"
 
+	// EnvTerminationReport names the file the JSON termination report is written to on exit.
+	EnvTerminationReport = "TTL_TERMINATION_REPORT"
+
+	// EnvWatchContainers lists ECS containers of the same task, comma-separated; the wrapper stops the
+	// agent once any of them has stopped.
+	EnvWatchContainers = "TTL_WATCH_CONTAINERS"
+
+	// EnvWatchProcess names a process in another container of the pod, visible through a shared process
+	// namespace; the wrapper stops the agent once it has exited.
+	EnvWatchProcess = "TTL_WATCH_PROCESS"
+
+	// EnvWatchFile names a file, typically in a shared emptyDir, whose creation stops the agent.
+	EnvWatchFile = "TTL_WATCH_FILE"
+
+	// EnvWatchIntervalSeconds controls how often the watched containers are checked.
+	EnvWatchIntervalSeconds = "TTL_WATCH_INTERVAL_SECONDS"
+
+	// DefaultWatchIntervalSeconds is how often the watched containers are checked by default.
+	DefaultWatchIntervalSeconds = 5
+
+	// EnvReadyContainers lists ECS containers of the same task, comma-separated, that must be HEALTHY
+	// before the wrapper creates an activation.
+	EnvReadyContainers = "TTL_READY_CONTAINERS"
+
+	// EnvReadyFile names a file, typically in a shared emptyDir, that must exist before the wrapper
+	// creates an activation.
+	EnvReadyFile = "TTL_READY_FILE"
+
+	// EnvReadyTimeoutSeconds bounds the wait for the ready containers or file.
+	EnvReadyTimeoutSeconds = "TTL_READY_TIMEOUT_SECONDS"
+
+	// EnvReadyPolicy selects what happens when the wait times out: "fail" or "continue".
+	EnvReadyPolicy = "TTL_READY_POLICY"
+
+	// DefaultReadyTimeoutSeconds bounds the wait for readiness by default.
+	DefaultReadyTimeoutSeconds = 300
+
+	// EnvLazy keeps the wrapper dormant until a wake request, instead of registering at startup.
+	EnvLazy = "TTL_LAZY"
+
+	// EnvLazyIdleSeconds controls how long a woken agent may go without a session before the wrapper
+	// deregisters it and goes dormant again.
+	EnvLazyIdleSeconds = "TTL_LAZY_IDLE_SECONDS"
+
+	// EnvLazyToken authenticates wake requests to the health server; without it only the control
+	// socket accepts them.
+	EnvLazyToken = "TTL_LAZY_TOKEN"
+
+	// DefaultLazyIdleSeconds is how long a woken agent may stay idle by default.
+	DefaultLazyIdleSeconds = 900
+
 	// EnvFallbackAvailabilityZone provides the AZ when metadata is unavailable.
 	EnvFallbackAvailabilityZone = "ECS_TASK_AVAILABILITY_ZONE"
 
@@ -44,9 +110,106 @@ const (
 	// EnvAdditionalActivationTags supplies extra activation tags.
 	EnvAdditionalActivationTags = "SSM_ACTIVATION_EXTRA_TAGS"
 
+	// EnvHealthListenAddr enables the HTTP health server on the given address.
+	EnvHealthListenAddr = "HEALTH_LISTEN_ADDR"
+
+	// EnvLogFormat selects the wrapper log format: "text" or "json".
+	EnvLogFormat = "LOG_FORMAT"
+
+	// EnvLogLevel sets the minimum wrapper log level: debug, info, warn or error.
+	EnvLogLevel = "LOG_LEVEL"
+
+	// EnvHookPrefix prefixes hook settings, e.g. HOOK_POST_REGISTRATION holds an executable or webhook URL.
+	EnvHookPrefix = "HOOK_"
+
+	// EnvHookTimeoutSuffix is appended to a hook variable to set its timeout in seconds.
+	EnvHookTimeoutSuffix = "_TIMEOUT_SECONDS"
+
+	// EnvHookPolicySuffix is appended to a hook variable to set fail-open or fail-closed.
+	EnvHookPolicySuffix = "_POLICY"
+
+	// EnvHookWebhookSecret signs webhook payloads with HMAC-SHA256 when set.
+	EnvHookWebhookSecret = "HOOK_WEBHOOK_SECRET"
+
+	// DefaultHookTimeoutSeconds bounds each hook invocation.
+	DefaultHookTimeoutSeconds = 10
+
+	// DefaultAgentConfigDir is where the agent reads amazon-ssm-agent.json and seelog.xml.
+	DefaultAgentConfigDir = "/etc/amazon/ssm"
+
+	// EnvAgentConfigDir overrides where the rendered agent configuration is written.
+	EnvAgentConfigDir = "SSM_AGENT_CONFIG_DIR"
+
+	// EnvAgentCommandWorkersLimit sets Mds.CommandWorkersLimit.
+	EnvAgentCommandWorkersLimit = "SSM_AGENT_COMMAND_WORKERS_LIMIT"
+
+	// EnvAgentCommandRetryLimit sets Mds.CommandRetryLimit.
+	EnvAgentCommandRetryLimit = "SSM_AGENT_COMMAND_RETRY_LIMIT"
+
+	// EnvAgentSessionHandshakeTimeout sets Ssm.SessionHandshakeTimeoutSeconds.
+	EnvAgentSessionHandshakeTimeout = "SSM_AGENT_SESSION_HANDSHAKE_TIMEOUT_SECONDS"
+
+	// EnvAgentSessionLogsDestination sets Ssm.SessionLogsDestination: "none" or "disk".
+	EnvAgentSessionLogsDestination = "SSM_AGENT_SESSION_LOGS_DESTINATION"
+
+	// EnvAgentSessionLogsRetentionHours sets Ssm.SessionLogsRetentionDurationHours.
+	EnvAgentSessionLogsRetentionHours = "SSM_AGENT_SESSION_LOGS_RETENTION_HOURS"
+
+	// EnvAgentRunCommandLogsRetentionHours sets Ssm.RunCommandLogsRetentionDurationHours.
+	EnvAgentRunCommandLogsRetentionHours = "SSM_AGENT_RUN_COMMAND_LOGS_RETENTION_HOURS"
+
+	// EnvAgentAssociationLogsRetentionHours sets Ssm.AssociationLogsRetentionDurationHours.
+	EnvAgentAssociationLogsRetentionHours = "SSM_AGENT_ASSOCIATION_LOGS_RETENTION_HOURS"
+
+	// EnvAgentLogLevel sets the agent seelog minimum level.
+	EnvAgentLogLevel = "SSM_AGENT_LOG_LEVEL"
+
+	// EnvAgentLogOutput selects where the agent logs: "stdout", "file" or "both".
+	EnvAgentLogOutput = "SSM_AGENT_LOG_OUTPUT"
+
+	// EnvControlSocket sets the wrapper control socket path; empty disables it.
+	EnvControlSocket = "TTL_CONTROL_SOCKET"
+
+	// DefaultControlSocket is the default wrapper control socket path.
+	DefaultControlSocket = "/var/lib/amazon/ssm/ipc/ttl-control.sock"
+
 	// FaultInjectionSidecarTagKey identifies FIS sidecar activations.
 	FaultInjectionSidecarTagKey = "FAULT_INJECTION_SIDECAR"
 
 	// FaultInjectionSidecarTagValue is the FIS sidecar activation value.
 	FaultInjectionSidecarTagValue = "true"
+
+	// EnvEndpointSSM overrides the SSM endpoint URL for the wrapper and the agent. The AWS_ENDPOINT_URL_*
+	// names match the SDK's own service-specific endpoint variables.
+	EnvEndpointSSM = "AWS_ENDPOINT_URL_SSM"
+
+	// EnvEndpointSTS overrides the STS endpoint URL.
+	EnvEndpointSTS = "AWS_ENDPOINT_URL_STS"
+
+	// EnvEndpointSecretsManager overrides the Secrets Manager endpoint URL.
+	EnvEndpointSecretsManager = "AWS_ENDPOINT_URL_SECRETS_MANAGER"
+
+	// EnvEndpointSSMMessages overrides the Session Manager message gateway endpoint used by the agent.
+	EnvEndpointSSMMessages = "AWS_ENDPOINT_URL_SSMMESSAGES"
+
+	// EnvEndpointEC2Messages overrides the message delivery endpoint used by the agent.
+	EnvEndpointEC2Messages = "AWS_ENDPOINT_URL_EC2MESSAGES"
+
+	// EnvUseFIPSEndpoint selects FIPS endpoints.
+	EnvUseFIPSEndpoint = "AWS_USE_FIPS_ENDPOINT"
+
+	// EnvUseDualStackEndpoint selects dual-stack (IPv4 and IPv6) endpoints.
+	EnvUseDualStackEndpoint = "AWS_USE_DUALSTACK_ENDPOINT"
+
+	// EnvCABundle points at a PEM bundle trusted in addition to the system roots.
+	EnvCABundle = "AWS_CA_BUNDLE"
+
+	// EnvHTTPSProxy is the proxy URL for HTTPS requests; the lowercase form is also read.
+	EnvHTTPSProxy = "HTTPS_PROXY"
+
+	// EnvHTTPProxy is the proxy URL for plain HTTP requests; the lowercase form is also read.
+	EnvHTTPProxy = "HTTP_PROXY"
+
+	// EnvNoProxy lists hosts, domains and CIDRs reached directly; the lowercase form is also read.
+	EnvNoProxy = "NO_PROXY"
 )
@@ -0,0 +1,119 @@
+// Package containerwatch reports when the containers the agent runs alongside are ready, so the
+// wrapper activates only once they are, and when they have finished, so it can stop with them
+// instead of keeping the task or pod alive.
+package containerwatch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
+)
+
+// Watcher waits for the watched containers to reach a state.
+type Watcher interface {
+	// Wait blocks until the watched containers have reached the state and returns a description,
+	// or until ctx ends.
+	Wait(ctx context.Context) (string, error)
+}
+
+// MetadataFetcher reads the ECS task metadata; metadata.Provider satisfies it.
+type MetadataFetcher interface {
+	FetchTaskMetadata(ctx context.Context, baseURI string) (metadata.TaskMetadata, error)
+}
+
+// ECS watches containers of the same task through the ECS task metadata endpoint.
+type ECS struct {
+	fetcher    MetadataFetcher
+	baseURI    string
+	containers []string
+	interval   time.Duration
+	clock      clock.Clock
+}
+
+// NewECS returns a watcher that polls the task metadata at baseURI every interval until one of
+// the named containers has stopped.
+func NewECS(fetcher MetadataFetcher, baseURI string, containers []string, interval time.Duration,
+	c clock.Clock,
+) *ECS {
+	return &ECS{
+		fetcher:    fetcher,
+		baseURI:    baseURI,
+		containers: containers,
+		interval:   interval,
+		clock:      c,
+	}
+}
+
+// Wait polls until one of the containers has stopped. Metadata that cannot be read is logged and
+// polled again; a container missing from the task is reported once, in case the name is wrong.
+func (w *ECS) Wait(ctx context.Context) (string, error) {
+	missing := map[string]bool{}
+
+	return poll(ctx, w.clock, w.interval, func(ctx context.Context) (string, bool) {
+		return w.check(ctx, missing)
+	})
+}
+
+func (w *ECS) check(parent context.Context, missing map[string]bool) (string, bool) {
+	ctx, cancel := context.WithTimeout(parent, w.interval)
+	defer cancel()
+
+	meta, err := w.fetcher.FetchTaskMetadata(ctx, w.baseURI)
+	if err != nil {
+		if parent.Err() == nil {
+			slog.Warn("unable to read task metadata for watched containers", logging.Err(err))
+		}
+
+		return "", false
+	}
+
+	for _, name := range w.containers {
+		i := slices.IndexFunc(meta.Containers, func(c metadata.Container) bool { return c.Name == name })
+		if i < 0 {
+			reportOnce(missing, name, "watched container not found in task metadata")
+
+			continue
+		}
+
+		if container := meta.Containers[i]; container.KnownStatus == metadata.ContainerStatusStopped {
+			return stoppedReason(container), true
+		}
+	}
+
+	return "", false
+}
+
+// poll calls check now and then every interval until it reports a reason or ctx ends.
+func poll(ctx context.Context, c clock.Clock, interval time.Duration,
+	check func(ctx context.Context) (string, bool),
+) (string, error) {
+	ticker := c.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reason, ok := check(ctx)
+		if ok {
+			return reason, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("watch containers: %w", ctx.Err())
+		case <-ticker.C():
+		}
+	}
+}
+
+func stoppedReason(container metadata.Container) string {
+	if container.ExitCode == nil {
+		return "container " + container.Name + " stopped"
+	}
+
+	return fmt.Sprintf("container %s stopped with exit code %d", container.Name, *container.ExitCode)
+}
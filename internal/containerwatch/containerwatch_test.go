@@ -0,0 +1,107 @@
+package containerwatch_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clocktest"
+	"github.com/benwsapp/aws-ssm-minimal/internal/containerwatch"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
+)
+
+var errUnavailable = errors.New("metadata unavailable")
+
+// response is one scripted reply from the metadata endpoint.
+type response struct {
+	containers []metadata.Container
+	err        error
+}
+
+// fetcher replies with its responses in order, then repeats the last one, and reports each read
+// on polled.
+type fetcher struct {
+	responses []response
+	polled    chan struct{}
+}
+
+func (f *fetcher) FetchTaskMetadata(context.Context, string) (metadata.TaskMetadata, error) {
+	next := f.responses[0]
+	if len(f.responses) > 1 {
+		f.responses = f.responses[1:]
+	}
+
+	f.polled <- struct{}{}
+
+	return metadata.TaskMetadata{AvailabilityZone: "", TaskARN: "", Containers: next.containers}, next.err
+}
+
+func container(name, status string, exitCode *int) metadata.Container {
+	return metadata.Container{Name: name, KnownStatus: status, ExitCode: exitCode, Health: nil}
+}
+
+func TestECSWaitsForWatchedContainer(t *testing.T) {
+	t.Parallel()
+
+	exitCode := 3
+	source := &fetcher{
+		responses: []response{
+			{containers: nil, err: errUnavailable},
+			{containers: nil, err: nil},
+			{containers: []metadata.Container{
+				container("app", "RUNNING", nil),
+				container("sidecar", metadata.ContainerStatusStopped, nil),
+			}, err: nil},
+			{containers: []metadata.Container{container("app", metadata.ContainerStatusStopped, &exitCode)}, err: nil},
+		},
+		polled: make(chan struct{}),
+	}
+
+	clock := clocktest.NewFake(time.Now())
+	watcher := containerwatch.NewECS(source, "http://metadata", []string{"app"}, time.Second, clock)
+
+	type result struct {
+		reason string
+		err    error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		reason, err := watcher.Wait(t.Context())
+		done <- result{reason: reason, err: err}
+	}()
+
+	for range 3 {
+		<-source.polled
+		clock.Advance(time.Second)
+	}
+
+	<-source.polled
+
+	got := <-done
+	if got.err != nil || got.reason != "container app stopped with exit code 3" {
+		t.Fatalf("Wait = %q, %v; want the app container's exit", got.reason, got.err)
+	}
+}
+
+func TestECSWaitEndsWithContext(t *testing.T) {
+	t.Parallel()
+
+	source := &fetcher{
+		responses: []response{{containers: []metadata.Container{container("app", "RUNNING", nil)}, err: nil}},
+		polled:    make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	watcher := containerwatch.NewECS(source, "http://metadata", []string{"app"}, time.Second,
+		clocktest.NewFake(time.Now()))
+
+	_, err := watcher.Wait(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v, want context.Canceled", err)
+	}
+}
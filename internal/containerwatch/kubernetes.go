@@ -0,0 +1,124 @@
+package containerwatch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	// commLength is how much of a process name the kernel keeps in /proc/<pid>/comm.
+	commLength = 15
+	// fileReasonLimit bounds how much of a watched file is quoted in the reason.
+	fileReasonLimit = 256
+)
+
+// Process watches for a process in another container of the pod to exit. The pod must share its
+// process namespace (shareProcessNamespace: true) for the process to be visible.
+type Process struct {
+	procRoot string
+	name     string
+	interval time.Duration
+	clock    clock.Clock
+}
+
+// NewProcess returns a watcher that scans procRoot every interval for processes named name.
+func NewProcess(procRoot, name string, interval time.Duration, c clock.Clock) *Process {
+	return &Process{procRoot: procRoot, name: name, interval: interval, clock: c}
+}
+
+// Wait polls until a process named name has been seen and none is left. A main container that
+// has not started yet is waited for rather than taken as finished.
+func (w *Process) Wait(ctx context.Context) (string, error) {
+	seen := false
+
+	return poll(ctx, w.clock, w.interval, func(context.Context) (string, bool) {
+		running := w.running()
+		if running && !seen {
+			seen = true
+
+			slog.Info("watching process", slog.String("process", w.name))
+		}
+
+		return "process " + w.name + " exited", seen && !running
+	})
+}
+
+// running reports whether any process under procRoot is named name, comparing as much of the
+// name as the kernel keeps.
+func (w *Process) running() bool {
+	entries, err := os.ReadDir(w.procRoot)
+	if err != nil {
+		return false
+	}
+
+	want := w.name[:min(len(w.name), commLength)]
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.Trim(entry.Name(), "0123456789") != "" {
+			continue
+		}
+
+		comm, readErr := os.ReadFile(filepath.Join(w.procRoot, entry.Name(), "comm")) // #nosec G304 -- procfs path
+		if readErr == nil && strings.TrimSpace(string(comm)) == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// File watches for a file another container creates when it finishes or becomes ready, typically
+// in a shared emptyDir volume.
+type File struct {
+	path     string
+	interval time.Duration
+	clock    clock.Clock
+}
+
+// NewFile returns a watcher that checks for path every interval.
+func NewFile(path string, interval time.Duration, c clock.Clock) *File {
+	return &File{path: path, interval: interval, clock: c}
+}
+
+// Wait polls until the file exists. Its first line, if any, is quoted in the reason, so the other
+// container can say how it ended.
+func (w *File) Wait(ctx context.Context) (string, error) {
+	return poll(ctx, w.clock, w.interval, func(context.Context) (string, bool) {
+		file, err := os.Open(w.path) // #nosec G304 -- path configured by the operator
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("unable to read watched file", slog.String("path", w.path), logging.Err(err))
+			}
+
+			return "", false
+		}
+
+		defer func() {
+			closeErr := file.Close()
+			if closeErr != nil {
+				slog.Warn("failed to close watched file", logging.Err(closeErr))
+			}
+		}()
+
+		reason := "file " + w.path + " created"
+
+		scanner := bufio.NewScanner(file)
+		if scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				reason += ": " + line[:min(len(line), fileReasonLimit)]
+			}
+		}
+
+		return reason, true
+	})
+}
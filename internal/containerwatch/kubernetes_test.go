@@ -0,0 +1,107 @@
+package containerwatch_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clocktest"
+	"github.com/benwsapp/aws-ssm-minimal/internal/containerwatch"
+)
+
+// waitResult runs wait in the background and delivers its reason.
+func waitResult(t *testing.T, watcher containerwatch.Watcher) <-chan string {
+	t.Helper()
+
+	done := make(chan string, 1)
+
+	go func() {
+		reason, err := watcher.Wait(t.Context())
+		if err != nil {
+			t.Errorf("Wait: %v", err)
+		}
+
+		done <- reason
+	}()
+
+	return done
+}
+
+// tickUntil advances clock one interval at a time until done delivers.
+func tickUntil(t *testing.T, clock *clocktest.Fake, done <-chan string) string {
+	t.Helper()
+
+	for range 100 {
+		select {
+		case reason := <-done:
+			return reason
+		case <-time.After(10 * time.Millisecond):
+			clock.Advance(time.Second)
+		}
+	}
+
+	t.Fatal("watcher never reported")
+
+	return ""
+}
+
+func writeProcess(t *testing.T, procRoot, pid, comm string) {
+	t.Helper()
+
+	dir := filepath.Join(procRoot, pid)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessWaitsForExit(t *testing.T) {
+	t.Parallel()
+
+	procRoot := t.TempDir()
+	writeProcess(t, procRoot, "1", "pause")
+
+	clock := clocktest.NewFake(time.Now())
+	done := waitResult(t, containerwatch.NewProcess(procRoot, "long-running-job", time.Second, clock))
+
+	// Not started yet: the watcher keeps waiting.
+	clock.Advance(time.Second)
+
+	select {
+	case reason := <-done:
+		t.Fatalf("Wait = %q before the process started", reason)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writeProcess(t, procRoot, "42", "long-running-jo")
+	clock.Advance(time.Second)
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.RemoveAll(filepath.Join(procRoot, "42")); err != nil {
+		t.Fatal(err)
+	}
+
+	if reason := tickUntil(t, clock, done); reason != "process long-running-job exited" {
+		t.Errorf("reason = %q", reason)
+	}
+}
+
+func TestFileWaitsForCreation(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "done")
+	clock := clocktest.NewFake(time.Now())
+	done := waitResult(t, containerwatch.NewFile(path, time.Second, clock))
+
+	if err := os.WriteFile(path, []byte("exit 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if reason := tickUntil(t, clock, done); reason != "file "+path+" created: exit 0" {
+		t.Errorf("reason = %q", reason)
+	}
+}
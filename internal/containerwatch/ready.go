@@ -0,0 +1,107 @@
+package containerwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
+)
+
+// ErrStopped reports that a container stopped before it became healthy.
+var ErrStopped = errors.New("container stopped before becoming healthy")
+
+// ECSHealthy waits for containers of the same task to pass their health checks.
+type ECSHealthy struct {
+	fetcher    MetadataFetcher
+	baseURI    string
+	containers []string
+	interval   time.Duration
+	clock      clock.Clock
+}
+
+// NewECSHealthy returns a watcher that polls the task metadata at baseURI every interval until
+// all of the named containers are healthy.
+func NewECSHealthy(fetcher MetadataFetcher, baseURI string, containers []string, interval time.Duration,
+	c clock.Clock,
+) *ECSHealthy {
+	return &ECSHealthy{
+		fetcher:    fetcher,
+		baseURI:    baseURI,
+		containers: containers,
+		interval:   interval,
+		clock:      c,
+	}
+}
+
+// Wait polls until every container is HEALTHY and fails with ErrStopped if one stops first.
+// Metadata that cannot be read is logged and polled again; a container missing from the task or
+// without a health check is reported once, since it will never become healthy.
+func (w *ECSHealthy) Wait(ctx context.Context) (string, error) {
+	reported := map[string]bool{}
+
+	var stopped error
+
+	reason, err := poll(ctx, w.clock, w.interval, func(parent context.Context) (string, bool) {
+		ctx, cancel := context.WithTimeout(parent, w.interval)
+		defer cancel()
+
+		meta, err := w.fetcher.FetchTaskMetadata(ctx, w.baseURI)
+		if err != nil {
+			if parent.Err() == nil {
+				slog.Warn("unable to read task metadata for container health", logging.Err(err))
+			}
+
+			return "", false
+		}
+
+		healthy := true
+
+		for _, name := range w.containers {
+			i := slices.IndexFunc(meta.Containers, func(c metadata.Container) bool { return c.Name == name })
+			if i < 0 {
+				reportOnce(reported, name, "awaited container not found in task metadata")
+
+				healthy = false
+
+				continue
+			}
+
+			switch container := meta.Containers[i]; {
+			case container.KnownStatus == metadata.ContainerStatusStopped:
+				stopped = fmt.Errorf("%w: %s", ErrStopped, stoppedReason(container))
+
+				return "", true
+			case container.Health == nil:
+				reportOnce(reported, name, "awaited container has no health check")
+
+				healthy = false
+			case container.Health.Status != metadata.HealthStatusHealthy:
+				healthy = false
+			}
+		}
+
+		return "containers " + strings.Join(w.containers, ", ") + " healthy", healthy
+	})
+	if stopped != nil {
+		return "", stopped
+	}
+
+	return reason, err
+}
+
+func reportOnce(reported map[string]bool, name, message string) {
+	if reported[name] {
+		return
+	}
+
+	reported[name] = true
+
+	slog.Warn(message, slog.String("container", name))
+}
@@ -0,0 +1,90 @@
+package containerwatch_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clocktest"
+	"github.com/benwsapp/aws-ssm-minimal/internal/containerwatch"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
+)
+
+func withHealth(c metadata.Container, status string) metadata.Container {
+	c.Health = &metadata.ContainerHealth{Status: status}
+
+	return c
+}
+
+func TestECSHealthy(t *testing.T) {
+	t.Parallel()
+
+	exitCode := 1
+	app := container("app", "RUNNING", nil)
+	db := container("db", "RUNNING", nil)
+
+	tests := []struct {
+		name      string
+		responses []response
+		reason    string
+		err       error
+	}{
+		{
+			name: "every container healthy",
+			responses: []response{
+				{containers: nil, err: errUnavailable},
+				{containers: []metadata.Container{withHealth(app, "UNKNOWN"), db}, err: nil},
+				{containers: []metadata.Container{withHealth(app, "HEALTHY"), withHealth(db, "UNHEALTHY")}, err: nil},
+				{containers: []metadata.Container{withHealth(app, "HEALTHY"), withHealth(db, "HEALTHY")}, err: nil},
+			},
+			reason: "containers app, db healthy",
+			err:    nil,
+		},
+		{
+			name: "container stops first",
+			responses: []response{
+				{containers: []metadata.Container{withHealth(app, "UNKNOWN"), withHealth(db, "HEALTHY")}, err: nil},
+				{containers: []metadata.Container{
+					withHealth(container("app", metadata.ContainerStatusStopped, &exitCode), "UNHEALTHY"),
+					withHealth(db, "HEALTHY"),
+				}, err: nil},
+			},
+			reason: "",
+			err:    containerwatch.ErrStopped,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := &fetcher{responses: test.responses, polled: make(chan struct{})}
+			clock := clocktest.NewFake(time.Now())
+			watcher := containerwatch.NewECSHealthy(source, "http://metadata", []string{"app", "db"}, time.Second, clock)
+
+			type result struct {
+				reason string
+				err    error
+			}
+
+			done := make(chan result, 1)
+
+			go func() {
+				reason, err := watcher.Wait(t.Context())
+				done <- result{reason: reason, err: err}
+			}()
+
+			for range len(test.responses) - 1 {
+				<-source.polled
+				clock.Advance(time.Second)
+			}
+
+			<-source.polled
+
+			got := <-done
+			if got.reason != test.reason || !errors.Is(got.err, test.err) {
+				t.Fatalf("Wait = %q, %v; want %q, %v", got.reason, got.err, test.reason, test.err)
+			}
+		})
+	}
+}
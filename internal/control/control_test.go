@@ -0,0 +1,174 @@
+package control_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/control"
+)
+
+var (
+	errRejected     = errors.New("logOutput \"xml\": want stdout, file or both")
+	errDeadlinePast = errors.New("deadline passed")
+)
+
+// startServer serves the logging and wake handlers on a socket in a fresh directory.
+func startServer(t *testing.T, apply control.LoggingFunc, wake control.WakeFunc) string {
+	t.Helper()
+
+	// Unix socket paths are limited to about 100 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "ttl.sock")
+	server := control.NewServer(path)
+	server.Handle(control.AgentLoggingPath, control.LoggingHandler(apply))
+	server.Handle(control.WakePath, control.WakeHandler(wake))
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = server.Shutdown(t.Context()) })
+
+	return path
+}
+
+func TestSetAgentLogging(t *testing.T) {
+	t.Parallel()
+
+	var got control.LoggingRequest
+
+	path := startServer(t, func(req control.LoggingRequest) (control.LoggingResponse, error) {
+		if req.Output == "xml" {
+			return control.LoggingResponse{}, errRejected
+		}
+
+		got = req
+
+		return control.LoggingResponse{Level: req.Level, Output: "stdout", Files: []string{"seelog.xml"},
+			Restarted: req.Restart}, nil
+	}, func() (control.WakeResponse, error) { return control.WakeResponse{Phase: "running"}, nil })
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket mode = %v, want 0600", perm)
+	}
+
+	resp, err := control.SetAgentLogging(t.Context(), path, control.LoggingRequest{Level: "debug", Restart: true})
+	if err != nil {
+		t.Fatalf("SetAgentLogging: %v", err)
+	}
+
+	if got.Level != "debug" || !got.Restart || resp.Level != "debug" || !resp.Restarted {
+		t.Errorf("request %+v, response %+v; want the level and restart passed through", got, resp)
+	}
+
+	_, err = control.SetAgentLogging(t.Context(), path, control.LoggingRequest{Output: "xml"})
+	if err == nil || !strings.Contains(err.Error(), "status 400") || !strings.Contains(err.Error(), "want stdout, file or both") {
+		t.Errorf("SetAgentLogging with a rejected output = %v, want the 400 and the reason", err)
+	}
+
+	wake, err := control.Wake(t.Context(), path)
+	if err != nil || wake.Phase != "running" {
+		t.Errorf("Wake = %+v, %v; want phase running", wake, err)
+	}
+}
+
+func TestWakeRejected(t *testing.T) {
+	t.Parallel()
+
+	path := startServer(t, func(control.LoggingRequest) (control.LoggingResponse, error) {
+		return control.LoggingResponse{}, nil
+	}, func() (control.WakeResponse, error) { return control.WakeResponse{}, errDeadlinePast })
+
+	_, err := control.Wake(t.Context(), path)
+	if err == nil || !strings.Contains(err.Error(), "status 409") || !strings.Contains(err.Error(), "deadline passed") {
+		t.Errorf("Wake = %v, want the 409 and the reason", err)
+	}
+}
+
+func TestLoggingHandlerRejectsBadRequests(t *testing.T) {
+	t.Parallel()
+
+	handler := control.LoggingHandler(func(control.LoggingRequest) (control.LoggingResponse, error) {
+		t.Error("apply called for a bad request")
+
+		return control.LoggingResponse{}, nil
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{name: "get", method: http.MethodGet, body: "", want: http.StatusMethodNotAllowed},
+		{name: "unknown field", method: http.MethodPost, body: `{"colour":"red"}`, want: http.StatusBadRequest},
+		{name: "not json", method: http.MethodPost, body: "level=debug", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), tt.method, control.AgentLoggingPath,
+				strings.NewReader(tt.body)))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	t.Parallel()
+
+	handler := control.RequireToken("s3cret", control.WakeHandler(func() (control.WakeResponse, error) {
+		return control.WakeResponse{Phase: "dormant"}, nil
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "missing", header: "", want: http.StatusUnauthorized},
+		{name: "wrong", header: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "not bearer", header: "s3cret", want: http.StatusUnauthorized},
+		{name: "valid", header: "Bearer s3cret", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, control.WakePath, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	bodyLimit      = 64 << 10
+	// socketHost is a placeholder; requests are always dialed over the Unix socket.
+	socketHost = "http://ttl"
+)
+
+var errRequestFailed = errors.New("control request failed")
+
+// LoggingRequest changes the agent log settings; empty fields keep their current value.
+type LoggingRequest struct {
+	Level   string `json:"level,omitempty"`
+	Output  string `json:"output,omitempty"`
+	Restart bool   `json:"restart,omitempty"`
+}
+
+// LoggingResponse reports the settings in effect after a LoggingRequest.
+type LoggingResponse struct {
+	Level     string   `json:"level"`
+	Output    string   `json:"output"`
+	Files     []string `json:"files"`
+	Restarted bool     `json:"restarted"`
+}
+
+// LoggingFunc applies a logging request.
+type LoggingFunc func(LoggingRequest) (LoggingResponse, error)
+
+// LoggingHandler serves AgentLoggingPath by decoding the request and calling apply. Errors from
+// apply are reported to the client as 400 responses.
+func LoggingHandler(apply LoggingFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		var req LoggingRequest
+
+		decoder := json.NewDecoder(io.LimitReader(r.Body, bodyLimit))
+		decoder.DisallowUnknownFields()
+
+		err := decoder.Decode(&req)
+		if err != nil {
+			http.Error(w, "decode request: "+err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		resp, err := apply(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encodeErr := json.NewEncoder(w).Encode(resp)
+		if encodeErr != nil {
+			slog.Warn("failed to encode control response", logging.Err(encodeErr))
+		}
+	})
+}
+
+// SetAgentLogging sends req to the wrapper listening on socketPath.
+func SetAgentLogging(ctx context.Context, socketPath string, req LoggingRequest) (LoggingResponse, error) {
+	var out LoggingResponse
+
+	err := post(ctx, socketPath, AgentLoggingPath, req, &out)
+	if err != nil {
+		return LoggingResponse{}, err
+	}
+
+	return out, nil
+}
+
+// post sends req as JSON to path on the wrapper listening on socketPath and decodes the response
+// into out.
+func post(parent context.Context, socketPath, path string, req, out any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal control request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, requestTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, socketHost+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build control request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := newClient(socketPath).Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", socketPath, err)
+	}
+
+	defer func() {
+		closeErr := resp.Body.Close()
+		if closeErr != nil {
+			slog.Warn("failed to close control response body", logging.Err(closeErr))
+		}
+	}()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, bodyLimit))
+	if err != nil {
+		return fmt.Errorf("read control response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d: %s", errRequestFailed, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	err = json.Unmarshal(respBody, out)
+	if err != nil {
+		return fmt.Errorf("decode control response: %w", err)
+	}
+
+	return nil
+}
+
+func newClient(socketPath string) *http.Client {
+	dialer := net.Dialer{}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
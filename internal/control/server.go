@@ -0,0 +1,100 @@
+// Package control serves wrapper control requests on a local Unix socket.
+//
+// The socket is created mode 0600, so only the container user can reach it, typically through
+// ECS Exec or kubectl exec running "ttl" subcommands.
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	// AgentLoggingPath rewrites the agent seelog configuration.
+	AgentLoggingPath = "/v1/agent/logging"
+
+	readHeaderTimeout = 5 * time.Second
+	socketPerm        = 0o600
+)
+
+// Server exposes control handlers over a Unix socket.
+type Server struct {
+	path     string
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewServer constructs a Server that listens on the socket at path once started.
+func NewServer(path string) *Server {
+	mux := http.NewServeMux()
+
+	return &Server{
+		path:     path,
+		mux:      mux,
+		listener: nil,
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+// Handle registers a control handler.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start replaces any stale socket, binds the listener and serves requests in the background.
+func (s *Server) Start() error {
+	err := os.Remove(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale control socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", s.path, err)
+	}
+
+	err = os.Chmod(s.path, socketPerm)
+	if err != nil {
+		_ = listener.Close()
+
+		return fmt.Errorf("restrict control socket: %w", err)
+	}
+
+	s.listener = listener
+	slog.Info("control socket listening", slog.String("path", s.path))
+
+	go func() {
+		serveErr := s.server.Serve(listener)
+		if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+			slog.Warn("control server stopped", logging.Err(serveErr))
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stops the server, waiting for in-flight requests until ctx expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.listener == nil {
+		return nil
+	}
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		return fmt.Errorf("shutdown control server: %w", err)
+	}
+
+	return nil
+}
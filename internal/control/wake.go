@@ -0,0 +1,79 @@
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+// WakePath starts activation, registration and the agent on a dormant wrapper.
+const WakePath = "/v1/wake"
+
+const bearerPrefix = "Bearer "
+
+// WakeResponse reports the lifecycle phase the wrapper was in when the wake request arrived.
+type WakeResponse struct {
+	Phase string `json:"phase"`
+}
+
+// WakeFunc wakes the wrapper, or fails when it can no longer be woken.
+type WakeFunc func() (WakeResponse, error)
+
+// WakeHandler serves WakePath by calling wake. Waking an awake wrapper only counts as activity.
+// Errors from wake are reported to the client as 409 responses.
+func WakeHandler(wake WakeFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		resp, err := wake()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encodeErr := json.NewEncoder(w).Encode(resp)
+		if encodeErr != nil {
+			slog.Warn("failed to encode control response", logging.Err(encodeErr))
+		}
+	})
+}
+
+// RequireToken rejects requests to next that do not carry "Authorization: Bearer <token>". It
+// guards control handlers served over TCP, where the socket's file mode does not apply.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			slog.Warn("rejected unauthenticated control request", slog.String("path", r.URL.Path))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Wake asks the wrapper listening on socketPath to wake.
+func Wake(ctx context.Context, socketPath string) (WakeResponse, error) {
+	var out WakeResponse
+
+	err := post(ctx, socketPath, WakePath, struct{}{}, &out)
+	if err != nil {
+		return WakeResponse{}, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,383 @@
+// Package doctor runs preflight checks against the environment the wrapper is about to start in.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/benwsapp/aws-ssm-minimal/internal/awsconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+)
+
+const (
+	// ImageUID is the user the container image runs the wrapper and agent as.
+	ImageUID = 65533
+
+	checkTimeout   = 10 * time.Second
+	dialTimeout    = 5 * time.Second
+	httpsPort      = "443"
+	httpPort       = "80"
+	socksPort      = "1080"
+	executableBits = 0o111
+	probeFilePerm  = 0o600
+)
+
+var (
+	errNotExecutable = errors.New("not executable")
+	errNoRegion      = errors.New("skipped: region unresolved")
+	errNoIdentity    = errors.New("skipped: caller identity unknown")
+)
+
+// Check is the outcome of a single preflight check.
+type Check struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// Report is the ordered list of checks that were run.
+type Report []Check
+
+// Passed reports whether every check passed.
+func (r Report) Passed() bool {
+	for _, check := range r {
+		if !check.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Write prints one PASS or FAIL line per check.
+func (r Report) Write(w io.Writer) error {
+	for _, check := range r {
+		status := "PASS"
+		if !check.Passed {
+			status = "FAIL"
+		}
+
+		_, err := fmt.Fprintf(w, "%s  %-40s %s\n", status, check.Name, check.Detail)
+		if err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Options selects what the checks inspect.
+type Options struct {
+	Config     config.Config
+	AgentPath  string
+	StatePaths []string
+}
+
+// Run executes every check and returns the report. AWS checks that depend on an earlier failure
+// are reported as failed with the reason they were skipped.
+func Run(ctx context.Context, opts Options) Report {
+	var report Report
+
+	add := func(name, detail string, err error) {
+		if err != nil {
+			detail = err.Error()
+		}
+
+		report = append(report, Check{Name: name, Passed: err == nil, Detail: detail})
+	}
+
+	execCtx, err := discoverRegion(ctx, opts.Config.Metadata)
+	add("region", fmt.Sprintf("%s (source %s)", execCtx.Region, execCtx.RegionSource), err)
+
+	px, pxErr := proxy.New(opts.Config.Proxy)
+	if pxErr != nil {
+		px, _ = proxy.New(config.Proxy{HTTPS: "", HTTP: "", NoProxy: ""})
+	}
+
+	add("proxy", "https "+px.Describe().HTTPS, pxErr)
+
+	principal, credErr := checkCredentials(ctx, execCtx.Region, opts.Config.AWS, px, add)
+	checkIAM(ctx, execCtx.Region, principal, opts.Config, px, credErr, add)
+
+	for _, path := range opts.StatePaths {
+		add("write "+path, fmt.Sprintf("writable as uid %d", os.Getuid()), checkWritable(path))
+	}
+
+	add("agent binary", opts.AgentPath, checkExecutable(opts.AgentPath))
+
+	for _, addr := range endpointAddrs(execCtx.Region, opts.Config.AWS) {
+		detail, reachErr := checkReachable(ctx, px, addr, err)
+		add("reach "+addr, detail, reachErr)
+	}
+
+	return report
+}
+
+func discoverRegion(parent context.Context, settings config.Metadata) (execution.Context, error) {
+	ctx, cancel := context.WithTimeout(parent, checkTimeout)
+	defer cancel()
+
+	execCtx, err := execution.NewProvider(metadata.NewProvider(nil), settings).Discover(ctx)
+	if err != nil {
+		return execution.Context{}, fmt.Errorf("resolve region: %w", err)
+	}
+
+	return execCtx, nil
+}
+
+// checkCredentials reports the credential source and caller identity, returning the caller ARN.
+func checkCredentials(
+	parent context.Context, region string, settings config.AWS, px *proxy.Proxy, add func(string, string, error),
+) (string, error) {
+	if region == "" {
+		add("credentials", "", errNoRegion)
+		add("caller identity", "", errNoRegion)
+
+		return "", errNoRegion
+	}
+
+	ctx, cancel := context.WithTimeout(parent, checkTimeout)
+	defer cancel()
+
+	cfg, err := awsconfig.Load(ctx, region, settings, px)
+	if err != nil {
+		add("credentials", "", err)
+		add("caller identity", "", errNoIdentity)
+
+		return "", err
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		err = fmt.Errorf("retrieve credentials: %w", err)
+	}
+
+	add("credentials", "source "+creds.Source, err)
+
+	identity, err := awsconfig.STSClient(cfg, settings).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		err = fmt.Errorf("sts:GetCallerIdentity: %w", err)
+		add("caller identity", "", err)
+
+		return "", err
+	}
+
+	arn := aws.ToString(identity.Arn)
+	add("caller identity", arn+" (account "+aws.ToString(identity.Account)+")", nil)
+
+	return arn, nil
+}
+
+func checkIAM(
+	parent context.Context, region, principal string, settings config.Config, px *proxy.Proxy, credErr error,
+	add func(string, string, error),
+) {
+	roleName := settings.ManagedInstanceRole
+
+	names := []string{"simulate ssm:CreateActivation", "simulate iam:PassRole", "role trust policy"}
+
+	// The online wait and /readyz poll the instance's ping status.
+	describe := settings.OnlineTimeout > 0 || settings.Health.ListenAddr != ""
+	if describe {
+		names = append(names, "simulate "+actionDescribeInstance)
+	}
+
+	if credErr != nil {
+		for _, name := range names {
+			add(name, "", errNoIdentity)
+		}
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, checkTimeout)
+	defer cancel()
+
+	cfg, err := awsconfig.Load(ctx, region, settings.AWS, px)
+	if err != nil {
+		for _, name := range names {
+			add(name, "", err)
+		}
+
+		return
+	}
+
+	client := iam.NewFromConfig(cfg)
+
+	role, roleErr := getRole(ctx, client, roleName)
+
+	add(names[0], "allowed", simulate(ctx, client, principal, actionCreateActivation, "*"))
+
+	if describe {
+		add(names[3], "allowed", simulate(ctx, client, principal, actionDescribeInstance, "*"))
+	}
+
+	if roleErr != nil {
+		add(names[1], "", roleErr)
+		add(names[2], "", roleErr)
+
+		return
+	}
+
+	add(names[1], "allowed on "+aws.ToString(role.Arn), simulate(ctx, client, principal, actionPassRole, aws.ToString(role.Arn)))
+	add(names[2], "trusts "+ssmServicePrincipal, checkTrustPolicy(aws.ToString(role.AssumeRolePolicyDocument)))
+}
+
+// checkWritable creates and removes a probe file, which is the only reliable test for the
+// effective uid, supplementary groups and read-only mounts together.
+func checkWritable(dir string) error {
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		if os.Getuid() != ImageUID {
+			return fmt.Errorf("running as uid %d, image runs as %d: %w", os.Getuid(), ImageUID, err)
+		}
+
+		return fmt.Errorf("create file: %w", err)
+	}
+
+	name := probe.Name()
+
+	err = errors.Join(probe.Chmod(probeFilePerm), probe.Close(), os.Remove(name))
+	if err != nil {
+		return fmt.Errorf("probe %s: %w", filepath.Base(name), err)
+	}
+
+	return nil
+}
+
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat agent: %w", err)
+	}
+
+	if info.IsDir() || info.Mode().Perm()&executableBits == 0 {
+		return fmt.Errorf("%w: %s (mode %s)", errNotExecutable, path, info.Mode())
+	}
+
+	return nil
+}
+
+// endpointAddrs lists the host:port of each endpoint the wrapper and agent connect to, honoring
+// endpoint overrides and the FIPS and dual-stack toggles.
+func endpointAddrs(region string, settings config.AWS) []string {
+	if region == "" {
+		region = "<region>"
+	}
+
+	services := []struct {
+		name     string
+		override string
+	}{
+		{"ssm", settings.Endpoints.SSM},
+		{"ssmmessages", settings.Endpoints.SSMMessages},
+		{"ec2messages", settings.Endpoints.EC2Messages},
+	}
+
+	addrs := make([]string, 0, len(services))
+
+	for _, service := range services {
+		addrs = append(addrs, endpointAddr(service.name, region, service.override, settings))
+	}
+
+	return addrs
+}
+
+func endpointAddr(service, region, override string, settings config.AWS) string {
+	if parsed, err := url.Parse(override); err == nil && parsed.Host != "" {
+		port := parsed.Port()
+		if port == "" {
+			port = httpsPort
+			if parsed.Scheme == "http" {
+				port = httpPort
+			}
+		}
+
+		return net.JoinHostPort(parsed.Hostname(), port)
+	}
+
+	if settings.UseFIPS {
+		service += "-fips"
+	}
+
+	domain := "amazonaws.com"
+	if settings.UseDualStack {
+		domain = "api.aws"
+	}
+
+	return net.JoinHostPort(service+"."+region+"."+domain, httpsPort)
+}
+
+// checkReachable opens a TCP connection to addr, or to the proxy that px would use for it.
+func checkReachable(parent context.Context, px *proxy.Proxy, addr string, regionErr error) (string, error) {
+	if regionErr != nil {
+		return "", errNoRegion
+	}
+
+	ctx, cancel := context.WithTimeout(parent, dialTimeout)
+	defer cancel()
+
+	dialAddr := addr
+	detail := "tcp reachable"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodConnect, "https://"+addr, nil)
+	if err != nil {
+		return "", fmt.Errorf("build request for %s: %w", addr, err)
+	}
+
+	via, err := px.Func(req)
+	if err != nil {
+		return "", fmt.Errorf("resolve proxy for %s: %w", addr, err)
+	}
+
+	if via != nil {
+		dialAddr = proxyAddr(via)
+		detail = "tcp reachable via proxy " + via.Redacted()
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", dialAddr)
+	if err != nil {
+		return "", fmt.Errorf("dial %s: %w", dialAddr, err)
+	}
+
+	closeErr := conn.Close()
+	if closeErr != nil {
+		return "", fmt.Errorf("close %s: %w", dialAddr, closeErr)
+	}
+
+	return detail, nil
+}
+
+// proxyAddr returns the host:port of a proxy URL, defaulting the port from its scheme.
+func proxyAddr(via *url.URL) string {
+	if via.Port() != "" {
+		return via.Host
+	}
+
+	port := httpPort
+
+	switch via.Scheme {
+	case "https":
+		port = httpsPort
+	case "socks5":
+		port = socksPort
+	}
+
+	return net.JoinHostPort(via.Hostname(), port)
+}
@@ -0,0 +1,152 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+const (
+	actionCreateActivation = "ssm:CreateActivation"
+	actionDescribeInstance = "ssm:DescribeInstanceInformation"
+	actionPassRole         = "iam:PassRole"
+	actionAssumeRole       = "sts:AssumeRole"
+	ssmServicePrincipal    = "ssm.amazonaws.com"
+	passedToServiceKey     = "iam:PassedToService"
+	arnParts               = 6
+	assumedRolePrefix      = "assumed-role/"
+)
+
+var (
+	errMissingRoleName = errors.New("managed instance role not configured")
+	errDenied          = errors.New("denied")
+	errNotTrusted      = errors.New("trust policy does not allow " + ssmServicePrincipal + " to " + actionAssumeRole)
+)
+
+func getRole(ctx context.Context, client *iam.Client, roleName string) (*types.Role, error) {
+	if roleName == "" {
+		return nil, errMissingRoleName
+	}
+
+	output, err := client.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if err != nil {
+		return nil, fmt.Errorf("iam:GetRole %s: %w", roleName, err)
+	}
+
+	return output.Role, nil
+}
+
+// simulate evaluates whether principal may perform action on resource.
+func simulate(ctx context.Context, client *iam.Client, principal, action, resource string) error {
+	input := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(policySourceARN(principal)),
+		ActionNames:     []string{action},
+		ResourceArns:    []string{resource},
+	}
+
+	if action == actionPassRole {
+		input.ContextEntries = []types.ContextEntry{{
+			ContextKeyName:   aws.String(passedToServiceKey),
+			ContextKeyType:   types.ContextKeyTypeEnumString,
+			ContextKeyValues: []string{ssmServicePrincipal},
+		}}
+	}
+
+	output, err := client.SimulatePrincipalPolicy(ctx, input)
+	if err != nil {
+		return fmt.Errorf("iam:SimulatePrincipalPolicy: %w", err)
+	}
+
+	for _, result := range output.EvaluationResults {
+		if result.EvalDecision != types.PolicyEvaluationDecisionTypeAllowed {
+			return fmt.Errorf("%w: %s on %s (%s)", errDenied, action, resource, result.EvalDecision)
+		}
+	}
+
+	return nil
+}
+
+// policySourceARN maps an STS assumed-role ARN to the IAM role ARN the simulator expects.
+// Roles with a path cannot be recovered from the session ARN and are assumed to live at "/".
+func policySourceARN(principal string) string {
+	parts := strings.SplitN(principal, ":", arnParts)
+	if len(parts) != arnParts || parts[2] != "sts" || !strings.HasPrefix(parts[5], assumedRolePrefix) {
+		return principal
+	}
+
+	roleName, _, _ := strings.Cut(strings.TrimPrefix(parts[5], assumedRolePrefix), "/")
+
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], roleName)
+}
+
+type policyDocument struct {
+	Statement []statement `json:"Statement"` //nolint:tagliatelle // IAM policy grammar
+}
+
+type statement struct {
+	Effect    string      `json:"Effect"`    //nolint:tagliatelle // IAM policy grammar
+	Action    stringOrSet `json:"Action"`    //nolint:tagliatelle // IAM policy grammar
+	Principal principal   `json:"Principal"` //nolint:tagliatelle // IAM policy grammar
+}
+
+type principal struct {
+	Service stringOrSet `json:"Service"` //nolint:tagliatelle // IAM policy grammar
+}
+
+// stringOrSet decodes IAM policy values that may be a single string or a list.
+type stringOrSet []string
+
+func (s *stringOrSet) UnmarshalJSON(data []byte) error {
+	var single string
+
+	err := json.Unmarshal(data, &single)
+	if err == nil {
+		*s = []string{single}
+
+		return nil
+	}
+
+	var list []string
+
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return fmt.Errorf("decode policy value: %w", err)
+	}
+
+	*s = list
+
+	return nil
+}
+
+// checkTrustPolicy verifies the URL-encoded trust policy returned by GetRole lets SSM assume the role.
+func checkTrustPolicy(encoded string) error {
+	document, err := url.QueryUnescape(encoded)
+	if err != nil {
+		return fmt.Errorf("decode trust policy: %w", err)
+	}
+
+	var policy policyDocument
+
+	err = json.Unmarshal([]byte(document), &policy)
+	if err != nil {
+		return fmt.Errorf("parse trust policy: %w", err)
+	}
+
+	for _, stmt := range policy.Statement {
+		if stmt.Effect == "Allow" &&
+			(slices.Contains(stmt.Action, actionAssumeRole) || slices.Contains(stmt.Action, "sts:*")) &&
+			slices.Contains(stmt.Principal.Service, ssmServicePrincipal) {
+			return nil
+		}
+	}
+
+	return errNotTrusted
+}
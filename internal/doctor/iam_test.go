@@ -0,0 +1,149 @@
+package doctor
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestPolicySourceARN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		principal string
+		want      string
+	}{
+		{
+			principal: "arn:aws:sts::123456789012:assumed-role/ttl-task/4f2a9c",
+			want:      "arn:aws:iam::123456789012:role/ttl-task",
+		},
+		{
+			principal: "arn:aws-us-gov:sts::123456789012:assumed-role/ttl-task/session",
+			want:      "arn:aws-us-gov:iam::123456789012:role/ttl-task",
+		},
+		{
+			// The session ARN drops the role path, so the role is assumed to live at "/".
+			principal: "arn:aws:sts::123456789012:assumed-role/ttl-task",
+			want:      "arn:aws:iam::123456789012:role/ttl-task",
+		},
+		{
+			principal: "arn:aws:iam::123456789012:role/platform/ttl-task",
+			want:      "arn:aws:iam::123456789012:role/platform/ttl-task",
+		},
+		{
+			principal: "arn:aws:iam::123456789012:user/ci",
+			want:      "arn:aws:iam::123456789012:user/ci",
+		},
+		{
+			principal: "arn:aws:sts::123456789012:federated-user/ci",
+			want:      "arn:aws:sts::123456789012:federated-user/ci",
+		},
+		{principal: "not-an-arn", want: "not-an-arn"},
+	}
+
+	for _, tt := range tests {
+		if got := policySourceARN(tt.principal); got != tt.want {
+			t.Errorf("policySourceARN(%q) = %q, want %q", tt.principal, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTrustPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  error
+		wantFail bool
+	}{
+		{
+			name: "action as a string",
+			document: `{"Statement":[{"Effect":"Allow","Action":"sts:AssumeRole",` +
+				`"Principal":{"Service":"ssm.amazonaws.com"}}]}`,
+			wantErr:  nil,
+			wantFail: false,
+		},
+		{
+			name: "action and service as lists",
+			document: `{"Statement":[{"Effect":"Allow","Action":["sts:TagSession","sts:AssumeRole"],` +
+				`"Principal":{"Service":["ec2.amazonaws.com","ssm.amazonaws.com"]}}]}`,
+			wantErr:  nil,
+			wantFail: false,
+		},
+		{
+			name: "wildcard action",
+			document: `{"Statement":[{"Effect":"Allow","Action":"sts:*",` +
+				`"Principal":{"Service":"ssm.amazonaws.com"}}]}`,
+			wantErr:  nil,
+			wantFail: false,
+		},
+		{
+			name: "denied",
+			document: `{"Statement":[{"Effect":"Deny","Action":"sts:AssumeRole",` +
+				`"Principal":{"Service":"ssm.amazonaws.com"}}]}`,
+			wantErr:  errNotTrusted,
+			wantFail: true,
+		},
+		{
+			name: "other service",
+			document: `{"Statement":[{"Effect":"Allow","Action":["sts:AssumeRole"],` +
+				`"Principal":{"Service":"ecs-tasks.amazonaws.com"}}]}`,
+			wantErr:  errNotTrusted,
+			wantFail: true,
+		},
+		{
+			name: "action of the wrong type",
+			document: `{"Statement":[{"Effect":"Allow","Action":42,` +
+				`"Principal":{"Service":"ssm.amazonaws.com"}}]}`,
+			wantErr:  nil,
+			wantFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkTrustPolicy(url.QueryEscape(tt.document))
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("checkTrustPolicy = %v, want failure %t", err, tt.wantFail)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkTrustPolicy = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStringOrSet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		data    string
+		want    stringOrSet
+		wantErr bool
+	}{
+		{data: `"sts:AssumeRole"`, want: stringOrSet{"sts:AssumeRole"}, wantErr: false},
+		{data: `["sts:AssumeRole","sts:TagSession"]`, want: stringOrSet{"sts:AssumeRole", "sts:TagSession"}, wantErr: false},
+		{data: `[]`, want: stringOrSet{}, wantErr: false},
+		{data: `{"Service":"ssm.amazonaws.com"}`, want: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got stringOrSet
+
+		err := got.UnmarshalJSON([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalJSON(%s) error = %v, want error %t", tt.data, err, tt.wantErr)
+
+			continue
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("UnmarshalJSON(%s) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
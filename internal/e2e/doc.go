@@ -0,0 +1,3 @@
+// Package e2e runs the wrapper's whole lifecycle in-process against a fake ECS task metadata
+// endpoint, the ssmtest fake SSM API and a stub amazon-ssm-agent. It holds only tests.
+package e2e
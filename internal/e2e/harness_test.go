@@ -0,0 +1,376 @@
+package e2e_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+const (
+	// envStub makes the test binary act as amazon-ssm-agent.
+	envStub = "TTL_E2E_STUB_AGENT"
+	// envStubLog is the file the stub appends one line per lifecycle event to.
+	envStubLog = "TTL_E2E_STUB_LOG"
+	// envStubRegistration is where the stub writes the registration file.
+	envStubRegistration = "TTL_E2E_STUB_REGISTRATION"
+
+	taskARN          = "arn:aws:ecs:us-east-1:123456789012:task/e2e/0123456789abcdef"
+	availabilityZone = "us-east-1a"
+	agentName        = "amazon-ssm-agent"
+	waitTimeout      = 10 * time.Second
+	pollInterval     = 10 * time.Millisecond
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envStub) == "1" {
+		os.Exit(stubAgent(os.Args[1:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+// stubAgent stands in for amazon-ssm-agent. With -register it registers with the fake SSM
+// endpoint and writes the registration file; otherwise it runs until SIGTERM or SIGINT.
+func stubAgent(args []string) int {
+	logEvent := func(format string, a ...any) {
+		file, err := os.OpenFile(os.Getenv(envStubLog), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		_, _ = fmt.Fprintf(file, format+"\n", a...)
+	}
+
+	if slices.Contains(args, "-register") {
+		return stubRegister(args, logEvent)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+
+	logEvent("started")
+	fmt.Println("INFO stub agent running") //nolint:forbidigo // exercises the wrapper's output processing
+
+	sig := <-sigs
+	logEvent("stopped %s", sig)
+
+	return 0
+}
+
+func stubRegister(args []string, logEvent func(string, ...any)) int {
+	fs := flag.NewFlagSet(agentName, flag.ContinueOnError)
+	register := fs.Bool("register", false, "")
+	code := fs.String("code", "", "")
+	id := fs.String("id", "", "")
+	region := fs.String("region", "", "")
+
+	if err := fs.Parse(args); err != nil || !*register {
+		logEvent("bad arguments %q", args)
+
+		return 2
+	}
+
+	instanceID, err := ssmtest.Register(context.Background(), http.DefaultClient,
+		os.Getenv(internal.EnvEndpointSSM), *id, *code)
+	if err != nil {
+		logEvent("register failed %s", ssmtest.ErrorCode(err))
+
+		return 1
+	}
+
+	path := os.Getenv(envStubRegistration)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		panic(err)
+	}
+
+	doc, _ := json.Marshal(map[string]string{"ManagedInstanceID": instanceID, "Region": *region})
+	if err := os.WriteFile(path, doc, 0o600); err != nil {
+		panic(err)
+	}
+
+	logEvent("registered %s", instanceID)
+
+	return 0
+}
+
+// harness owns the fakes and the filesystem root for one wrapper run.
+type harness struct {
+	t        *testing.T
+	ssm      *ssmtest.Server
+	metadata *httptest.Server
+	root     string
+	log      string
+	report   string
+	signals  chan os.Signal
+	logs     *logBuffer
+
+	// containers is the task's container list served by the fake metadata endpoint.
+	mu         sync.Mutex
+	containers []container
+}
+
+// logBuffer collects the wrapper's log output; the wrapper and the test read and write it
+// concurrently.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p) //nolint:wrapcheck // bytes.Buffer never fails
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// container is one entry of the task metadata container list.
+type container struct {
+	Name        string `json:"Name"`
+	KnownStatus string `json:"KnownStatus"`
+	ExitCode    *int   `json:"ExitCode,omitempty"`
+}
+
+// newHarness starts the fakes. The AWS SDK reads credentials from the process environment, so
+// tests using a harness cannot run in parallel.
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDE2E")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "aws-config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "aws-credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	h := &harness{
+		t:          t,
+		ssm:        ssmtest.NewServer(),
+		metadata:   nil,
+		root:       filepath.Join(dir, "root"),
+		log:        filepath.Join(dir, "stub.log"),
+		report:     filepath.Join(dir, "termination-log"),
+		signals:    make(chan os.Signal, 1),
+		logs:       &logBuffer{mu: sync.Mutex{}, buf: bytes.Buffer{}},
+		mu:         sync.Mutex{},
+		containers: nil,
+	}
+
+	h.metadata = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/task" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"TaskARN":          taskARN,
+			"AvailabilityZone": availabilityZone,
+			"Containers":       h.containers,
+		})
+	}))
+
+	t.Cleanup(h.ssm.Close)
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("wrapper log:\n%s", h.logs)
+		}
+	})
+	t.Cleanup(h.metadata.Close)
+
+	// Mirror the directories the image creates for the agent.
+	for _, dir := range []string{
+		"/var/lib/amazon/ssm/runtimeconfig",
+		"/var/lib/amazon/ssm/ipc",
+		"/var/log/amazon/ssm",
+		"/etc/amazon/ssm/sessionlogger",
+	} {
+		if err := os.MkdirAll(filepath.Join(h.root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return h
+}
+
+// setContainers replaces the container list the fake metadata endpoint reports.
+func (h *harness) setContainers(containers ...container) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.containers = containers
+}
+
+// environ is the wrapper's environment: the settings every run needs, then extra KEY=value
+// entries, which win.
+func (h *harness) environ(extra ...string) []string {
+	return append([]string{
+		envStub + "=1",
+		envStubLog + "=" + h.log,
+		envStubRegistration + "=" + filepath.Join(h.root, internal.RegistrationFilePath),
+		"PATH=" + os.Getenv("PATH"),
+		internal.EnvManagedInstanceRole + "=ssm-role",
+		internal.MetadataEnvKey + "=" + h.metadata.URL,
+		internal.EnvEndpointSSM + "=" + h.ssm.URL,
+		internal.EnvTTLShutdownGraceSeconds + "=2s",
+		internal.EnvLogLevel + "=debug",
+		internal.EnvTerminationReport + "=" + h.report,
+	}, extra...)
+}
+
+// command starts the test binary as the stub agent, whatever agent path the wrapper asks for.
+func (h *harness) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if filepath.Base(name) != agentName {
+		h.t.Errorf("wrapper started %q, want %s", name, agentName)
+	}
+
+	return exec.CommandContext(ctx, os.Args[0], args...) // #nosec G204 -- re-executes the test binary
+}
+
+// run runs the wrapper to completion with extra environment entries.
+func (h *harness) run(extra ...string) (int, error) {
+	app := runner.NewApp(
+		runner.WithEnviron(h.environ(extra...)),
+		runner.WithRoot(h.root),
+		runner.WithCommand(h.command),
+		runner.WithSignals(h.signals),
+		runner.WithLogOutput(h.logs),
+	)
+
+	return app.Run([]string{agentName})
+}
+
+// outcome is how a wrapper run started with start ended.
+type outcome struct {
+	code int
+	err  error
+}
+
+// start runs the wrapper in the background with extra environment entries.
+func (h *harness) start(extra ...string) <-chan outcome {
+	done := make(chan outcome, 1)
+
+	go func() {
+		code, err := h.run(extra...)
+		done <- outcome{code: code, err: err}
+	}()
+
+	return done
+}
+
+// events returns the stub agent's lifecycle events so far.
+func (h *harness) events() []string {
+	file, err := os.Open(h.log)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer file.Close()
+
+	var events []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		events = append(events, scanner.Text())
+	}
+
+	return events
+}
+
+// logRecords returns the wrapper's log records so far; the run must use LOG_FORMAT=json.
+func (h *harness) logRecords() []map[string]any {
+	var records []map[string]any
+
+	for line := range strings.Lines(h.logs.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			h.t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// waitForEvent blocks until the stub logs an event starting with prefix.
+func (h *harness) waitForEvent(prefix string) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for time.Now().Before(deadline) {
+		if slices.ContainsFunc(h.events(), func(e string) bool { return strings.HasPrefix(e, prefix) }) {
+			return
+		}
+
+		time.Sleep(pollInterval)
+	}
+
+	h.t.Fatalf("stub agent never logged %q; events: %q", prefix, h.events())
+}
+
+// waitUntil blocks until cond holds.
+func (h *harness) waitUntil(desc string, cond func() bool) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+
+		time.Sleep(pollInterval)
+	}
+
+	h.t.Fatalf("timed out waiting until %s", desc)
+}
+
+// assertCleanedUp checks every SSM resource the run created was removed again.
+func (h *harness) assertCleanedUp() {
+	h.t.Helper()
+
+	for _, op := range []string{ssmtest.OpCreateActivation, ssmtest.OpDeleteActivation} {
+		if calls := h.ssm.Calls(op); calls != 1 {
+			h.t.Errorf("%s calls = %d, want 1", op, calls)
+		}
+	}
+
+	if h.ssm.Activations() != 0 || h.ssm.Instances() != 0 {
+		h.t.Errorf("state left behind: %d activations, %d instances", h.ssm.Activations(), h.ssm.Instances())
+	}
+}
@@ -0,0 +1,180 @@
+package e2e_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/control"
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+func TestLazyWakeAndIdle(t *testing.T) {
+	h := newHarness(t)
+	socket := filepath.Join(h.root, internal.DefaultControlSocket)
+
+	done := h.start(
+		internal.EnvTTLSeconds+"=1h",
+		internal.EnvLazy+"=true",
+		internal.EnvLazyIdleSeconds+"=300ms",
+		internal.EnvLogFormat+"=json",
+	)
+
+	wake := func() {
+		t.Helper()
+
+		h.waitUntil("the control socket accepts wake requests", func() bool {
+			resp, err := control.Wake(t.Context(), socket)
+
+			return err == nil && resp.Phase == "dormant"
+		})
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if calls := h.ssm.Calls(ssmtest.OpCreateActivation); calls != 0 {
+		t.Fatalf("activation created %d times while dormant", calls)
+	}
+
+	wake()
+	h.waitForEvent("started")
+	h.waitUntil("the idle agent is deregistered", func() bool {
+		return h.ssm.Calls(ssmtest.OpDeleteActivation) == 1 && h.ssm.Instances() == 0
+	})
+
+	if !slices.Contains(h.events(), "stopped terminated") {
+		t.Fatalf("events = %q, want the idle agent stopped", h.events())
+	}
+
+	wake()
+	h.waitUntil("the agent starts again", func() bool {
+		return len(slices.DeleteFunc(h.events(), func(e string) bool { return e != "started" })) == 2
+	})
+
+	h.signals <- syscall.SIGTERM
+
+	got := <-done
+	if got.err != nil || got.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 after SIGTERM", got.code, got.err)
+	}
+
+	// Once dormant, and until the next session activates, nothing is logged with the previous
+	// session's identity.
+	for _, record := range h.logRecords() {
+		if phase := record["phase"]; phase != "dormant" && phase != "discover" {
+			continue
+		}
+
+		for _, key := range []string{"activationId", "managedInstanceId"} {
+			if id, ok := record[key]; ok {
+				t.Errorf("%s log %q carries %s %v", record["phase"], record["msg"], key, id)
+			}
+		}
+	}
+
+	for _, op := range []string{ssmtest.OpCreateActivation, ssmtest.OpDeleteActivation} {
+		if calls := h.ssm.Calls(op); calls != 2 {
+			t.Errorf("%s calls = %d, want one per wake", op, calls)
+		}
+	}
+
+	if h.ssm.Activations() != 0 || h.ssm.Instances() != 0 {
+		t.Errorf("state left behind: %d activations, %d instances", h.ssm.Activations(), h.ssm.Instances())
+	}
+}
+
+// TestLazyIdleWaitsForSessionWorkers puts a session worker under the fake /proc with the name
+// the kernel keeps in comm, and checks the agent only goes idle once it has gone.
+func TestLazyIdleWaitsForSessionWorkers(t *testing.T) {
+	h := newHarness(t)
+	socket := filepath.Join(h.root, internal.DefaultControlSocket)
+
+	worker := filepath.Join(h.root, "proc", "4242")
+	if err := os.MkdirAll(worker, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(worker, "comm"), []byte("ssm-session-wor\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	done := h.start(
+		internal.EnvTTLSeconds+"=1h",
+		internal.EnvLazy+"=true",
+		internal.EnvLazyIdleSeconds+"=200ms",
+	)
+
+	h.waitUntil("the control socket accepts wake requests", func() bool {
+		_, err := control.Wake(t.Context(), socket)
+
+		return err == nil
+	})
+	h.waitForEvent("started")
+
+	time.Sleep(time.Second)
+
+	if slices.Contains(h.events(), "stopped terminated") || h.ssm.Calls(ssmtest.OpDeleteActivation) != 0 {
+		t.Fatalf("agent stopped as idle with a session worker running; events %q", h.events())
+	}
+
+	if err := os.RemoveAll(worker); err != nil {
+		t.Fatal(err)
+	}
+
+	h.waitUntil("the idle agent is deregistered", func() bool {
+		return h.ssm.Calls(ssmtest.OpDeleteActivation) == 1
+	})
+
+	h.signals <- syscall.SIGTERM
+
+	if got := <-done; got.err != nil || got.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 after SIGTERM", got.code, got.err)
+	}
+}
+
+func TestLazyDeadlineWhileDormant(t *testing.T) {
+	h := newHarness(t)
+
+	code, err := h.run(
+		internal.EnvTTLDeadline+"="+time.Now().Add(300*time.Millisecond).Format(time.RFC3339Nano),
+		internal.EnvLazy+"=true",
+	)
+	if err != nil || code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 once the deadline passed", code, err)
+	}
+
+	if calls := h.ssm.Calls(ssmtest.OpCreateActivation); calls != 0 {
+		t.Errorf("activation created %d times without a wake", calls)
+	}
+
+	if report := h.terminationReport(); report.Reason != "ttl-expired" || report.StopReason != "deadline reached" {
+		t.Errorf("report reason %q, stop reason %q; want the deadline", report.Reason, report.StopReason)
+	}
+}
+
+func TestLazySignalWhileDormant(t *testing.T) {
+	h := newHarness(t)
+
+	done := h.start(internal.EnvTTLSeconds+"=1h", internal.EnvLazy+"=true")
+
+	time.Sleep(100 * time.Millisecond)
+	h.signals <- syscall.SIGTERM
+
+	got := <-done
+	if got.err != nil || got.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 after SIGTERM", got.code, got.err)
+	}
+
+	if calls := h.ssm.Calls(ssmtest.OpCreateActivation); calls != 0 {
+		t.Errorf("activation created %d times without a wake", calls)
+	}
+
+	if report := h.terminationReport(); report.Reason != "stop-requested" || report.StopReason != "signal terminated" {
+		t.Errorf("report reason %q, stop reason %q; want the signal", report.Reason, report.StopReason)
+	}
+}
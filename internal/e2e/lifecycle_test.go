@@ -0,0 +1,205 @@
+package e2e_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+func TestTTLExpiry(t *testing.T) {
+	h := newHarness(t)
+
+	code, err := h.run(internal.EnvTTLSeconds + "=500ms")
+	if err != nil || code != 0 {
+		t.Fatalf("Run = %d, %v; want 0", code, err)
+	}
+
+	events := h.events()
+	if len(events) != 3 || !strings.HasPrefix(events[0], "registered mi-") || events[1] != "started" ||
+		events[2] != "stopped terminated" {
+		t.Fatalf("unexpected agent events %q", events)
+	}
+
+	instanceID := strings.TrimPrefix(events[0], "registered ")
+
+	if calls := h.ssm.Calls(ssmtest.OpDeregisterManagedInstance); calls != 1 {
+		t.Errorf("DeregisterManagedInstance calls = %d, want 1", calls)
+	}
+
+	h.assertCleanedUp()
+
+	var identity struct {
+		InstanceID string `json:"InstanceId"` //nolint:tagliatelle // agent runtime config schema
+	}
+
+	data, err := os.ReadFile(filepath.Join(h.root, "/var/lib/amazon/ssm/runtimeconfig/identity_config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(data, &identity); err != nil || identity.InstanceID != instanceID {
+		t.Fatalf("runtime identity = %s, want instance %s", data, instanceID)
+	}
+
+	if _, err := os.Stat(filepath.Join(h.root, internal.DefaultAgentConfigDir, "amazon-ssm-agent.json")); err != nil {
+		t.Errorf("agent config was not rendered under the root: %v", err)
+	}
+}
+
+func TestActivationUsesTaskMetadata(t *testing.T) {
+	h := newHarness(t)
+
+	// Fail both rollbacks so the instance and activation survive the run and can be inspected.
+	h.ssm.Inject(ssmtest.OpDeregisterManagedInstance, ssmtest.Fault{Code: ssmtest.CodeInternalServerError, Times: 1})
+	h.ssm.Inject(ssmtest.OpDeleteActivation, ssmtest.Fault{Code: ssmtest.CodeInternalServerError, Times: 1})
+
+	code, err := h.run(internal.EnvTTLSeconds + "=200ms")
+	if err == nil || code != runner.ExitCleanup {
+		t.Fatalf("Run = %d, %v; want a cleanup error", code, err)
+	}
+
+	if h.ssm.Activations() != 1 || h.ssm.Instances() != 1 {
+		t.Fatalf("%d activations, %d instances; want the ones whose removal failed",
+			h.ssm.Activations(), h.ssm.Instances())
+	}
+
+	if calls := h.ssm.Calls(ssmtest.OpDeleteActivation); calls != 1 {
+		t.Errorf("DeleteActivation calls = %d; want it attempted after deregistration failed", calls)
+	}
+
+	var tags map[string]string
+
+	for _, event := range h.events() {
+		if id, ok := strings.CutPrefix(event, "registered "); ok {
+			instance, found := h.ssm.Instance(id)
+			if !found {
+				t.Fatalf("instance %s missing", id)
+			}
+
+			activation, _ := h.ssm.Activation(instance.ActivationID)
+			tags = activation.Tags
+
+			if activation.DefaultInstanceName != taskARN || activation.IamRole != "ssm-role" {
+				t.Errorf("unexpected activation %+v", activation)
+			}
+		}
+	}
+
+	if tags["ECS_TASK_ARN"] != taskARN || tags["ECS_TASK_AVAILABILITY_ZONE"] != availabilityZone {
+		t.Fatalf("activation tags %v do not describe the task", tags)
+	}
+}
+
+func TestSignalForwarding(t *testing.T) {
+	h := newHarness(t)
+
+	done := h.start(internal.EnvTTLSeconds + "=1h")
+
+	h.waitForEvent("started")
+	h.signals <- syscall.SIGTERM
+
+	result := <-done
+	if result.err != nil || result.code != 0 {
+		t.Fatalf("Run = %d, %v; want the agent's exit status 0", result.code, result.err)
+	}
+
+	if events := h.events(); !slices.Contains(events, "stopped terminated") {
+		t.Fatalf("agent did not receive SIGTERM; events %q", events)
+	}
+
+	h.assertCleanedUp()
+}
+
+// TestSignalDuringRegistration sends SIGTERM while the agent registers, before there is an agent
+// to forward it to, and checks the activation created for it is rolled back.
+func TestSignalDuringRegistration(t *testing.T) {
+	h := newHarness(t)
+
+	h.ssm.Inject(ssmtest.OpRegisterManagedInstance, ssmtest.Fault{Delay: time.Minute, Times: 1})
+
+	done := h.start(internal.EnvTTLSeconds + "=1h")
+
+	h.waitUntil("registration starts", func() bool { return h.ssm.Calls(ssmtest.OpRegisterManagedInstance) > 0 })
+	h.signals <- syscall.SIGTERM
+
+	result := <-done
+	if result.err != nil || result.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 after rolling back", result.code, result.err)
+	}
+
+	if events := h.events(); slices.Contains(events, "started") {
+		t.Errorf("agent started after the signal; events %q", events)
+	}
+
+	h.assertCleanedUp()
+
+	if report := h.terminationReport(); report.Reason != "stop-requested" || report.StopReason != "signal terminated" {
+		t.Errorf("report reason %q, stop reason %q; want the signal", report.Reason, report.StopReason)
+	}
+}
+
+func TestRegistrationFailure(t *testing.T) {
+	h := newHarness(t)
+
+	h.ssm.Inject(ssmtest.OpRegisterManagedInstance, ssmtest.Fault{Code: ssmtest.CodeInvalidActivation})
+
+	code, err := h.run(internal.EnvTTLSeconds + "=1h")
+	if err == nil || code != runner.ExitRegister {
+		t.Fatalf("Run = %d, %v; want a registration error", code, err)
+	}
+
+	if events := h.events(); len(events) != 1 || events[0] != "register failed "+ssmtest.CodeInvalidActivation {
+		t.Fatalf("unexpected agent events %q", events)
+	}
+
+	if calls := h.ssm.Calls(ssmtest.OpDeregisterManagedInstance); calls != 0 {
+		t.Errorf("DeregisterManagedInstance calls = %d, want 0 without a registration", calls)
+	}
+
+	h.assertCleanedUp()
+}
+
+func TestOnlineTimeout(t *testing.T) {
+	h := newHarness(t)
+
+	h.ssm.Inject(ssmtest.OpDescribeInstanceInformation, ssmtest.Fault{Code: ssmtest.CodeInternalServerError})
+
+	code, err := h.run(internal.EnvTTLSeconds+"=1h", internal.EnvOnlineTimeoutSeconds+"=300ms")
+	if err == nil || code != runner.ExitVerifyOnline {
+		t.Fatalf("Run = %d, %v; want an online timeout", code, err)
+	}
+
+	if events := h.events(); !slices.Contains(events, "stopped terminated") {
+		t.Fatalf("agent was not stopped on rollback; events %q", events)
+	}
+
+	h.assertCleanedUp()
+}
+
+func TestOnlineWaitFailsFastWhenDescribeDenied(t *testing.T) {
+	h := newHarness(t)
+
+	h.ssm.Inject(ssmtest.OpDescribeInstanceInformation, ssmtest.Fault{Code: ssmtest.CodeAccessDenied})
+
+	code, err := h.run(internal.EnvTTLSeconds+"=1h", internal.EnvOnlineTimeoutSeconds+"=1h")
+	if !errors.Is(err, ssmagent.ErrDescribeDenied) || code != runner.ExitVerifyOnline {
+		t.Fatalf("Run = %d, %v; want the denied describe to end the wait", code, err)
+	}
+
+	if calls := h.ssm.Calls(ssmtest.OpDescribeInstanceInformation); calls != 1 {
+		t.Errorf("DescribeInstanceInformation calls = %d, want 1: a denial is not retried", calls)
+	}
+
+	h.assertCleanedUp()
+}
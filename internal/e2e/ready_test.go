@@ -0,0 +1,108 @@
+package e2e_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+func TestReadyFileGatesActivation(t *testing.T) {
+	h := newHarness(t)
+	ready := filepath.Join(t.TempDir(), "ready")
+
+	done := h.start(
+		internal.EnvTTLSeconds+"=200ms",
+		internal.EnvReadyFile+"="+ready,
+		internal.EnvWatchIntervalSeconds+"=50ms",
+	)
+
+	time.Sleep(300 * time.Millisecond)
+
+	if calls := h.ssm.Calls(ssmtest.OpCreateActivation); calls != 0 {
+		t.Fatalf("activation created %d times before the application was ready", calls)
+	}
+
+	if err := os.WriteFile(ready, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-done; got.err != nil || got.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 after the TTL", got.code, got.err)
+	}
+
+	h.assertCleanedUp()
+
+	if names := h.terminationReport().phaseNames(); !slices.Equal(names[:3], []string{"discover", "wait-ready", "activate"}) {
+		t.Errorf("phases = %q, want wait-ready between discover and activate", names)
+	}
+}
+
+func TestNotReady(t *testing.T) {
+	h := newHarness(t)
+
+	code, err := h.run(
+		internal.EnvTTLSeconds+"=1h",
+		internal.EnvReadyFile+"="+filepath.Join(t.TempDir(), "ready"),
+		internal.EnvReadyTimeoutSeconds+"=200ms",
+		internal.EnvWatchIntervalSeconds+"=50ms",
+	)
+	if err == nil || code != runner.ExitNotReady {
+		t.Fatalf("Run = %d, %v; want the application not to be ready", code, err)
+	}
+
+	if calls := h.ssm.Calls(ssmtest.OpCreateActivation); calls != 0 {
+		t.Errorf("activation created %d times for an application that never got ready", calls)
+	}
+
+	if report := h.terminationReport(); report.Reason != "not-ready" || report.FailedPhase != "wait-ready" {
+		t.Errorf("report reason %q, failed phase %q; want not-ready in wait-ready", report.Reason, report.FailedPhase)
+	}
+}
+
+func TestNotReadyContinues(t *testing.T) {
+	h := newHarness(t)
+
+	code, err := h.run(
+		internal.EnvTTLSeconds+"=200ms",
+		internal.EnvReadyFile+"="+filepath.Join(t.TempDir(), "ready"),
+		internal.EnvReadyTimeoutSeconds+"=200ms",
+		internal.EnvReadyPolicy+"=continue",
+		internal.EnvWatchIntervalSeconds+"=50ms",
+	)
+	if err != nil || code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want the run to continue and end with the TTL", code, err)
+	}
+
+	h.assertCleanedUp()
+}
+
+func TestReadyContainerStoppedFailsDespiteContinue(t *testing.T) {
+	h := newHarness(t)
+
+	exitCode := 1
+	h.setContainers(
+		container{Name: "app", KnownStatus: "STOPPED", ExitCode: &exitCode},
+		container{Name: "ssm", KnownStatus: "RUNNING", ExitCode: nil},
+	)
+
+	code, err := h.run(
+		internal.EnvTTLSeconds+"=1h",
+		internal.EnvReadyContainers+"=app",
+		internal.EnvReadyTimeoutSeconds+"=1h",
+		internal.EnvReadyPolicy+"=continue",
+		internal.EnvWatchIntervalSeconds+"=50ms",
+	)
+	if err == nil || code != runner.ExitNotReady {
+		t.Fatalf("Run = %d, %v; want a stopped container to fail even with the continue policy", code, err)
+	}
+
+	if calls := h.ssm.Calls(ssmtest.OpCreateActivation); calls != 0 {
+		t.Errorf("activation created %d times for a stopped application", calls)
+	}
+}
@@ -0,0 +1,130 @@
+package e2e_test
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+// terminationReport is the part of the wrapper's termination report the tests inspect.
+type terminationReport struct {
+	ExitCode    int    `json:"exitCode"`
+	Reason      string `json:"reason"`
+	FailedPhase string `json:"failedPhase"`
+	Error       string `json:"error"`
+	StopReason  string `json:"stopReason"`
+	Phases      []struct {
+		Name  string `json:"name"`
+		Error string `json:"error"`
+	} `json:"phases"`
+	Agent *struct {
+		ExitCode   int  `json:"exitCode"`
+		TTLExpired bool `json:"ttlExpired"`
+	} `json:"agent"`
+	Cleanup *struct {
+		Outcome string `json:"outcome"`
+		Steps   []struct {
+			Name    string `json:"name"`
+			Outcome string `json:"outcome"`
+		} `json:"steps"`
+	} `json:"cleanup"`
+}
+
+func (h *harness) terminationReport() terminationReport {
+	h.t.Helper()
+
+	data, err := os.ReadFile(h.report)
+	if err != nil {
+		h.t.Fatalf("read termination report: %v", err)
+	}
+
+	var report terminationReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		h.t.Fatalf("decode termination report %s: %v", data, err)
+	}
+
+	return report
+}
+
+func (r terminationReport) phaseNames() []string {
+	names := make([]string, 0, len(r.Phases))
+	for _, phase := range r.Phases {
+		names = append(names, phase.Name)
+	}
+
+	return names
+}
+
+func TestTerminationReportAfterTTL(t *testing.T) {
+	h := newHarness(t)
+
+	code, err := h.run(internal.EnvTTLSeconds + "=200ms")
+	if err != nil || code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0", code, err)
+	}
+
+	report := h.terminationReport()
+	if report.ExitCode != runner.ExitOK || report.Reason != "ttl-expired" || report.StopReason != "ttl expired" {
+		t.Errorf("report exit %d, reason %q, stop reason %q; want a TTL expiry",
+			report.ExitCode, report.Reason, report.StopReason)
+	}
+
+	want := []string{
+		"discover", "activate", "register", "persist-identity", "verify-online", "supervise", "drain", "cleanup",
+	}
+	if got := report.phaseNames(); !slices.Equal(got, want) {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+
+	if report.Agent == nil || !report.Agent.TTLExpired {
+		t.Errorf("agent = %+v, want a TTL expiry", report.Agent)
+	}
+
+	if report.Cleanup == nil || report.Cleanup.Outcome != "success" || len(report.Cleanup.Steps) != 4 {
+		t.Errorf("cleanup = %+v, want four successful steps", report.Cleanup)
+	}
+}
+
+func TestTerminationReportActivationDenied(t *testing.T) {
+	h := newHarness(t)
+
+	h.ssm.Inject(ssmtest.OpCreateActivation, ssmtest.Fault{Code: ssmtest.CodeAccessDenied})
+
+	code, err := h.run(internal.EnvTTLSeconds + "=1h")
+	if err == nil || code != runner.ExitActivationDenied {
+		t.Fatalf("Run = %d, %v; want the activation to be denied", code, err)
+	}
+
+	report := h.terminationReport()
+	if report.ExitCode != code || report.Reason != "activation-denied" || report.FailedPhase != "activate" {
+		t.Errorf("report exit %d, reason %q, failed phase %q; want a denied activation",
+			report.ExitCode, report.Reason, report.FailedPhase)
+	}
+
+	if report.Error == "" || report.Agent != nil {
+		t.Errorf("report error %q, agent %+v; want an error and no agent", report.Error, report.Agent)
+	}
+
+	if events := h.events(); len(events) != 0 {
+		t.Errorf("agent ran after activation was denied: %q", events)
+	}
+}
+
+func TestTerminationReportConfigError(t *testing.T) {
+	h := newHarness(t)
+
+	code, err := h.run(internal.EnvManagedInstanceRole + "=")
+	if err == nil || code != runner.ExitConfig {
+		t.Fatalf("Run = %d, %v; want a configuration error", code, err)
+	}
+
+	if report := h.terminationReport(); report.Reason != "config-error" || len(report.Phases) != 0 {
+		t.Errorf("report reason %q, phases %q; want a configuration error before any phase",
+			report.Reason, report.phaseNames())
+	}
+}
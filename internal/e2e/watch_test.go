@@ -0,0 +1,117 @@
+package e2e_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/runner"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+func TestWatchedContainerStops(t *testing.T) {
+	h := newHarness(t)
+	h.setContainers(
+		container{Name: "app", KnownStatus: "RUNNING", ExitCode: nil},
+		container{Name: "ssm", KnownStatus: "RUNNING", ExitCode: nil},
+	)
+
+	done := h.start(
+		internal.EnvTTLSeconds+"=1h",
+		internal.EnvWatchContainers+"=app",
+		internal.EnvWatchIntervalSeconds+"=50ms",
+	)
+
+	h.waitForEvent("started")
+
+	exitCode := 1
+	h.setContainers(
+		container{Name: "app", KnownStatus: "STOPPED", ExitCode: &exitCode},
+		container{Name: "ssm", KnownStatus: "RUNNING", ExitCode: nil},
+	)
+
+	got := <-done
+	if got.err != nil || got.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 once the app container stopped", got.code, got.err)
+	}
+
+	h.waitForEvent("stopped terminated")
+	h.assertCleanedUp()
+
+	report := h.terminationReport()
+	if report.Reason != "stop-requested" || report.StopReason != "container app stopped with exit code 1" {
+		t.Errorf("report reason %q, stop reason %q; want the app container's exit",
+			report.Reason, report.StopReason)
+	}
+}
+
+// TestProcessFinishedBeforeOnline covers a main process that exits while the agent is still
+// coming online: the watcher runs from the start of the lifecycle, so it has seen it.
+func TestProcessFinishedBeforeOnline(t *testing.T) {
+	h := newHarness(t)
+
+	proc := filepath.Join(h.root, "proc", "4242")
+	if err := os.MkdirAll(proc, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(proc, "comm"), []byte("app-main\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the first online check long enough for the watcher to see the process exit.
+	h.ssm.Inject(ssmtest.OpDescribeInstanceInformation, ssmtest.Fault{Delay: 500 * time.Millisecond, Times: 1})
+
+	done := h.start(
+		internal.EnvTTLSeconds+"=1h",
+		internal.EnvWatchProcess+"=app-main",
+		internal.EnvWatchIntervalSeconds+"=50ms",
+	)
+
+	h.waitUntil("online wait", func() bool { return h.ssm.Calls(ssmtest.OpDescribeInstanceInformation) > 0 })
+
+	if err := os.RemoveAll(proc); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-done
+	if got.err != nil || got.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 once the main process exited", got.code, got.err)
+	}
+
+	h.assertCleanedUp()
+
+	if report := h.terminationReport(); report.StopReason != "process app-main exited" {
+		t.Errorf("stop reason %q, want the main process", report.StopReason)
+	}
+}
+
+func TestCompletionFile(t *testing.T) {
+	h := newHarness(t)
+	done := filepath.Join(t.TempDir(), "main-finished")
+
+	finished := h.start(
+		internal.EnvTTLSeconds+"=1h",
+		internal.EnvWatchFile+"="+done,
+		internal.EnvWatchIntervalSeconds+"=50ms",
+	)
+
+	h.waitForEvent("started")
+
+	if err := os.WriteFile(done, []byte("exit 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-finished
+	if got.err != nil || got.code != runner.ExitOK {
+		t.Fatalf("Run = %d, %v; want 0 once the completion file was created", got.code, got.err)
+	}
+
+	h.assertCleanedUp()
+
+	if report := h.terminationReport(); report.StopReason != "file "+done+" created: exit 0" {
+		t.Errorf("stop reason %q, want the completion file", report.StopReason)
+	}
+}
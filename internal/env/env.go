@@ -10,24 +10,86 @@ import (
 	"time"
 )
 
-// DurationSeconds reads an environment variable and returns its value in seconds, or a default.
+// maxUnixSeconds bounds Unix timestamps in seconds; larger values (year 5138 onwards) are
+// almost certainly milliseconds.
+const maxUnixSeconds = 1e11
+
+// zonelessLayouts are timestamp forms that parse but leave the time zone to guess.
+var zonelessLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02"}
+
+// DurationSeconds reads an environment variable as a duration (see ParseDuration), or a default
+// number of seconds.
 func DurationSeconds(key string, defaultSeconds int) (time.Duration, error) {
 	value := strings.TrimSpace(os.Getenv(key))
 	if value == "" {
 		return time.Duration(defaultSeconds) * time.Second, nil
 	}
 
+	return ParseDuration(key, value)
+}
+
+// ParseDuration converts the value read from key into a duration. Integers are whole seconds;
+// anything else must be a Go duration such as "90m" or "1h30m".
+func ParseDuration(key, value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
 	secs, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
 	if err != nil {
-		return 0, errors.Join(ErrInvalidDuration, fmt.Errorf("parse %s (%q): %w", key, value, err))
+		return 0, errors.Join(ErrInvalidDuration,
+			fmt.Errorf("parse %s (%q): want seconds or a Go duration such as 90m: %w", key, value, err))
+	}
+
+	return parsed, nil
+}
+
+// ParseDeadline converts the value read from key into an absolute time. It accepts RFC3339 with
+// an explicit offset or a Unix timestamp in seconds. Timestamps without an offset and numbers
+// large enough to be milliseconds are rejected as ambiguous.
+func ParseDeadline(key, value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if unix <= 0 {
+			return time.Time{}, fmt.Errorf("%w: %s (%q): Unix timestamp must be positive", ErrInvalidDeadline, key, value)
+		}
+
+		if unix >= maxUnixSeconds {
+			return time.Time{}, fmt.Errorf("%w: %s (%q): Unix timestamps must be in seconds", ErrAmbiguousDeadline, key, value)
+		}
+
+		return time.Unix(unix, 0).UTC(), nil
 	}
 
-	return time.Duration(secs) * time.Second, nil
+	deadline, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return deadline, nil
+	}
+
+	for _, layout := range zonelessLayouts {
+		_, zonelessErr := time.Parse(layout, value)
+		if zonelessErr == nil {
+			return time.Time{}, fmt.Errorf("%w: %s (%q): add a UTC offset such as Z or +02:00", ErrAmbiguousDeadline, key, value)
+		}
+	}
+
+	return time.Time{}, errors.Join(ErrInvalidDeadline,
+		fmt.Errorf("parse %s (%q): want RFC3339 or a Unix timestamp: %w", key, value, err))
 }
 
-// MustGetNonEmpty fetches a non-empty environment variable value.
+// MustGetNonEmpty fetches a non-empty environment variable value, honoring KEY_FILE.
+// Secret references are not resolved; use a Resolver with a SecretStore for those.
 func MustGetNonEmpty(key string) (string, error) {
-	value := strings.TrimSpace(os.Getenv(key))
+	raw, _, err := NewResolver(os.LookupEnv, nil).lookupFile(key)
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSpace(raw)
 	if value == "" {
 		return "", fmt.Errorf("%w: %s", ErrMissingVariable, key)
 	}
@@ -0,0 +1,81 @@
+package env_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+)
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr error
+	}{
+		{value: "900", want: 15 * time.Minute},
+		{value: " 60 ", want: time.Minute},
+		{value: "0", want: 0},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "1h30m", want: 90 * time.Minute},
+		{value: "1.5h", want: 90 * time.Minute},
+		{value: "250ms", want: 250 * time.Millisecond},
+		{value: "", wantErr: env.ErrInvalidDuration},
+		{value: "ten", wantErr: env.ErrInvalidDuration},
+		{value: "10 minutes", wantErr: env.ErrInvalidDuration},
+		{value: "1d", wantErr: env.ErrInvalidDuration},
+	}
+
+	for _, tt := range tests {
+		got, err := env.ParseDuration("TTL_SECONDS", tt.value)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("ParseDuration(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr error
+	}{
+		{value: "2026-10-18T12:00:00Z", want: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{value: "2026-10-18T14:00:00+02:00", want: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{value: "1792324800", want: time.Unix(1792324800, 0)},
+		{value: " 1792324800 ", want: time.Unix(1792324800, 0)},
+		{value: "1792324800000", wantErr: env.ErrAmbiguousDeadline},
+		{value: "2026-10-18T12:00:00", wantErr: env.ErrAmbiguousDeadline},
+		{value: "2026-10-18 12:00:00", wantErr: env.ErrAmbiguousDeadline},
+		{value: "2026-10-18T12:00", wantErr: env.ErrAmbiguousDeadline},
+		{value: "2026-10-18", wantErr: env.ErrAmbiguousDeadline},
+		{value: "0", wantErr: env.ErrInvalidDeadline},
+		{value: "-5", wantErr: env.ErrInvalidDeadline},
+		{value: "tomorrow", wantErr: env.ErrInvalidDeadline},
+		{value: "", wantErr: env.ErrInvalidDeadline},
+	}
+
+	for _, tt := range tests {
+		got, err := env.ParseDeadline("TTL_DEADLINE", tt.value)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("ParseDeadline(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+
+			continue
+		}
+
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDeadline(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -7,3 +7,21 @@ var ErrMissingVariable = errors.New("required environment variable not set")
 
 // ErrInvalidDuration indicates an environment variable contained an invalid duration value.
 var ErrInvalidDuration = errors.New("invalid duration value")
+
+// ErrAmbiguousSource indicates both KEY and KEY_FILE were set.
+var ErrAmbiguousSource = errors.New("both variable and _FILE variant set")
+
+// ErrInsecureFile indicates a _FILE target failed its ownership or permission checks.
+var ErrInsecureFile = errors.New("insecure secret file")
+
+// ErrNoSecretStore indicates a secret reference was used without a configured store.
+var ErrNoSecretStore = errors.New("secret reference requires an AWS secret store")
+
+// ErrInvalidReference indicates a malformed ssm-parameter:// or secretsmanager:// reference.
+var ErrInvalidReference = errors.New("invalid secret reference")
+
+// ErrInvalidDeadline indicates an environment variable contained an unparseable deadline.
+var ErrInvalidDeadline = errors.New("invalid deadline value")
+
+// ErrAmbiguousDeadline indicates a deadline that parses but could mean more than one instant.
+var ErrAmbiguousDeadline = errors.New("ambiguous deadline")
@@ -0,0 +1,203 @@
+package env
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	// FileSuffix marks a variable whose value is the path of a file holding the real value.
+	FileSuffix = "_FILE"
+
+	// SSMParameterScheme prefixes references to SSM Parameter Store parameters.
+	SSMParameterScheme = "ssm-parameter://"
+
+	// SecretsManagerScheme prefixes references to Secrets Manager secrets. An optional "#key"
+	// suffix selects one field of a JSON secret.
+	SecretsManagerScheme = "secretsmanager://"
+
+	groupOtherWrite = 0o022
+	otherRead       = 0o004
+	rootUID         = 0
+)
+
+// LookupFunc reports the value of an environment variable and whether it was set.
+type LookupFunc func(key string) (string, bool)
+
+// EnvironLookup returns a LookupFunc over environ, a list of KEY=value entries in the form
+// returned by os.Environ. Later entries win, as they do for exec.
+func EnvironLookup(environ []string) LookupFunc {
+	values := make(map[string]string, len(environ))
+
+	for _, entry := range environ {
+		key, value, ok := strings.Cut(entry, "=")
+		if ok {
+			values[key] = value
+		}
+	}
+
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+
+		return value, ok
+	}
+}
+
+// SecretStore fetches the values behind secret references.
+type SecretStore interface {
+	GetParameter(ctx context.Context, name string) (string, error)
+	GetSecret(ctx context.Context, id string) (string, error)
+}
+
+// Resolver reads variables through KEY_FILE indirection and resolves secret references.
+type Resolver struct {
+	lookup LookupFunc
+	store  SecretStore
+}
+
+// NewResolver returns a Resolver over lookup. Secret references fail when store is nil.
+func NewResolver(lookup LookupFunc, store SecretStore) *Resolver {
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	return &Resolver{lookup: lookup, store: store}
+}
+
+// Lookup returns the value for key, read from the file named by KEY_FILE when set, then
+// resolved through the secret store when it is an ssm-parameter:// or secretsmanager:// reference.
+func (r *Resolver) Lookup(ctx context.Context, key string) (string, bool, error) {
+	value, ok, err := r.lookupFile(key)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+
+	resolved, err := r.resolveReference(ctx, key, value)
+	if err != nil {
+		return "", true, err
+	}
+
+	return resolved, true, nil
+}
+
+func (r *Resolver) lookupFile(key string) (string, bool, error) {
+	value, ok := r.lookup(key)
+	path, fileOK := r.lookup(key + FileSuffix)
+
+	if !fileOK || strings.TrimSpace(path) == "" {
+		return value, ok, nil
+	}
+
+	if ok && strings.TrimSpace(value) != "" {
+		return "", true, fmt.Errorf("%w: %s and %s", ErrAmbiguousSource, key, key+FileSuffix)
+	}
+
+	contents, err := ReadSecretFile(strings.TrimSpace(path))
+	if err != nil {
+		return "", true, fmt.Errorf("read %s: %w", key+FileSuffix, err)
+	}
+
+	return contents, true, nil
+}
+
+func (r *Resolver) resolveReference(ctx context.Context, key, value string) (string, error) {
+	trimmed := strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(trimmed, SSMParameterScheme):
+		name := strings.TrimPrefix(trimmed, SSMParameterScheme)
+		if name == "" || r.store == nil {
+			return "", referenceError(key, trimmed, r.store)
+		}
+
+		resolved, err := r.store.GetParameter(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s: %w", key, err)
+		}
+
+		return resolved, nil
+	case strings.HasPrefix(trimmed, SecretsManagerScheme):
+		id, field, _ := strings.Cut(strings.TrimPrefix(trimmed, SecretsManagerScheme), "#")
+		if id == "" || r.store == nil {
+			return "", referenceError(key, trimmed, r.store)
+		}
+
+		resolved, err := r.store.GetSecret(ctx, id)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s: %w", key, err)
+		}
+
+		return secretField(key, resolved, field)
+	default:
+		return value, nil
+	}
+}
+
+func referenceError(key, reference string, store SecretStore) error {
+	if store == nil {
+		return fmt.Errorf("%w: %s", ErrNoSecretStore, key)
+	}
+
+	return fmt.Errorf("%w: %s=%q", ErrInvalidReference, key, reference)
+}
+
+// secretField returns secret, or one string field of it when field is set.
+func secretField(key, secret, field string) (string, error) {
+	if field == "" {
+		return secret, nil
+	}
+
+	var fields map[string]any
+
+	err := json.Unmarshal([]byte(secret), &fields)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: secret is not a JSON object: %w", ErrInvalidReference, key, err)
+	}
+
+	value, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s: no string field %q in secret", ErrInvalidReference, key, field)
+	}
+
+	return value, nil
+}
+
+// ReadSecretFile reads a value from path, trimming one trailing newline. The file must be a
+// regular file owned by root or the current user and not writable by group or others.
+func ReadSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("stat secret file: %w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %s is not a regular file", ErrInsecureFile, path)
+	}
+
+	if info.Mode().Perm()&groupOtherWrite != 0 {
+		return "", fmt.Errorf("%w: %s is writable by group or others (mode %s)", ErrInsecureFile, path, info.Mode().Perm())
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		owner := int(stat.Uid)
+		if owner != rootUID && owner != os.Getuid() {
+			return "", fmt.Errorf("%w: %s is owned by uid %d", ErrInsecureFile, path, owner)
+		}
+	}
+
+	if info.Mode().Perm()&otherRead != 0 {
+		slog.Warn("secret file is world-readable", slog.String("path", path))
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path supplied by operator and checked above
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
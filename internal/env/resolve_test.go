@@ -0,0 +1,221 @@
+package env_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeStore serves parameters and secrets from maps.
+type fakeStore struct {
+	parameters map[string]string
+	secrets    map[string]string
+}
+
+func (f fakeStore) GetParameter(_ context.Context, name string) (string, error) {
+	value, ok := f.parameters[name]
+	if !ok {
+		return "", errNotFound
+	}
+
+	return value, nil
+}
+
+func (f fakeStore) GetSecret(_ context.Context, id string) (string, error) {
+	value, ok := f.secrets[id]
+	if !ok {
+		return "", errNotFound
+	}
+
+	return value, nil
+}
+
+// secretFile writes content to a file with mode perm and returns its path.
+func secretFile(t *testing.T, content string, perm os.FileMode) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestResolverLookup(t *testing.T) {
+	t.Parallel()
+
+	store := fakeStore{
+		parameters: map[string]string{"/ttl/role": "from-parameter"},
+		secrets: map[string]string{
+			"ttl/token": "plain-secret",
+			"ttl/json":  `{"user":"admin","port":8080}`,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		environ []string
+		store   env.SecretStore
+		want    string
+		wantSet bool
+		wantErr error
+	}{
+		{name: "plain value", environ: []string{"KEY=value"}, store: store, want: "value", wantSet: true},
+		{name: "unset", environ: nil, store: store, want: "", wantSet: false},
+		{name: "empty", environ: []string{"KEY="}, store: store, want: "", wantSet: true},
+		{
+			name:    "ssm parameter",
+			environ: []string{"KEY=ssm-parameter:///ttl/role"},
+			store:   store, want: "from-parameter", wantSet: true,
+		},
+		{
+			name:    "secrets manager",
+			environ: []string{"KEY=secretsmanager://ttl/token"},
+			store:   store, want: "plain-secret", wantSet: true,
+		},
+		{
+			name:    "secrets manager field",
+			environ: []string{"KEY=secretsmanager://ttl/json#user"},
+			store:   store, want: "admin", wantSet: true,
+		},
+		{
+			name:    "non-string field",
+			environ: []string{"KEY=secretsmanager://ttl/json#port"},
+			store:   store, wantSet: true, wantErr: env.ErrInvalidReference,
+		},
+		{
+			name:    "missing field",
+			environ: []string{"KEY=secretsmanager://ttl/json#password"},
+			store:   store, wantSet: true, wantErr: env.ErrInvalidReference,
+		},
+		{
+			name:    "field of a non-JSON secret",
+			environ: []string{"KEY=secretsmanager://ttl/token#user"},
+			store:   store, wantSet: true, wantErr: env.ErrInvalidReference,
+		},
+		{
+			name:    "empty parameter name",
+			environ: []string{"KEY=ssm-parameter://"},
+			store:   store, wantSet: true, wantErr: env.ErrInvalidReference,
+		},
+		{
+			name:    "empty secret id",
+			environ: []string{"KEY=secretsmanager://#user"},
+			store:   store, wantSet: true, wantErr: env.ErrInvalidReference,
+		},
+		{
+			name:    "store error",
+			environ: []string{"KEY=ssm-parameter:///ttl/missing"},
+			store:   store, wantSet: true, wantErr: errNotFound,
+		},
+		{
+			name:    "no store",
+			environ: []string{"KEY=ssm-parameter:///ttl/role"},
+			store:   nil, wantSet: true, wantErr: env.ErrNoSecretStore,
+		},
+		{
+			name:    "no store without a reference",
+			environ: []string{"KEY=plain"},
+			store:   nil, want: "plain", wantSet: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, set, err := env.NewResolver(env.EnvironLookup(tt.environ), tt.store).Lookup(t.Context(), "KEY")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Lookup error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want || set != tt.wantSet {
+				t.Errorf("Lookup = %q, %t; want %q, %t", got, set, tt.want, tt.wantSet)
+			}
+		})
+	}
+}
+
+func TestResolverLookupFile(t *testing.T) {
+	t.Parallel()
+
+	store := fakeStore{parameters: map[string]string{"/ttl/role": "from-parameter"}, secrets: nil}
+
+	tests := []struct {
+		name    string
+		value   *string
+		content string
+		perm    os.FileMode
+		want    string
+		wantErr error
+	}{
+		{name: "file", content: "from-file\n", perm: 0o600, want: "from-file"},
+		{name: "crlf", content: "from-file\r\n", perm: 0o600, want: "from-file"},
+		{name: "world readable", content: "from-file", perm: 0o644, want: "from-file"},
+		{name: "empty variable beside the file", value: ptr(""), content: "from-file", perm: 0o600, want: "from-file"},
+		{name: "reference in the file", content: "ssm-parameter:///ttl/role\n", perm: 0o600, want: "from-parameter"},
+		{name: "group writable", content: "x", perm: 0o620, wantErr: env.ErrInsecureFile},
+		{name: "world writable", content: "x", perm: 0o602, wantErr: env.ErrInsecureFile},
+		{name: "ambiguous", value: ptr("inline"), content: "x", perm: 0o600, wantErr: env.ErrAmbiguousSource},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			environ := []string{"KEY_FILE=" + secretFile(t, tt.content, tt.perm)}
+			if tt.value != nil {
+				environ = append(environ, "KEY="+*tt.value)
+			}
+
+			got, set, err := env.NewResolver(env.EnvironLookup(environ), store).Lookup(t.Context(), "KEY")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Lookup error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want || !set {
+				t.Errorf("Lookup = %q, %t; want %q, true", got, set, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSecretFileRejects(t *testing.T) {
+	t.Parallel()
+
+	if _, err := env.ReadSecretFile(t.TempDir()); !errors.Is(err, env.ErrInsecureFile) {
+		t.Errorf("ReadSecretFile(directory) = %v, want %v", err, env.ErrInsecureFile)
+	}
+
+	if _, err := env.ReadSecretFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("ReadSecretFile(missing) succeeded")
+	}
+
+	if os.Getuid() != 0 {
+		t.Skip("changing a file's owner requires root")
+	}
+
+	path := secretFile(t, "x", 0o600)
+	if err := os.Chown(path, 4242, 4242); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env.ReadSecretFile(path); !errors.Is(err, env.ErrInsecureFile) {
+		t.Errorf("ReadSecretFile(foreign owner) = %v, want %v", err, env.ErrInsecureFile)
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
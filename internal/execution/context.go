@@ -5,16 +5,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/benwsapp/aws-ssm-minimal/internal"
-	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
 	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
 )
 
+// Region sources reported in Context.RegionSource.
+const (
+	RegionSourceMetadata = "ecs-task-metadata"
+	RegionSourceConfig   = "configuration"
+)
+
 // Context captures region and identity information for the running task.
 type Context struct {
 	Region           string
+	RegionSource     string
 	AvailabilityZone string
 	TaskARN          string
 }
@@ -22,11 +30,13 @@ type Context struct {
 // Provider discovers execution context information.
 type Provider struct {
 	MetadataProvider metadata.Provider
+	Settings         config.Metadata
 }
 
-// NewProvider constructs a Provider instance.
-func NewProvider(metadataProvider metadata.Provider) Provider {
-	return Provider{MetadataProvider: metadataProvider}
+// NewProvider constructs a Provider that queries the configured metadata endpoint and falls back
+// to the configured region, availability zone and task ARN.
+func NewProvider(metadataProvider metadata.Provider, settings config.Metadata) Provider {
+	return Provider{MetadataProvider: metadataProvider, Settings: settings}
 }
 
 // Discover returns the execution context for the current environment.
@@ -39,14 +49,14 @@ func (p Provider) Discover(ctx context.Context) (Context, error) {
 }
 
 func (p Provider) populateFromMetadata(ctx context.Context, execCtx *Context) {
-	metadataURI := env.GetString(internal.MetadataEnvKey)
+	metadataURI := p.Settings.URI
 	if metadataURI == "" {
 		return
 	}
 
 	meta, err := p.MetadataProvider.FetchTaskMetadata(ctx, metadataURI)
 	if err != nil {
-		log.Printf("warning: failed to load ECS task metadata: %v", err)
+		slog.Warn("failed to load ECS task metadata", logging.Err(err))
 
 		return
 	}
@@ -56,29 +66,27 @@ func (p Provider) populateFromMetadata(ctx context.Context, execCtx *Context) {
 
 	region, err := metadata.RegionFromTaskARN(meta.TaskARN)
 	if err != nil {
-		log.Printf("warning: unable to derive region from task ARN: %v", err)
+		slog.Warn("unable to derive region from task ARN", logging.Err(err))
 
 		return
 	}
 
 	execCtx.Region = region
+	execCtx.RegionSource = RegionSourceMetadata
 }
 
 func (p Provider) applyFallbacks(execCtx *Context) {
-	if execCtx.Region == "" {
-		execCtx.Region = env.GetString(internal.EnvFallbackRegion)
-	}
-
-	if execCtx.Region == "" {
-		execCtx.Region = env.GetString(internal.EnvFallbackDefaultRegion)
+	if execCtx.Region == "" && p.Settings.Region != "" {
+		execCtx.Region = p.Settings.Region
+		execCtx.RegionSource = RegionSourceConfig
 	}
 
 	if execCtx.AvailabilityZone == "" {
-		execCtx.AvailabilityZone = env.GetString(internal.EnvFallbackAvailabilityZone)
+		execCtx.AvailabilityZone = p.Settings.AvailabilityZone
 	}
 
 	if execCtx.TaskARN == "" {
-		execCtx.TaskARN = env.GetString(internal.EnvFallbackTaskARN)
+		execCtx.TaskARN = p.Settings.TaskARN
 	}
 }
 
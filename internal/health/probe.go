@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	probeTimeout   = 3 * time.Second
+	probeBodyLimit = 4_096
+	loopbackHost   = "127.0.0.1"
+)
+
+var (
+	errProbeStatus  = errors.New("health probe failed")
+	errProbeAddress = errors.New("invalid health listen address")
+)
+
+// Probe queries the health server listening on addr and returns an error unless path reports healthy.
+func Probe(parent context.Context, addr, path string) error {
+	target, err := probeURL(addr, path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(parent, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("build health probe request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", target, err)
+	}
+
+	defer func() {
+		closeErr := resp.Body.Close()
+		if closeErr != nil {
+			slog.Warn("failed to close health probe body", logging.Err(closeErr))
+		}
+	}()
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, probeBodyLimit))
+	if readErr != nil {
+		return fmt.Errorf("read health probe body: %w", readErr)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d body %s", errProbeStatus, resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// probeURL converts a listen address into a loopback URL for the given path.
+func probeURL(addr, path string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %w", errProbeAddress, addr, err)
+	}
+
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = loopbackHost
+	}
+
+	return "http://" + net.JoinHostPort(host, port) + path, nil
+}
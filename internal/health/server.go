@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	// LivenessPath reports whether the wrapper is alive.
+	LivenessPath = "/healthz"
+	// ReadinessPath reports whether the agent is registered and Online.
+	ReadinessPath = "/readyz"
+	// StatusPath returns the full lifecycle status as JSON.
+	StatusPath = "/status"
+
+	readHeaderTimeout = 5 * time.Second
+)
+
+// Server exposes lifecycle state over HTTP.
+type Server struct {
+	state    *State
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewServer constructs a Server bound to addr once started.
+func NewServer(addr string, state *State) *Server {
+	mux := http.NewServeMux()
+	srv := &Server{
+		state:    state,
+		mux:      mux,
+		listener: nil,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+
+	mux.HandleFunc(LivenessPath, srv.handleLiveness)
+	mux.HandleFunc(ReadinessPath, srv.handleReadiness)
+	mux.HandleFunc(StatusPath, srv.handleStatus)
+
+	return srv
+}
+
+// Handle registers an additional handler, such as the metrics endpoint, on the server.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start binds the listener and serves requests in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", s.server.Addr, err)
+	}
+
+	s.listener = listener
+	slog.Info("health server listening", slog.String("addr", listener.Addr().String()))
+
+	go func() {
+		serveErr := s.server.Serve(listener)
+		if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+			slog.Warn("health server stopped", logging.Err(serveErr))
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stops the server, waiting for in-flight requests until ctx expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.listener == nil {
+		return nil
+	}
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		return fmt.Errorf("shutdown health server: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) handleLiveness(w http.ResponseWriter, _ *http.Request) {
+	status := s.state.Snapshot()
+	writeStatus(w, status, status.Live)
+}
+
+func (s *Server) handleReadiness(w http.ResponseWriter, _ *http.Request) {
+	status := s.state.Snapshot()
+	writeStatus(w, status, status.Ready)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeStatus(w, s.state.Snapshot(), true)
+}
+
+func writeStatus(w http.ResponseWriter, status Status, healthy bool) {
+	code := http.StatusOK
+	if !healthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	encodeErr := json.NewEncoder(w).Encode(status)
+	if encodeErr != nil {
+		slog.Warn("failed to encode health status", logging.Err(encodeErr))
+	}
+}
@@ -0,0 +1,176 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlers(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	state := NewState()
+	state.now = func() time.Time { return now }
+	server := NewServer("127.0.0.1:0", state)
+
+	steps := []struct {
+		name      string
+		apply     func()
+		wantPhase string
+		live      bool
+		ready     bool
+	}{
+		{
+			name:      "starting",
+			apply:     func() {},
+			wantPhase: PhaseStarting,
+			live:      true,
+			ready:     false,
+		},
+		{
+			name:      "dormant",
+			apply:     func() { state.SetPhase(PhaseDormant) },
+			wantPhase: PhaseDormant,
+			live:      true,
+			ready:     true,
+		},
+		{
+			name: "registered",
+			apply: func() {
+				state.SetPhase(PhaseVerifyOnline)
+				state.SetActivationID("act-1")
+				state.SetManagedInstanceID("mi-0123456789abcdef0")
+				state.SetDeadline(now.Add(90 * time.Second))
+			},
+			wantPhase: PhaseVerifyOnline,
+			live:      true,
+			ready:     false,
+		},
+		{
+			name: "agent running but not online",
+			apply: func() {
+				state.SetChildPID(4242)
+				state.SetPingStatus("ConnectionLost")
+			},
+			wantPhase: PhaseVerifyOnline,
+			live:      true,
+			ready:     false,
+		},
+		{
+			name: "online",
+			apply: func() {
+				state.SetPhase(PhaseSupervise)
+				state.SetPingStatus("Online")
+			},
+			wantPhase: PhaseSupervise,
+			live:      true,
+			ready:     true,
+		},
+		{
+			name: "child stopped",
+			apply: func() {
+				state.SetChildPID(0)
+				state.IncrementRestarts()
+			},
+			wantPhase: PhaseSupervise,
+			live:      true,
+			ready:     false,
+		},
+		{
+			name:      "draining",
+			apply:     func() { state.SetPhase(PhaseDrain) },
+			wantPhase: PhaseDrain,
+			live:      true,
+			ready:     false,
+		},
+		{
+			name:      "failed",
+			apply:     func() { state.Fail(PhaseRegister) },
+			wantPhase: PhaseFailed,
+			live:      false,
+			ready:     false,
+		},
+	}
+
+	for _, step := range steps {
+		step.apply()
+
+		status := get(t, server, StatusPath, http.StatusOK)
+		if status.Phase != step.wantPhase || status.Live != step.live || status.Ready != step.ready {
+			t.Errorf("%s: status = %+v, want phase %s, live %t, ready %t",
+				step.name, status, step.wantPhase, step.live, step.ready)
+		}
+
+		get(t, server, LivenessPath, wantCode(step.live))
+		get(t, server, ReadinessPath, wantCode(step.ready))
+	}
+
+	status := get(t, server, StatusPath, http.StatusOK)
+	want := Status{
+		Phase:               PhaseFailed,
+		FailedPhase:         PhaseRegister,
+		ActivationID:        "act-1",
+		ManagedInstanceID:   "mi-0123456789abcdef0",
+		PingStatus:          "Online",
+		TTLRemainingSeconds: 90,
+		ChildPID:            0,
+		RestartCount:        1,
+		Live:                false,
+		Ready:               false,
+	}
+
+	if status != want {
+		t.Errorf("final status = %+v, want %+v", status, want)
+	}
+}
+
+func TestTTLRemainingNeverNegative(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	state := NewState()
+	state.now = func() time.Time { return now }
+
+	if got := state.Snapshot().TTLRemainingSeconds; got != 0 {
+		t.Errorf("remaining without a deadline = %d, want 0", got)
+	}
+
+	state.SetDeadline(now.Add(-time.Minute))
+
+	if got := state.Snapshot().TTLRemainingSeconds; got != 0 {
+		t.Errorf("remaining after the deadline = %d, want 0", got)
+	}
+}
+
+func get(t *testing.T, server *Server, path string, wantCode int) Status {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	server.mux.ServeHTTP(recorder, httptest.NewRequestWithContext(t.Context(), http.MethodGet, path, nil))
+
+	if recorder.Code != wantCode {
+		t.Errorf("GET %s = %d, want %d", path, recorder.Code, wantCode)
+	}
+
+	if got := recorder.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("GET %s content type = %q, want application/json", path, got)
+	}
+
+	var status Status
+	if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+		t.Fatalf("GET %s: decode status: %v", path, err)
+	}
+
+	return status
+}
+
+func wantCode(healthy bool) int {
+	if healthy {
+		return http.StatusOK
+	}
+
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,173 @@
+// Package health tracks wrapper lifecycle state and serves it over HTTP for container probes.
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Lifecycle phases reported by the wrapper, in the order they run. PhaseFailed replaces the
+// phase once the wrapper has cleaned up after a failure.
+const (
+	PhaseStarting        = "starting"
+	PhaseDormant         = "dormant"
+	PhaseDiscover        = "discover"
+	PhaseWaitReady       = "wait-ready"
+	PhaseActivate        = "activate"
+	PhaseRegister        = "register"
+	PhasePersistIdentity = "persist-identity"
+	PhaseVerifyOnline    = "verify-online"
+	PhaseSupervise       = "supervise"
+	PhaseDrain           = "drain"
+	PhaseCleanup         = "cleanup"
+	PhaseFailed          = "failed"
+)
+
+// Status is a point-in-time snapshot of the wrapper lifecycle.
+type Status struct {
+	Phase               string `json:"phase"`
+	FailedPhase         string `json:"failedPhase,omitempty"`
+	ActivationID        string `json:"activationId,omitempty"`
+	ManagedInstanceID   string `json:"managedInstanceId,omitempty"`
+	PingStatus          string `json:"pingStatus,omitempty"`
+	TTLRemainingSeconds int64  `json:"ttlRemainingSeconds"`
+	ChildPID            int    `json:"childPid,omitempty"`
+	RestartCount        int    `json:"restartCount"`
+	Live                bool   `json:"live"`
+	Ready               bool   `json:"ready"`
+}
+
+// State records lifecycle progress and is safe for concurrent use.
+type State struct {
+	mu sync.RWMutex
+
+	phase        string
+	failedPhase  string
+	activationID string
+	instanceID   string
+	pingStatus   string
+	deadline     time.Time
+	childPID     int
+	restarts     int
+	now          func() time.Time
+}
+
+// NewState returns a State in the starting phase.
+func NewState() *State {
+	return &State{
+		mu:           sync.RWMutex{},
+		phase:        PhaseStarting,
+		failedPhase:  "",
+		activationID: "",
+		instanceID:   "",
+		pingStatus:   "",
+		deadline:     time.Time{},
+		childPID:     0,
+		restarts:     0,
+		now:          time.Now,
+	}
+}
+
+// SetPhase records the current lifecycle phase.
+func (s *State) SetPhase(phase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.phase = phase
+}
+
+// Fail marks the wrapper failed because phase failed.
+func (s *State) Fail(phase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.phase = PhaseFailed
+	s.failedPhase = phase
+}
+
+// SetActivationID records the SSM activation backing this wrapper.
+func (s *State) SetActivationID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.activationID = id
+}
+
+// SetManagedInstanceID records the mi- identifier assigned at registration.
+func (s *State) SetManagedInstanceID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.instanceID = id
+}
+
+// SetPingStatus records the last SSM ping status observed for the managed instance.
+func (s *State) SetPingStatus(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pingStatus = status
+}
+
+// SetDeadline records when the TTL expires.
+func (s *State) SetDeadline(deadline time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadline = deadline
+}
+
+// SetChildPID records the supervised child process ID; zero marks the child as stopped.
+func (s *State) SetChildPID(pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.childPID = pid
+}
+
+// IncrementRestarts records a restart of the supervised child.
+func (s *State) IncrementRestarts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restarts++
+}
+
+// Snapshot returns the current status.
+func (s *State) Snapshot() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var remaining int64
+	if !s.deadline.IsZero() {
+		remaining = max(int64(s.deadline.Sub(s.now()).Seconds()), 0)
+	}
+
+	return Status{
+		Phase:               s.phase,
+		FailedPhase:         s.failedPhase,
+		ActivationID:        s.activationID,
+		ManagedInstanceID:   s.instanceID,
+		PingStatus:          s.pingStatus,
+		TTLRemainingSeconds: remaining,
+		ChildPID:            s.childPID,
+		RestartCount:        s.restarts,
+		Live:                s.phase != PhaseFailed,
+		Ready:               s.ready(),
+	}
+}
+
+// ready reports whether the agent is registered, running and reported Online by SSM, or the
+// wrapper is dormant and waiting to be woken as configured.
+func (s *State) ready() bool {
+	if s.phase == PhaseDormant {
+		return true
+	}
+
+	return (s.phase == PhaseVerifyOnline || s.phase == PhaseSupervise) &&
+		s.instanceID != "" &&
+		s.childPID > 0 &&
+		s.pingStatus == pingStatusOnline
+}
+
+const pingStatusOnline = "Online"
@@ -0,0 +1,236 @@
+// Package hooks runs operator-supplied executables or webhooks at wrapper lifecycle points.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+// Lifecycle events that can trigger a hook.
+const (
+	EventPostRegistration = "post-registration"
+	EventPreShutdown      = "pre-shutdown"
+	EventPostCleanup      = "post-cleanup"
+)
+
+// Policy controls whether a failing hook aborts the wrapper.
+type Policy string
+
+// Supported hook failure policies.
+const (
+	FailOpen   Policy = "fail-open"
+	FailClosed Policy = "fail-closed"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the webhook body when a secret is configured.
+	SignatureHeader = "X-Hook-Signature"
+
+	outputLimit     = 4_096
+	signaturePrefix = "sha256="
+)
+
+var (
+	errInvalidPolicy = errors.New("invalid hook policy")
+	errWebhookStatus = errors.New("unexpected webhook status")
+	errEmptyCommand  = errors.New("empty hook command")
+)
+
+// Hook is a single configured hook target.
+type Hook struct {
+	Event   string
+	Target  string
+	Timeout time.Duration
+	Policy  Policy
+	Secret  string
+	// Client posts webhooks; http.DefaultClient is used when nil.
+	Client *http.Client
+	// Environ is the environment executables run with, to which HOOK_EVENT is added.
+	Environ []string
+}
+
+// Execution describes where the wrapper is running.
+type Execution struct {
+	Region           string `json:"region"`
+	AvailabilityZone string `json:"availabilityZone,omitempty"`
+	TaskARN          string `json:"taskArn,omitempty"`
+}
+
+// Identity describes the SSM identity backing the wrapper.
+type Identity struct {
+	ActivationID      string `json:"activationId,omitempty"`
+	ManagedInstanceID string `json:"managedInstanceId,omitempty"`
+}
+
+// Payload is the JSON document delivered to every hook.
+type Payload struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Reason    string    `json:"reason,omitempty"`
+	Execution Execution `json:"execution"`
+	Identity  Identity  `json:"identity"`
+}
+
+// ParsePolicy validates a policy name, defaulting to fail-open.
+func ParsePolicy(value string) (Policy, error) {
+	switch Policy(strings.ToLower(strings.TrimSpace(value))) {
+	case "", FailOpen:
+		return FailOpen, nil
+	case FailClosed:
+		return FailClosed, nil
+	default:
+		return "", fmt.Errorf("%w: %q (want %s or %s)", errInvalidPolicy, value, FailOpen, FailClosed)
+	}
+}
+
+// Run delivers payload to the hook and logs the result. It returns an error only when the hook
+// failed under a fail-closed policy.
+func (h Hook) Run(parent context.Context, payload Payload) error {
+	if h.Target == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(parent, h.Timeout)
+	defer cancel()
+
+	payload.Event = h.Event
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s hook payload: %w", h.Event, err)
+	}
+
+	started := time.Now()
+	runErr := h.deliver(ctx, body)
+	attrs := []any{
+		slog.String("hook", h.Event),
+		slog.String("policy", string(h.Policy)),
+		slog.Duration("elapsed", time.Since(started)),
+	}
+
+	if runErr == nil {
+		slog.Info("hook succeeded", attrs...)
+
+		return nil
+	}
+
+	slog.Warn("hook failed", append(attrs, logging.Err(runErr))...)
+
+	if h.Policy == FailClosed {
+		return fmt.Errorf("%s hook: %w", h.Event, runErr)
+	}
+
+	return nil
+}
+
+func (h Hook) deliver(ctx context.Context, body []byte) error {
+	if isWebhook(h.Target) {
+		return h.postWebhook(ctx, body)
+	}
+
+	return h.runExecutable(ctx, body)
+}
+
+func isWebhook(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// runExecutable runs the target directly (there is no shell in the image) with the payload on stdin.
+func (h Hook) runExecutable(ctx context.Context, body []byte) error {
+	fields := strings.Fields(h.Target)
+	if len(fields) == 0 {
+		return errEmptyCommand
+	}
+
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...) // #nosec G204 -- hook target configured by operator
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(slices.Clone(h.Environ), "HOOK_EVENT="+h.Event)
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		slog.Info("hook output", slog.String("hook", h.Event), slog.String("output", truncate(output)))
+	}
+
+	if err != nil {
+		return fmt.Errorf("run %s: %w", fields[0], err)
+	}
+
+	return nil
+}
+
+func (h Hook) postWebhook(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hook-Event", h.Event)
+
+	if h.Secret != "" {
+		req.Header.Set(SignatureHeader, signaturePrefix+sign(h.Secret, body))
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+
+	defer func() {
+		closeErr := resp.Body.Close()
+		if closeErr != nil {
+			slog.Warn("failed to close webhook response body", logging.Err(closeErr))
+		}
+	}()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, outputLimit))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %d body %q", errWebhookStatus, resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func truncate(output []byte) string {
+	text := strings.TrimSpace(string(output))
+	if len(text) > outputLimit {
+		return text[:outputLimit] + "..."
+	}
+
+	return text
+}
+
+// Set groups the hooks for every lifecycle event; unconfigured hooks are no-ops.
+type Set struct {
+	PostRegistration Hook
+	PreShutdown      Hook
+	PostCleanup      Hook
+}
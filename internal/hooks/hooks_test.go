@@ -0,0 +1,204 @@
+package hooks_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/hooks"
+)
+
+// childCommand makes the test binary act as an executable hook that copies its stdin, HOOK_EVENT
+// and the PATH and MARKER variables to the file named by the next argument.
+const childCommand = "hook-child"
+
+func TestMain(m *testing.M) {
+	if len(os.Args) == 3 && os.Args[1] == childCommand {
+		payload, _ := io.ReadAll(os.Stdin)
+		doc, _ := json.Marshal(map[string]string{
+			"event":  os.Getenv("HOOK_EVENT"),
+			"stdin":  string(payload),
+			"path":   os.Getenv("PATH"),
+			"marker": os.Getenv("MARKER"),
+		})
+
+		if err := os.WriteFile(os.Args[2], doc, 0o600); err != nil {
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func payload() hooks.Payload {
+	return hooks.Payload{
+		Event:     "",
+		Time:      time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Reason:    "ttl expired",
+		Execution: hooks.Execution{Region: "us-east-1", AvailabilityZone: "", TaskARN: ""},
+		Identity:  hooks.Identity{ActivationID: "act", ManagedInstanceID: "mi-0123"},
+	}
+}
+
+func hook(target string, policy hooks.Policy, secret string) hooks.Hook {
+	return hooks.Hook{
+		Event:   hooks.EventPreShutdown,
+		Target:  target,
+		Timeout: 5 * time.Second,
+		Policy:  policy,
+		Secret:  secret,
+		Client:  nil,
+		Environ: nil,
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string]hooks.Policy{"": hooks.FailOpen, " Fail-Closed ": hooks.FailClosed} {
+		got, err := hooks.ParsePolicy(value)
+		if err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := hooks.ParsePolicy("ignore"); err == nil {
+		t.Error("ParsePolicy accepted an unknown policy")
+	}
+}
+
+func TestWebhookIsSigned(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		event     string
+		signature string
+		body      []byte
+	}
+
+	received := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{event: r.Header.Get("X-Hook-Event"), signature: r.Header.Get(hooks.SignatureHeader), body: body}
+	}))
+	defer server.Close()
+
+	err := hook(server.URL, hooks.FailClosed, "secret").Run(t.Context(), payload())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got := <-received
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(got.body)
+
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); got.signature != want {
+		t.Errorf("signature = %q, want %q", got.signature, want)
+	}
+
+	var delivered hooks.Payload
+	if err := json.Unmarshal(got.body, &delivered); err != nil || delivered.Event != hooks.EventPreShutdown ||
+		delivered.Reason != "ttl expired" || got.event != hooks.EventPreShutdown {
+		t.Errorf("delivered %s (event header %q), %v; want the pre-shutdown payload", got.body, got.event, err)
+	}
+}
+
+func TestFailurePolicy(t *testing.T) {
+	t.Parallel()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+
+	timedOut := hook(slow.URL, hooks.FailClosed, "")
+	timedOut.Timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		hook    hooks.Hook
+		wantErr bool
+	}{
+		{name: "unconfigured", hook: hook("", hooks.FailClosed, ""), wantErr: false},
+		{name: "webhook status fail-open", hook: hook(failing.URL, hooks.FailOpen, ""), wantErr: false},
+		{name: "webhook status fail-closed", hook: hook(failing.URL, hooks.FailClosed, ""), wantErr: true},
+		{name: "webhook timeout", hook: timedOut, wantErr: true},
+		{name: "executable fails", hook: hook("false", hooks.FailClosed, ""), wantErr: true},
+		{name: "executable fails open", hook: hook("false", hooks.FailOpen, ""), wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.hook.Run(t.Context(), payload())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlankExecutableFails(t *testing.T) {
+	t.Parallel()
+
+	if err := hook(" \t", hooks.FailClosed, "").Run(t.Context(), payload()); err == nil {
+		t.Error("Run of a blank command succeeded under fail-closed")
+	}
+}
+
+func TestExecutableReceivesPayload(t *testing.T) {
+	t.Parallel()
+
+	out := filepath.Join(t.TempDir(), "hook.json")
+
+	h := hook(os.Args[0]+" "+childCommand+" "+out, hooks.FailClosed, "")
+	h.Environ = []string{"MARKER=from-environ"}
+
+	err := h.Run(t.Context(), payload())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Event  string `json:"event"`
+		Stdin  string `json:"stdin"`
+		Path   string `json:"path"`
+		Marker string `json:"marker"`
+	}
+
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	var delivered hooks.Payload
+	if err := json.Unmarshal([]byte(got.Stdin), &delivered); err != nil || got.Event != hooks.EventPreShutdown ||
+		delivered.Identity.ManagedInstanceID != "mi-0123" {
+		t.Errorf("hook saw event %q and stdin %q (%v); want the pre-shutdown payload", got.Event, got.Stdin, err)
+	}
+
+	if got.Marker != "from-environ" || got.Path != "" {
+		t.Errorf("hook saw MARKER %q and PATH %q; want only the hook's own environment", got.Marker, got.Path)
+	}
+}
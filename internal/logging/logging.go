@@ -0,0 +1,161 @@
+// Package logging configures structured slog output with lifecycle correlation fields.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Supported output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Correlation field keys attached to every record once known.
+const (
+	KeyTaskARN           = "taskArn"
+	KeyRegion            = "region"
+	KeyActivationID      = "activationId"
+	KeyManagedInstanceID = "managedInstanceId"
+	KeyPhase             = "phase"
+)
+
+var (
+	errUnknownFormat = errors.New("unknown log format")
+	errUnknownLevel  = errors.New("unknown log level")
+)
+
+// Setup installs the default slog logger writing to w in the given format and minimum level.
+func Setup(w io.Writer, format, level string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{AddSource: false, Level: lvl, ReplaceAttr: nil}
+
+	var inner slog.Handler
+
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatText:
+		inner = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		inner = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("%w: %q (want %s or %s)", errUnknownFormat, format, FormatText, FormatJSON)
+	}
+
+	slog.SetDefault(slog.New(&correlationHandler{inner: inner, fields: defaultFields}))
+
+	return nil
+}
+
+// ParseLevel converts a level name such as "debug" or "warn" into a slog.Level.
+func ParseLevel(level string) (slog.Level, error) {
+	trimmed := strings.TrimSpace(level)
+	if trimmed == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var lvl slog.Level
+
+	err := lvl.UnmarshalText([]byte(trimmed))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q: %w", errUnknownLevel, level, err)
+	}
+
+	return lvl, nil
+}
+
+// Annotate attaches a correlation field to every subsequent record, replacing any previous value.
+func Annotate(key, value string) {
+	defaultFields.set(key, value)
+}
+
+// Forget removes the correlation fields under keys from subsequent records.
+func Forget(keys ...string) {
+	defaultFields.remove(keys...)
+}
+
+// Err returns an attribute for err under the conventional "error" key.
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+var defaultFields = &fields{mu: sync.RWMutex{}, attrs: nil}
+
+// fields holds correlation attributes shared by every logger derived from the default.
+type fields struct {
+	mu    sync.RWMutex
+	attrs []slog.Attr
+}
+
+func (f *fields) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, attr := range f.attrs {
+		if attr.Key == key {
+			f.attrs[i] = slog.String(key, value)
+
+			return
+		}
+	}
+
+	f.attrs = append(f.attrs, slog.String(key, value))
+}
+
+func (f *fields) remove(keys ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attrs = slices.DeleteFunc(f.attrs, func(attr slog.Attr) bool { return slices.Contains(keys, attr.Key) })
+}
+
+func (f *fields) snapshot() []slog.Attr {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return append([]slog.Attr(nil), f.attrs...)
+}
+
+// correlationHandler adds the current correlation fields to each record before delegating.
+type correlationHandler struct {
+	inner  slog.Handler
+	fields *fields
+}
+
+func (h *correlationHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+//nolint:gocritic // slog.Handler requires a value receiver for the record.
+func (h *correlationHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := h.fields.snapshot()
+	if len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+
+	err := h.inner.Handle(ctx, record)
+	if err != nil {
+		return fmt.Errorf("handle log record: %w", err)
+	}
+
+	return nil
+}
+
+func (h *correlationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &correlationHandler{inner: h.inner.WithAttrs(attrs), fields: h.fields}
+}
+
+func (h *correlationHandler) WithGroup(name string) slog.Handler {
+	return &correlationHandler{inner: h.inner.WithGroup(name), fields: h.fields}
+}
@@ -7,15 +7,23 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
 )
 
 const (
 	metadataPathSuffix = "/task"
 	statusBodyLimit    = 4_096
 	arnRegionIndex     = 3
+
+	// ContainerStatusStopped is the KnownStatus of a container that has exited.
+	ContainerStatusStopped = "STOPPED"
+
+	// HealthStatusHealthy is the health status of a container passing its health check.
+	HealthStatusHealthy = "HEALTHY"
 )
 
 var (
@@ -25,10 +33,27 @@ var (
 	errMetadataRegion     = errors.New("invalid task ARN region")
 )
 
-// TaskMetadata represents the subset of ECS metadata used for registration.
+// TaskMetadata represents the subset of ECS metadata used for registration and for watching the
+// task's other containers.
 type TaskMetadata struct {
-	AvailabilityZone string `json:"AvailabilityZone"` //nolint:tagliatelle // AWS metadata casing
-	TaskARN          string `json:"TaskARN"`          //nolint:tagliatelle // AWS metadata casing
+	AvailabilityZone string      `json:"AvailabilityZone"` //nolint:tagliatelle // AWS metadata casing
+	TaskARN          string      `json:"TaskARN"`          //nolint:tagliatelle // AWS metadata casing
+	Containers       []Container `json:"Containers"`       //nolint:tagliatelle // AWS metadata casing
+}
+
+// Container is one container of the task as the metadata endpoint last saw it.
+type Container struct {
+	Name        string `json:"Name"`        //nolint:tagliatelle // AWS metadata casing
+	KnownStatus string `json:"KnownStatus"` //nolint:tagliatelle // AWS metadata casing
+	// ExitCode is set once the container has stopped.
+	ExitCode *int `json:"ExitCode"` //nolint:tagliatelle // AWS metadata casing
+	// Health is set for containers with a health check.
+	Health *ContainerHealth `json:"Health"` //nolint:tagliatelle // AWS metadata casing
+}
+
+// ContainerHealth is the result of a container's health check.
+type ContainerHealth struct {
+	Status string `json:"status"`
 }
 
 // Provider retrieves ECS task metadata.
@@ -41,10 +66,14 @@ type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-// NewProvider constructs a Provider instance.
+// NewProvider constructs a Provider instance. The default client never uses a proxy: the task
+// metadata endpoint is link-local and must not leave the host.
 func NewProvider(client HTTPClient) Provider {
 	if client == nil {
-		client = http.DefaultClient
+		transport, _ := http.DefaultTransport.(*http.Transport)
+		transport = transport.Clone()
+		transport.Proxy = nil
+		client = &http.Client{Transport: transport}
 	}
 
 	return Provider{Client: client}
@@ -67,7 +96,7 @@ func (p Provider) FetchTaskMetadata(ctx context.Context, baseURI string) (TaskMe
 	defer func() {
 		closeErr := resp.Body.Close()
 		if closeErr != nil {
-			log.Printf("warning: failed to close metadata response body: %v", closeErr)
+			slog.Warn("failed to close metadata response body", logging.Err(closeErr))
 		}
 	}()
 
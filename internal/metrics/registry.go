@@ -0,0 +1,228 @@
+// Package metrics implements a minimal Prometheus text exposition registry for wrapper metrics.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	kindCounter = "counter"
+	kindGauge   = "gauge"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Registry holds metric families and renders them in the Prometheus text format.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{mu: sync.Mutex{}, families: nil}
+}
+
+type family struct {
+	mu sync.Mutex
+
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]*series
+	fn     func() float64
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	family *family
+}
+
+// GaugeVec is a gauge partitioned by label values.
+type GaugeVec struct {
+	family *family
+}
+
+// NewCounterVec registers a counter family with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) CounterVec {
+	return CounterVec{family: r.register(name, help, kindCounter, labels, nil)}
+}
+
+// NewGaugeVec registers a gauge family with the given label names.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) GaugeVec {
+	return GaugeVec{family: r.register(name, help, kindGauge, labels, nil)}
+}
+
+// NewGaugeFunc registers a gauge whose value is computed by fn at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, kindGauge, nil, fn)
+}
+
+// NewCounterFunc registers a counter whose value is computed by fn at scrape time.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, help, kindCounter, nil, fn)
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, fn func() float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.families {
+		if existing.name == name {
+			existing.fn = fn
+
+			return existing
+		}
+	}
+
+	fam := &family{
+		mu:     sync.Mutex{},
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series),
+		fn:     fn,
+	}
+	r.families = append(r.families, fam)
+
+	return fam
+}
+
+// Inc increments the counter for the given label values by one.
+func (c CounterVec) Inc(labelValues ...string) {
+	c.family.update(labelValues, func(s *series) { s.value++ })
+}
+
+// Set stores value for the given label values.
+func (g GaugeVec) Set(value float64, labelValues ...string) {
+	g.family.update(labelValues, func(s *series) { s.value = value })
+}
+
+func (f *family) update(labelValues []string, apply func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		slog.Warn("metric label mismatch",
+			slog.String("metric", f.name),
+			slog.Int("want", len(f.labels)),
+			slog.Int("got", len(labelValues)))
+
+		return
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.series[key]
+	if !ok {
+		entry = &series{labelValues: slices.Clone(labelValues), value: 0}
+		f.series[key] = entry
+	}
+
+	apply(entry)
+}
+
+// WriteText renders every registered family in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := slices.Clone(r.families)
+	r.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+
+	for _, fam := range families {
+		fam.writeText(buf)
+	}
+
+	err := buf.Flush()
+	if err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+
+	return nil
+}
+
+func (f *family) writeText(w *bufio.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	if f.fn != nil {
+		_, _ = fmt.Fprintf(w, "%s %s\n", f.name, formatValue(f.fn()))
+
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		entry := f.series[key]
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", f.name, f.formatLabels(entry.labelValues), formatValue(entry.value))
+	}
+}
+
+func (f *family) formatLabels(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(values))
+	for i, value := range values {
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabelValue(value)+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+
+		err := r.WriteText(w)
+		if err != nil {
+			slog.Warn("failed to serve metrics", logging.Err(err))
+		}
+	})
+}
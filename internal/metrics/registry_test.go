@@ -0,0 +1,96 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
+)
+
+func TestWriteText(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+
+	calls := registry.NewCounterVec("test_calls_total", "Calls by operation\nand outcome.", "operation", "outcome")
+	calls.Inc("Register", "success")
+	calls.Inc("Activate", "failure")
+	calls.Inc("Activate", "failure")
+	calls.Inc("Activate")
+
+	phases := registry.NewGaugeVec("test_phase_seconds", `Phase duration in seconds, with a \ in the help.`, "phase")
+	phases.Set(1.5, "discover")
+	phases.Set(0.25, `wait "ready"`+"\n")
+
+	registry.NewGaugeFunc("test_ttl_seconds", "Remaining TTL.", func() float64 { return 42 })
+	registry.NewCounterFunc("test_restarts_total", "Child restarts.", func() float64 { return 3 })
+	registry.NewCounterVec("test_empty_total", "Never incremented.", "outcome")
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP test_calls_total Calls by operation\nand outcome.
+# TYPE test_calls_total counter
+test_calls_total{operation="Activate",outcome="failure"} 2
+test_calls_total{operation="Register",outcome="success"} 1
+# HELP test_phase_seconds Phase duration in seconds, with a \\ in the help.
+# TYPE test_phase_seconds gauge
+test_phase_seconds{phase="discover"} 1.5
+test_phase_seconds{phase="wait \"ready\"\n"} 0.25
+# HELP test_ttl_seconds Remaining TTL.
+# TYPE test_ttl_seconds gauge
+test_ttl_seconds 42
+# HELP test_restarts_total Child restarts.
+# TYPE test_restarts_total counter
+test_restarts_total 3
+# HELP test_empty_total Never incremented.
+# TYPE test_empty_total counter
+`
+	if got := out.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegisterSameNameReturnsExistingFamily(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	registry.NewCounterVec("test_total", "First.", "outcome").Inc("success")
+	registry.NewCounterVec("test_total", "Second.", "outcome").Inc("success")
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	if strings.Count(got, "# TYPE test_total") != 1 || !strings.Contains(got, `test_total{outcome="success"} 2`) {
+		t.Errorf("exposition = %q, want one family counting both increments", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	registry.NewGaugeFunc("test_up", "Up.", func() float64 { return 1 })
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", recorder.Code)
+	}
+
+	if got := recorder.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type = %q, want the Prometheus text format", got)
+	}
+
+	if body := recorder.Body.String(); !strings.HasSuffix(body, "test_up 1\n") {
+		t.Errorf("body = %q, want the gauge sample", body)
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import "time"
+
+// Phase labels used with PhaseDuration.
+const (
+	PhaseDiscovery      = "discovery"
+	PhaseCredentials    = "credentials"
+	PhaseEndpointWarmup = "endpoint_warmup"
+	PhasePrepare        = "prepare"
+	PhaseActivation     = "activation"
+	PhaseRegistration   = "registration"
+	PhaseOnline         = "time_to_online"
+)
+
+// Outcome labels shared by call and cleanup counters.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeSkipped = "skipped"
+)
+
+// Default is the registry served on the wrapper's /metrics endpoint.
+var Default = NewRegistry()
+
+var (
+	phaseDuration = Default.NewGaugeVec(
+		"ssm_wrapper_phase_duration_seconds",
+		"Duration of each wrapper lifecycle phase in seconds.",
+		"phase",
+	)
+	awsCalls = Default.NewCounterVec(
+		"ssm_wrapper_aws_calls_total",
+		"AWS API calls made by the wrapper, by operation and outcome.",
+		"operation", "outcome",
+	)
+	registrations = Default.NewCounterVec(
+		"ssm_wrapper_registrations_total",
+		"Agent registrations run by the wrapper, by outcome.",
+		"outcome",
+	)
+	cleanupOutcomes = Default.NewCounterVec(
+		"ssm_wrapper_cleanup_total",
+		"Cleanup steps performed on shutdown, by step and outcome.",
+		"step", "outcome",
+	)
+)
+
+// ObservePhase records how long a lifecycle phase took.
+func ObservePhase(phase string, elapsed time.Duration) {
+	phaseDuration.Set(elapsed.Seconds(), phase)
+}
+
+// ObserveAWSCall counts an AWS API call as a success or failure based on err.
+func ObserveAWSCall(operation string, err error) {
+	awsCalls.Inc(operation, outcomeOf(err))
+}
+
+// ObserveRegistration counts an amazon-ssm-agent -register run as a success or failure based on
+// err. The run may fail locally without reaching AWS, so it is not counted as an AWS call.
+func ObserveRegistration(err error) {
+	registrations.Inc(outcomeOf(err))
+}
+
+// ObserveCleanup counts a cleanup step outcome.
+func ObserveCleanup(step, outcome string) {
+	cleanupOutcomes.Inc(step, outcome)
+}
+
+func outcomeOf(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+
+	return OutcomeSuccess
+}
@@ -0,0 +1,308 @@
+// Package proxy decides which outbound requests go through the configured HTTP proxy and renders
+// the matching environment for child processes.
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+)
+
+const (
+	decisionDirect = "direct"
+	wildcard       = "*"
+)
+
+// AlwaysExempt lists destinations that never use a proxy: the ECS task metadata and credential
+// endpoint, IMDS over IPv4 and IPv6, the link-local ranges they live in, and loopback.
+var AlwaysExempt = []string{
+	"169.254.170.2",
+	"169.254.169.254",
+	"fd00:ec2::254",
+	"169.254.0.0/16",
+	"fe80::/10",
+	"localhost",
+	"127.0.0.0/8",
+	"::1",
+}
+
+// environKeys are the proxy variables removed from inherited environments, compared case-insensitively.
+var environKeys = []string{
+	internal.EnvHTTPSProxy,
+	internal.EnvHTTPProxy,
+	internal.EnvNoProxy,
+	"ALL_PROXY",
+}
+
+// Proxy routes requests through the configured proxies unless NO_PROXY or AlwaysExempt matches.
+type Proxy struct {
+	https   *url.URL
+	http    *url.URL
+	noProxy []string
+	logged  sync.Map
+}
+
+// New parses settings. Empty proxy URLs mean direct connections for that scheme.
+func New(settings config.Proxy) (*Proxy, error) {
+	httpsURL, err := parseURL(settings.HTTPS)
+	if err != nil {
+		return nil, fmt.Errorf("parse https proxy: %w", err)
+	}
+
+	httpURL, err := parseURL(settings.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("parse http proxy: %w", err)
+	}
+
+	noProxy := append(splitList(settings.NoProxy), AlwaysExempt...)
+
+	return &Proxy{https: httpsURL, http: httpURL, noProxy: noProxy, logged: sync.Map{}}, nil
+}
+
+// FromEnvironment builds a Proxy from HTTPS_PROXY, HTTP_PROXY and NO_PROXY (or their lowercase
+// forms), for commands that run without the wrapper configuration.
+func FromEnvironment() (*Proxy, error) {
+	return New(config.Proxy{
+		HTTPS:   getenv(internal.EnvHTTPSProxy),
+		HTTP:    getenv(internal.EnvHTTPProxy),
+		NoProxy: getenv(internal.EnvNoProxy),
+	})
+}
+
+// Func returns the proxy for req, or nil for a direct connection. It has the signature of
+// http.Transport.Proxy.
+func (p *Proxy) Func(req *http.Request) (*url.URL, error) {
+	host := req.URL.Hostname()
+
+	target := p.http
+	if req.URL.Scheme == "https" || req.URL.Scheme == "wss" {
+		target = p.https
+	}
+
+	if target == nil {
+		p.logDecision(host, decisionDirect, "no proxy configured for "+req.URL.Scheme)
+
+		return nil, nil //nolint:nilnil // nil URL means connect directly, as http.Transport expects
+	}
+
+	if entry, ok := p.exempt(host); ok {
+		p.logDecision(host, decisionDirect, "matches no-proxy entry "+entry)
+
+		return nil, nil //nolint:nilnil // nil URL means connect directly, as http.Transport expects
+	}
+
+	p.logDecision(host, target.Redacted(), "")
+
+	return target, nil
+}
+
+// Transport returns a clone of http.DefaultTransport that routes through p.
+func (p *Proxy) Transport() *http.Transport {
+	transport, _ := http.DefaultTransport.(*http.Transport)
+	transport = transport.Clone()
+	transport.Proxy = p.Func
+
+	return transport
+}
+
+// Client returns an HTTP client using Transport.
+func (p *Proxy) Client() *http.Client {
+	return &http.Client{Transport: p.Transport()}
+}
+
+// Environ returns base without any inherited proxy variables, plus the configured proxies and a
+// NO_PROXY that always includes AlwaysExempt. Both upper and lowercase names are set because
+// tools disagree on which they read.
+func (p *Proxy) Environ(base []string) []string {
+	return p.environ(base, (*url.URL).String)
+}
+
+// RedactedEnviron is the proxy environment Environ adds, with credentials redacted for display.
+func (p *Proxy) RedactedEnviron() []string {
+	return p.environ(nil, (*url.URL).Redacted)
+}
+
+func (p *Proxy) environ(base []string, render func(*url.URL) string) []string {
+	env := make([]string, 0, len(base))
+
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if !IsEnvironKey(key) {
+			env = append(env, kv)
+		}
+	}
+
+	set := func(key, value string) {
+		env = append(env, key+"="+value, strings.ToLower(key)+"="+value)
+	}
+
+	if p.https != nil {
+		set(internal.EnvHTTPSProxy, render(p.https))
+	}
+
+	if p.http != nil {
+		set(internal.EnvHTTPProxy, render(p.http))
+	}
+
+	set(internal.EnvNoProxy, strings.Join(p.noProxy, ","))
+
+	return env
+}
+
+// Description is the effective proxy configuration with credentials redacted.
+type Description struct {
+	HTTPS   string `json:"https"`
+	HTTP    string `json:"http"`
+	NoProxy string `json:"noProxy"`
+}
+
+// Describe returns the effective configuration with credentials redacted.
+func (p *Proxy) Describe() Description {
+	return Description{HTTPS: redacted(p.https), HTTP: redacted(p.http), NoProxy: strings.Join(p.noProxy, ",")}
+}
+
+// LogSummary logs the effective proxy configuration with credentials redacted.
+func (p *Proxy) LogSummary() {
+	description := p.Describe()
+
+	slog.Info("proxy configuration",
+		slog.String("https", description.HTTPS),
+		slog.String("http", description.HTTP),
+		slog.String("noProxy", description.NoProxy))
+}
+
+// exempt reports the no-proxy entry matching host, if any.
+func (p *Proxy) exempt(host string) (string, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	if ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+		return "link-local or loopback address", true
+	}
+
+	for _, entry := range p.noProxy {
+		if matches(entry, host, ip) {
+			return entry, true
+		}
+	}
+
+	return "", false
+}
+
+// logDecision logs the first decision made for each host.
+func (p *Proxy) logDecision(host, via, reason string) {
+	_, seen := p.logged.LoadOrStore(host, struct{}{})
+	if seen {
+		return
+	}
+
+	attrs := []any{slog.String("host", host), slog.String("via", via)}
+	if reason != "" {
+		attrs = append(attrs, slog.String("reason", reason))
+	}
+
+	slog.Info("proxy decision", attrs...)
+}
+
+// matches applies NO_PROXY semantics: "*" matches everything, CIDRs and IPs match addresses, and
+// a name matches itself and its subdomains, with or without a leading dot. Ports are ignored.
+func matches(entry, host string, ip net.IP) bool {
+	entry = strings.ToLower(entry)
+
+	if entry == wildcard {
+		return true
+	}
+
+	if _, network, err := net.ParseCIDR(entry); err == nil {
+		return ip != nil && network.Contains(ip)
+	}
+
+	if entryHost, _, err := net.SplitHostPort(entry); err == nil {
+		entry = entryHost
+	}
+
+	if entryIP := net.ParseIP(entry); entryIP != nil {
+		return ip != nil && entryIP.Equal(ip)
+	}
+
+	entry = strings.TrimPrefix(entry, ".")
+
+	return host == entry || strings.HasSuffix(host, "."+entry)
+}
+
+func parseURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil //nolint:nilnil // no proxy configured
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		// url.Error repeats the raw URL, credentials included.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return nil, fmt.Errorf("parse %q: %w", redactRaw(raw), err)
+	}
+
+	return parsed, nil
+}
+
+func splitList(value string) []string {
+	var entries []string
+
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// IsEnvironKey reports whether key is a proxy variable such as HTTPS_PROXY, in any case.
+func IsEnvironKey(key string) bool {
+	for _, proxyKey := range environKeys {
+		if strings.EqualFold(key, proxyKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getenv(key string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+
+	return strings.TrimSpace(os.Getenv(strings.ToLower(key)))
+}
+
+func redacted(u *url.URL) string {
+	if u == nil {
+		return decisionDirect
+	}
+
+	return u.Redacted()
+}
+
+// redactRaw hides credentials in a URL that failed to parse.
+func redactRaw(raw string) string {
+	if at := strings.LastIndex(raw, "@"); at >= 0 {
+		return "xxxxx" + raw[at:]
+	}
+
+	return raw
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/control"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+// agentLogging owns the agent seelog settings so control requests can change them at runtime.
+type agentLogging struct {
+	mu       sync.Mutex
+	settings config.Agent
+	restarts chan struct{}
+}
+
+func newAgentLogging(settings config.Agent) *agentLogging {
+	return &agentLogging{mu: sync.Mutex{}, settings: settings, restarts: make(chan struct{}, 1)}
+}
+
+// apply validates and renders the requested settings, then optionally asks the supervisor to
+// restart the agent. Without a restart the agent picks the new file up through its own watcher.
+func (l *agentLogging) apply(req control.LoggingRequest) (control.LoggingResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next := l.settings
+	next.LogLevel = valueOr(req.Level, next.LogLevel)
+	next.LogOutput = valueOr(req.Output, next.LogOutput)
+
+	err := config.ValidateAgentLogging(next)
+	if err != nil {
+		return control.LoggingResponse{}, err
+	}
+
+	files, err := agentconfig.WriteSeelog(next.ConfigDir, next)
+	if err != nil {
+		return control.LoggingResponse{}, fmt.Errorf("render seelog config: %w", err)
+	}
+
+	l.settings = next
+	slog.Info("updated agent logging",
+		slog.String("level", next.LogLevel),
+		slog.String("output", next.LogOutput))
+
+	restarted := false
+
+	if req.Restart {
+		select {
+		case l.restarts <- struct{}{}:
+			restarted = true
+		default:
+		}
+	}
+
+	return control.LoggingResponse{
+		Level:     next.LogLevel,
+		Output:    next.LogOutput,
+		Files:     files,
+		Restarted: restarted,
+	}, nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+// startControlServer serves control requests on socketPath, including wake requests when wake is
+// set; failures are logged, not fatal.
+func startControlServer(socketPath string, agentLog *agentLogging, wake *waker) *control.Server {
+	if socketPath == "" {
+		return nil
+	}
+
+	server := control.NewServer(socketPath)
+	server.Handle(control.AgentLoggingPath, control.LoggingHandler(agentLog.apply))
+
+	if wake != nil {
+		server.Handle(control.WakePath, control.WakeHandler(wake.wake))
+	}
+
+	err := server.Start()
+	if err != nil {
+		slog.Warn("control socket unavailable", logging.Err(err))
+
+		return nil
+	}
+
+	return server
+}
+
+func stopControlServer(server *control.Server) {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		slog.Warn("failed to stop control server", logging.Err(err))
+	}
+}
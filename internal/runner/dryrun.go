@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+)
+
+// Placeholders for values only SSM can issue.
+const (
+	planActivationID   = "<activation-id>"
+	planActivationCode = "<activation-code>"
+)
+
+// plan is everything the wrapper would do, as printed by --dry-run.
+type plan struct {
+	ExecutionContext      planContext                `json:"executionContext"`
+	CreateActivationInput *ssm.CreateActivationInput `json:"createActivationInput"`
+	ActivationExpiresAt   time.Time                  `json:"activationExpiresAt"`
+	AgentConfig           agentconfig.AppConfig      `json:"agentConfig"`
+	AgentEnvironment      []string                   `json:"agentEnvironment"`
+	Proxy                 proxy.Description          `json:"proxy"`
+	RegistrationCommand   []string                   `json:"registrationCommand"`
+	ServiceCommand        []string                   `json:"serviceCommand"`
+	Paths                 planPaths                  `json:"paths"`
+	TTLSeconds            int64                      `json:"ttlSeconds"`
+	Deadline              config.Deadline            `json:"deadline"`
+	ShutdownGraceSeconds  int64                      `json:"shutdownGraceSeconds"`
+}
+
+type planContext struct {
+	Region           string `json:"region"`
+	RegionSource     string `json:"regionSource"`
+	AvailabilityZone string `json:"availabilityZone"`
+	TaskARN          string `json:"taskArn"`
+}
+
+type planPaths struct {
+	RegistrationFile string   `json:"registrationFile"`
+	AgentConfig      string   `json:"agentConfig"`
+	State            []string `json:"state"`
+}
+
+// printPlan resolves the execution context and writes the activation plan to w. It makes no AWS
+// calls; only the ECS task metadata endpoint is queried. The exit codes are those a real run would
+// fail with at the same step.
+func printPlan(ctx context.Context, w io.Writer, cfg config.Config, command []string) (int, error) {
+	execCtx, err := discoverExecutionContext(ctx, cfg.Metadata)
+	if err != nil {
+		return ExitDiscover, err
+	}
+
+	px, err := proxy.New(cfg.Proxy)
+	if err != nil {
+		return ExitFailure, fmt.Errorf("configure proxy: %w", err)
+	}
+
+	p := plan{
+		ExecutionContext:      newPlanContext(execCtx),
+		CreateActivationInput: activation.NewService(nil, cfg.Activation).BuildInput(cfg.ManagedInstanceRole, execCtx),
+		ActivationExpiresAt:   time.Now().Add(activation.DefaultExpiry).UTC().Truncate(time.Second),
+		AgentConfig:           agentconfig.Build(cfg.Agent, cfg.AWS.Endpoints),
+		AgentEnvironment:      append(px.RedactedEnviron(), agentconfig.Environ(cfg.AWS)...),
+		Proxy:                 px.Describe(),
+		RegistrationCommand: slices.Concat(
+			command[:1],
+			ssmagent.RegistrationArgs(execCtx.Region, planActivationID, planActivationCode),
+		),
+		ServiceCommand: command,
+		Paths: planPaths{
+			RegistrationFile: cfg.RegistrationFile,
+			AgentConfig:      filepath.Join(cfg.Agent.ConfigDir, agentconfig.AppConfigFileName),
+			State:            StatePaths(),
+		},
+		TTLSeconds:           int64(planTTL(cfg).Seconds()),
+		Deadline:             cfg.Deadline,
+		ShutdownGraceSeconds: int64(cfg.ShutdownGrace.Std().Seconds()),
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	err = encoder.Encode(p)
+	if err != nil {
+		return ExitFailure, fmt.Errorf("write plan: %w", err)
+	}
+
+	return ExitOK, nil
+}
+
+func newPlanContext(execCtx execution.Context) planContext {
+	return planContext{
+		Region:           execCtx.Region,
+		RegionSource:     execCtx.RegionSource,
+		AvailabilityZone: execCtx.AvailabilityZone,
+		TaskARN:          execCtx.TaskARN,
+	}
+}
+
+// planTTL is the configured TTL, or the time left until the deadline when one is set.
+func planTTL(cfg config.Config) time.Duration {
+	if cfg.Deadline.IsZero() {
+		return cfg.TTL.Std()
+	}
+
+	return time.Until(cfg.Deadline.Std())
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+)
+
+func TestPrintPlan(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.ManagedInstanceRole = "role"
+	cfg.Metadata.Region = "eu-west-1"
+
+	var out bytes.Buffer
+
+	code, err := printPlan(t.Context(), &out, cfg, []string{"/service/amazon-ssm-agent"})
+	if err != nil || code != ExitOK {
+		t.Fatalf("printPlan = %d, %v; want %d", code, err, ExitOK)
+	}
+
+	var got struct {
+		ActivationExpiresAt time.Time `json:"activationExpiresAt"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if until := time.Until(got.ActivationExpiresAt); until <= activation.DefaultExpiry-time.Minute || until > activation.DefaultExpiry {
+		t.Errorf("activationExpiresAt = %s, want about %s from now", got.ActivationExpiresAt, activation.DefaultExpiry)
+	}
+}
+
+func TestPrintPlanExitCodes(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.ManagedInstanceRole = "role"
+	cfg.Metadata.Region = "eu-west-1"
+	cfg.Proxy.HTTPS = "http://proxy:port"
+
+	if code, err := printPlan(t.Context(), &bytes.Buffer{}, cfg, []string{"agent"}); err == nil || code != ExitFailure {
+		t.Errorf("printPlan with a bad proxy = %d, %v; want %d", code, err, ExitFailure)
+	}
+
+	cfg.Metadata.Region = ""
+
+	if code, err := printPlan(t.Context(), &bytes.Buffer{}, cfg, []string{"agent"}); err == nil || code != ExitDiscover {
+		t.Errorf("printPlan without a region = %d, %v; want %d", code, err, ExitDiscover)
+	}
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"errors"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+)
+
+// Exit codes returned by Run. Each failure has its own code so that the reason a task stopped can
+// be read from the container's exit code alone.
+const (
+	// ExitOK means the TTL or deadline ended the session, or the agent exited with status 0 on its
+	// own or after a forwarded signal. The TTL is the expected ending, so it is not a failure to an
+	// orchestrator; the termination report's reason tells these cases apart.
+	ExitOK = 0
+	// ExitFailure is any other setup error, such as an unusable proxy or hook configuration.
+	ExitFailure = 1
+	// ExitConfig means the configuration or command line was invalid.
+	ExitConfig = 2
+
+	ExitDiscover            = 20
+	ExitActivate            = 21
+	ExitActivationDenied    = 22
+	ExitActivationThrottled = 23
+	ExitRegister            = 24
+	ExitPersistIdentity     = 25
+	ExitVerifyOnline        = 26
+	ExitAgentUnhealthy      = 27
+	// ExitChildCrashed means the agent exited with a non-zero status without being asked to stop
+	// by the TTL, or could not be supervised.
+	ExitChildCrashed = 28
+	ExitDrain        = 29
+	ExitCleanup      = 30
+	// ExitNotReady means the application was not ready in time and the ready policy is fail.
+	ExitNotReady = 31
+)
+
+// exitReasons names every exit code in the termination report.
+var exitReasons = map[int]string{
+	ExitOK:                  "agent-exited",
+	ExitFailure:             "setup-failed",
+	ExitConfig:              "config-error",
+	ExitDiscover:            "discovery-failed",
+	ExitActivate:            "activation-failed",
+	ExitActivationDenied:    "activation-denied",
+	ExitActivationThrottled: "activation-throttled",
+	ExitRegister:            "registration-failed",
+	ExitPersistIdentity:     "persist-identity-failed",
+	ExitVerifyOnline:        "online-timeout",
+	ExitAgentUnhealthy:      "agent-unhealthy",
+	ExitChildCrashed:        "child-crashed",
+	ExitDrain:               "drain-failed",
+	ExitCleanup:             "cleanup-failed",
+	ExitNotReady:            "not-ready",
+}
+
+var phaseExitCodes = map[string]int{
+	health.PhaseDiscover:        ExitDiscover,
+	health.PhaseWaitReady:       ExitNotReady,
+	health.PhaseActivate:        ExitActivate,
+	health.PhaseRegister:        ExitRegister,
+	health.PhasePersistIdentity: ExitPersistIdentity,
+	health.PhaseVerifyOnline:    ExitVerifyOnline,
+	health.PhaseSupervise:       ExitChildCrashed,
+	health.PhaseDrain:           ExitDrain,
+	health.PhaseCleanup:         ExitCleanup,
+}
+
+// exitCode returns the exit code for err: a specific code for recognised causes, otherwise the
+// failing phase's code, or ExitFailure for errors raised outside the lifecycle.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, activation.ErrDenied):
+		return ExitActivationDenied
+	case errors.Is(err, activation.ErrThrottled):
+		return ExitActivationThrottled
+	case errors.Is(err, errAgentUnhealthy):
+		return ExitAgentUnhealthy
+	}
+
+	var phaseErr *PhaseError
+	if errors.As(err, &phaseErr) {
+		if code, ok := phaseExitCodes[phaseErr.Phase]; ok {
+			return code
+		}
+	}
+
+	return ExitFailure
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "setup error", err: errTest, want: ExitFailure},
+		{name: "phase error", err: &PhaseError{Phase: health.PhaseDrain, Err: errTest}, want: ExitDrain},
+		{
+			name: "wrapped phase error",
+			err:  fmt.Errorf("outer: %w", &PhaseError{Phase: health.PhaseRegister, Err: errTest}),
+			want: ExitRegister,
+		},
+		{name: "unknown phase", err: &PhaseError{Phase: health.PhaseStarting, Err: errTest}, want: ExitFailure},
+		{
+			name: "activation denied",
+			err:  &PhaseError{Phase: health.PhaseActivate, Err: fmt.Errorf("%w: %w", activation.ErrDenied, errTest)},
+			want: ExitActivationDenied,
+		},
+		{
+			name: "activation throttled",
+			err:  &PhaseError{Phase: health.PhaseActivate, Err: fmt.Errorf("%w: %w", activation.ErrThrottled, errTest)},
+			want: ExitActivationThrottled,
+		},
+		{name: "other activation failure", err: &PhaseError{Phase: health.PhaseActivate, Err: errTest}, want: ExitActivate},
+		{
+			name: "agent unhealthy",
+			err:  &PhaseError{Phase: health.PhaseSupervise, Err: fmt.Errorf("%w: credentials", errAgentUnhealthy)},
+			want: ExitAgentUnhealthy,
+		},
+		{
+			name: "child crashed",
+			err:  &PhaseError{Phase: health.PhaseSupervise, Err: fmt.Errorf("%w: exit status 2", errChildCrashed)},
+			want: ExitChildCrashed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := exitCode(test.err); got != test.want {
+				t.Errorf("exitCode(%v) = %d, want %d", test.err, got, test.want)
+			}
+
+			if _, ok := exitReasons[test.want]; !ok {
+				t.Errorf("exit code %d has no reason", test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/env"
+	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/hooks"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+)
+
+// wrapperSecrets are the variables holding the wrapper's own secrets, which hooks do not inherit.
+var wrapperSecrets = []string{internal.EnvLazyToken, internal.EnvHookWebhookSecret}
+
+// hooksFromConfig builds the hook set for every lifecycle event from the resolved configuration.
+// Webhooks are posted with client; executables run with environ less the wrapper's secrets.
+func hooksFromConfig(settings config.Hooks, client *http.Client, environ []string) (hooks.Set, error) {
+	environ = hookEnviron(environ)
+
+	postRegistration, err := newHook(hooks.EventPostRegistration, settings.PostRegistration, settings.WebhookSecret,
+		client, environ)
+	if err != nil {
+		return hooks.Set{}, err
+	}
+
+	preShutdown, err := newHook(hooks.EventPreShutdown, settings.PreShutdown, settings.WebhookSecret, client, environ)
+	if err != nil {
+		return hooks.Set{}, err
+	}
+
+	postCleanup, err := newHook(hooks.EventPostCleanup, settings.PostCleanup, settings.WebhookSecret, client, environ)
+	if err != nil {
+		return hooks.Set{}, err
+	}
+
+	return hooks.Set{
+		PostRegistration: postRegistration,
+		PreShutdown:      preShutdown,
+		PostCleanup:      postCleanup,
+	}, nil
+}
+
+func newHook(event string, settings config.Hook, secret string, client *http.Client, environ []string,
+) (hooks.Hook, error) {
+	policy, err := hooks.ParsePolicy(settings.Policy)
+	if err != nil {
+		return hooks.Hook{}, fmt.Errorf("read %s hook policy: %w", event, err)
+	}
+
+	return hooks.Hook{
+		Event:   event,
+		Target:  settings.Target,
+		Timeout: settings.Timeout.Std(),
+		Policy:  policy,
+		Secret:  secret,
+		Client:  client,
+		Environ: environ,
+	}, nil
+}
+
+// hookEnviron returns environ without the wrapper's secrets, the files they are read from and the
+// proxy variables, whose URLs can carry credentials.
+func hookEnviron(environ []string) []string {
+	return slices.DeleteFunc(slices.Clone(environ), func(kv string) bool {
+		key, _, _ := strings.Cut(kv, "=")
+
+		return slices.Contains(wrapperSecrets, strings.TrimSuffix(key, env.FileSuffix)) || proxy.IsEnvironKey(key)
+	})
+}
+
+// hookPayload describes the current execution context and identity for hook delivery.
+func (a App) hookPayload(execCtx execution.Context, reason string) hooks.Payload {
+	status := a.state.Snapshot()
+
+	return hooks.Payload{
+		Event:  "",
+		Time:   a.clock.Now().UTC(),
+		Reason: reason,
+		Execution: hooks.Execution{
+			Region:           execCtx.Region,
+			AvailabilityZone: execCtx.AvailabilityZone,
+			TaskARN:          execCtx.TaskARN,
+		},
+		Identity: hooks.Identity{
+			ActivationID:      status.ActivationID,
+			ManagedInstanceID: status.ManagedInstanceID,
+		},
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"path/filepath"
 	"time"
 
-	runtimeconfig "github.com/aws/amazon-ssm-agent/common/runtimeconfig"
+	"github.com/aws/amazon-ssm-agent/common/runtimeconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentconfig"
 )
 
 var errMissingManagedInstanceID = errors.New("registration file missing managed instance id")
@@ -16,9 +17,8 @@ var errMissingManagedInstanceID = errors.New("registration file missing managed
 const (
 	runtimeConfigDir      = "/var/lib/amazon/ssm/runtimeconfig"
 	runtimeIdentityConfig = "identity_config.json"
-	runtimeShareFile      = "/var/lib/amazon/ssm/runtimeconfig/managed-instance"
-	registrationFile      = "/var/lib/amazon/ssm/registration"
 	agentDataDir          = "/var/lib/amazon/ssm"
+	agentLogDir           = "/var/log/amazon/ssm"
 
 	runtimeDirPerm  = 0o700
 	runtimeFilePerm = 0o600
@@ -38,8 +38,28 @@ type registrationInfo struct {
 	ManagedInstanceID string `json:"ManagedInstanceID"`
 }
 
-func persistIdentity(region string) error {
-	payload, err := os.ReadFile(registrationFile)
+// StatePaths lists the directories persistIdentity and the agent must be able to write to.
+func StatePaths() []string {
+	return []string{agentDataDir, runtimeConfigDir, agentLogDir}
+}
+
+// fsRoot is the directory the wrapper treats as the filesystem root for the state it writes; the
+// empty root is "/".
+type fsRoot string
+
+// path returns the absolute path p relocated under the root.
+func (r fsRoot) path(p string) string {
+	if r == "" || p == "" {
+		return p
+	}
+
+	return filepath.Join(string(r), p)
+}
+
+// persistIdentity writes the runtime identity for the managed instance recorded in the
+// registration file at registrationPath, which is already relocated under root.
+func persistIdentity(root fsRoot, registrationPath, region string) error {
+	payload, err := os.ReadFile(registrationPath) // #nosec G304 -- validated registration path
 	if err != nil {
 		return fmt.Errorf("read registration file: %w", err)
 	}
@@ -55,11 +75,6 @@ func persistIdentity(region string) error {
 		return errMissingManagedInstanceID
 	}
 
-	err = os.MkdirAll(runtimeConfigDir, runtimeDirPerm)
-	if err != nil {
-		return fmt.Errorf("create runtime config dir: %w", err)
-	}
-
 	identity := runtimeIdentity{
 		IdentityType:            "OnPrem",
 		OnPremRegistrationType:  "Managed",
@@ -72,24 +87,24 @@ func persistIdentity(region string) error {
 		return fmt.Errorf("marshal identity payload: %w", marshalErr)
 	}
 
-	target := filepath.Join(runtimeConfigDir, runtimeIdentityConfig)
+	target := filepath.Join(root.path(runtimeConfigDir), runtimeIdentityConfig)
 
 	err = os.WriteFile(target, data, runtimeFilePerm)
 	if err != nil {
 		return fmt.Errorf("write runtime identity: %w", err)
 	}
 
-	err = saveRuntimeConfig(info.ManagedInstanceID)
+	err = saveRuntimeConfig(target, info.ManagedInstanceID)
 	if err != nil {
 		return err
 	}
 
-	err = ensureShareFile(info.ManagedInstanceID)
+	err = ensureShareFile(root.path(agentconfig.ShareFile), info.ManagedInstanceID)
 	if err != nil {
 		return err
 	}
 
-	err = ensureIPCPaths(info.ManagedInstanceID)
+	err = ensureIPCPaths(root.path(agentDataDir), info.ManagedInstanceID)
 	if err != nil {
 		return err
 	}
@@ -97,30 +112,50 @@ func persistIdentity(region string) error {
 	return nil
 }
 
-func saveRuntimeConfig(managedID string) error {
-	runtimeClient := runtimeconfig.NewIdentityRuntimeConfigClient()
+// prepareFilesystem creates the directories registration and persistIdentity write to.
+func prepareFilesystem(root fsRoot, registrationPath string) error {
+	err := os.MkdirAll(root.path(runtimeConfigDir), runtimeDirPerm)
+	if err != nil {
+		return fmt.Errorf("create runtime config dir: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(registrationPath), runtimeDirPerm)
+	if err != nil {
+		return fmt.Errorf("create registration dir: %w", err)
+	}
+
+	return nil
+}
 
+// saveRuntimeConfig writes the agent's identity runtime config to target in the schema its
+// runtimeconfig client reads.
+func saveRuntimeConfig(target, managedID string) error {
 	configPayload := runtimeconfig.IdentityRuntimeConfig{
 		SchemaVersion:          "1.1",
 		InstanceId:             managedID,
 		IdentityType:           "OnPrem",
-		ShareFile:              runtimeShareFile,
+		ShareFile:              agentconfig.ShareFile,
 		ShareProfile:           "",
 		CredentialsExpiresAt:   time.Time{},
 		CredentialsRetrievedAt: time.Time{},
 		CredentialSource:       "",
 	}
 
-	err := runtimeClient.SaveConfig(configPayload)
+	data, err := json.Marshal(configPayload)
+	if err != nil {
+		return fmt.Errorf("marshal runtime config: %w", err)
+	}
+
+	err = os.WriteFile(target, data, runtimeFilePerm)
 	if err != nil {
-		return fmt.Errorf("save runtime config via client: %w", err)
+		return fmt.Errorf("write runtime config: %w", err)
 	}
 
 	return nil
 }
 
-func ensureShareFile(managedID string) error {
-	_, statErr := os.Stat(runtimeShareFile)
+func ensureShareFile(shareFile, managedID string) error {
+	_, statErr := os.Stat(shareFile)
 	if statErr == nil {
 		return nil
 	}
@@ -129,7 +164,7 @@ func ensureShareFile(managedID string) error {
 		return fmt.Errorf("stat runtime share file: %w", statErr)
 	}
 
-	writeErr := os.WriteFile(runtimeShareFile, []byte(managedID+"\n"), runtimeFilePerm)
+	writeErr := os.WriteFile(shareFile, []byte(managedID+"\n"), runtimeFilePerm)
 	if writeErr != nil {
 		return fmt.Errorf("write runtime share file: %w", writeErr)
 	}
@@ -137,8 +172,8 @@ func ensureShareFile(managedID string) error {
 	return nil
 }
 
-func ensureIPCPaths(managedID string) error {
-	ipcBase := filepath.Join(agentDataDir, managedID, "channels")
+func ensureIPCPaths(dataDir, managedID string) error {
+	ipcBase := filepath.Join(dataDir, managedID, "channels")
 
 	channelNames := []string{"health", "termination"}
 	for _, name := range channelNames {
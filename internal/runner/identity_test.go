@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPersistIdentityReadsConfiguredRegistrationFile(t *testing.T) {
+	t.Parallel()
+
+	root := fsRoot(t.TempDir())
+	registration := root.path("/var/lib/amazon/ssm/custom/registration")
+
+	if err := prepareFilesystem(root, registration); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(registration, []byte(`{"ManagedInstanceID":"mi-0123456789abcdef0"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := persistIdentity(root, registration, "eu-west-1"); err != nil {
+		t.Fatalf("persistIdentity: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root.path(runtimeConfigDir), runtimeIdentityConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "mi-0123456789abcdef0") {
+		t.Errorf("runtime identity %s lacks the managed instance from the configured file", data)
+	}
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// interrupter owns the wrapper's signals for one session. Until the agent starts, a signal other
+// than SIGHUP cancels the lifecycle, so the phases already run are rolled back instead of the
+// process dying with whatever it created; from then on signals go to the supervisor, which
+// forwards them to the agent.
+type interrupter struct {
+	cancel context.CancelFunc
+	relay  chan os.Signal
+
+	mu       sync.Mutex
+	starting bool
+	signal   os.Signal
+}
+
+func newInterrupter(cancel context.CancelFunc) *interrupter {
+	return &interrupter{
+		cancel:   cancel,
+		relay:    make(chan os.Signal, signalBuffer),
+		mu:       sync.Mutex{},
+		starting: false,
+		signal:   nil,
+	}
+}
+
+// watch handles signals until the returned function is called.
+func (i *interrupter) watch(signals <-chan os.Signal) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				i.handle(sig)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (i *interrupter) handle(sig os.Signal) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.starting {
+		select {
+		case i.relay <- sig:
+		default:
+			slog.Warn("dropped signal for the agent", slog.String("signal", sig.String()))
+		}
+
+		return
+	}
+
+	if sig == syscall.SIGHUP || i.signal != nil {
+		return
+	}
+
+	slog.Info("signal received before the agent started; rolling back", slog.String("signal", sig.String()))
+
+	i.signal = sig
+	i.cancel()
+}
+
+// agentStarting hands later signals to the supervisor.
+func (i *interrupter) agentStarting() {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.starting = true
+}
+
+// interrupted returns the signal that cancelled the lifecycle, or nil.
+func (i *interrupter) interrupted() os.Signal {
+	if i == nil {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.signal
+}
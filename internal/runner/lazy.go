@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+	"github.com/benwsapp/aws-ssm-minimal/internal/control"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+)
+
+const (
+	idleCheckInterval = 10 * time.Second
+	signalBuffer      = 4
+	// stopReasonDeadline is the stop reason of a lazy wrapper that reached its deadline while dormant.
+	stopReasonDeadline = "deadline reached"
+)
+
+var errDeadlinePassed = errors.New("deadline passed; not waking")
+
+// waker carries wake requests from the control socket and the health server to the lazy
+// lifecycle: while dormant a request starts it, and while the agent runs it counts as activity.
+type waker struct {
+	state    *health.State
+	clock    clock.Clock
+	deadline time.Time
+	requests chan struct{}
+}
+
+func newWaker(state *health.State, c clock.Clock, deadline time.Time) *waker {
+	return &waker{state: state, clock: c, deadline: deadline, requests: make(chan struct{}, 1)}
+}
+
+// wake records a wake request and reports the phase it arrived in. Once the deadline has passed
+// it is rejected, as the wrapper is about to exit.
+func (w *waker) wake() (control.WakeResponse, error) {
+	phase := w.state.Snapshot().Phase
+
+	if w.expired() {
+		slog.Warn("wake rejected after the deadline", slog.String("phase", phase))
+
+		return control.WakeResponse{}, fmt.Errorf("%w: %s", errDeadlinePassed, w.deadline.Format(time.RFC3339))
+	}
+
+	select {
+	case w.requests <- struct{}{}:
+	default:
+	}
+
+	slog.Info("wake requested", slog.String("phase", phase))
+
+	return control.WakeResponse{Phase: phase}, nil
+}
+
+// expired reports whether the deadline, if any, has passed.
+func (w *waker) expired() bool {
+	return !w.deadline.IsZero() && !w.clock.Now().Before(w.deadline)
+}
+
+// deadlineTimer returns a channel that receives once the deadline passes, or nil without one,
+// and the function that stops it.
+func (w *waker) deadlineTimer() (<-chan time.Time, func()) {
+	if w.deadline.IsZero() {
+		return nil, func() {}
+	}
+
+	timer := w.clock.NewTimer(w.deadline.Sub(w.clock.Now()))
+
+	return timer.C(), func() { timer.Stop() }
+}
+
+// runLazy keeps the wrapper dormant until woken, then runs the lifecycle until the agent has been
+// idle for the configured period, cleans up and goes dormant again. A signal while dormant exits
+// with ExitOK, as does reaching the deadline; a signal while awake is forwarded to the agent and
+// the wrapper exits after cleanup. A failed lifecycle, or one that ends after the deadline, ends
+// the wrapper with its exit code.
+func (a App) runLazy(lc *lifecycle, newSession func() *session, wake *waker, report *terminationReport) (int, error) {
+	signals, stopSignals := a.notifySignals()
+	defer stopSignals()
+
+	expired, stopTimer := wake.deadlineTimer()
+	defer stopTimer()
+
+	for {
+		a.goDormant(lc)
+
+		reason, woken := waitForWake(signals, wake.requests, expired)
+		lc.record(nil)
+
+		// A wake queued just before the deadline must not start a session after it.
+		if woken && wake.expired() {
+			reason, woken = stopReasonDeadline, false
+		}
+
+		if !woken {
+			slog.Info("exiting while dormant", slog.String("reason", reason))
+
+			report.StopReason = reason
+			report.Agent = nil
+
+			return ExitOK, nil
+		}
+
+		relay := make(chan os.Signal, signalBuffer)
+		relayDone := make(chan struct{})
+		terminating := make(chan bool, 1)
+
+		go func() { terminating <- relaySignals(signals, relay, relayDone) }()
+
+		sess := newSession()
+		sess.signals = relay
+		sess.wakes = wake.requests
+
+		code, err := a.runSession(lc, sess)
+		report.recordRun(lc, sess)
+
+		close(relayDone)
+
+		switch {
+		case err != nil:
+			return code, err
+		case <-terminating:
+			return code, nil
+		case wake.expired():
+			slog.Info("deadline reached; exiting")
+
+			return code, nil
+		}
+	}
+}
+
+// goDormant enters the dormant phase and forgets the previous session's identity, both in the
+// health state and in the log correlation fields.
+func (a App) goDormant(lc *lifecycle) {
+	lc.transition(health.PhaseDormant)
+
+	a.state.SetActivationID("")
+	a.state.SetManagedInstanceID("")
+	a.state.SetPingStatus("")
+	a.state.SetDeadline(time.Time{})
+	a.state.SetChildPID(0)
+	logging.Forget(logging.KeyActivationID, logging.KeyManagedInstanceID)
+}
+
+// waitForWake blocks until a wake request, the deadline passing on expired or a signal other
+// than SIGHUP, which has no agent to reach while dormant. Unless woken it returns why the wrapper
+// stops.
+func waitForWake(signals <-chan os.Signal, wakes <-chan struct{}, expired <-chan time.Time) (string, bool) {
+	for {
+		select {
+		case <-wakes:
+			return "", true
+		case <-expired:
+			return stopReasonDeadline, false
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				return "signal " + sig.String(), false
+			}
+		}
+	}
+}
+
+// relaySignals forwards signals to the session's supervisor until done is closed, and reports
+// whether any of them asked the wrapper to terminate.
+func relaySignals(signals <-chan os.Signal, relay chan<- os.Signal, done <-chan struct{}) bool {
+	terminating := false
+
+	for {
+		select {
+		case <-done:
+			return terminating
+		case sig := <-signals:
+			terminating = terminating || sig != syscall.SIGHUP
+
+			select {
+			case relay <- sig:
+			default:
+				slog.Warn("dropped signal for the agent", slog.String("signal", sig.String()))
+			}
+		}
+	}
+}
+
+// notifySignals returns the signals the wrapper receives: those injected with WithSignals, or
+// otherwise the ones the supervisor would forward.
+func (a App) notifySignals() (<-chan os.Signal, func()) {
+	if a.signals != nil {
+		return a.signals, func() {}
+	}
+
+	signals := make(chan os.Signal, signalBuffer)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+
+	return signals, func() { signal.Stop(signals) }
+}
+
+// watchIdle stops the agent once no Session Manager session has run for the lazy idle timeout,
+// unless ctx ends first. A wake request counts as activity.
+func (s *session) watchIdle(ctx context.Context) {
+	idle := s.cfg.Lazy.IdleTimeout.Std()
+
+	ticker := s.app.clock.NewTicker(min(idleCheckInterval, idle))
+	defer ticker.Stop()
+
+	lastActive := s.app.clock.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wakes:
+			lastActive = s.app.clock.Now()
+		case <-ticker.C():
+			now := s.app.clock.Now()
+			if ssmagent.CountSessionWorkers(s.app.root.path(procRoot)) > 0 {
+				lastActive = now
+
+				continue
+			}
+
+			if now.Sub(lastActive) >= idle {
+				reason := fmt.Sprintf("idle for %s", idle)
+				slog.Info("agent idle; going dormant", slog.Duration("idle", idle))
+				s.agent.sup.Stop(reason)
+
+				return
+			}
+		}
+	}
+}
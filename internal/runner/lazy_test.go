@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clocktest"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+)
+
+func TestWakerRejectsWakesAfterDeadline(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	fake := clocktest.NewFake(now)
+	wake := newWaker(health.NewState(), fake, now.Add(time.Minute))
+
+	expired, stop := wake.deadlineTimer()
+	defer stop()
+
+	if _, err := wake.wake(); err != nil {
+		t.Fatalf("wake before the deadline: %v", err)
+	}
+
+	<-wake.requests
+
+	fake.Advance(time.Minute)
+
+	select {
+	case <-expired:
+	default:
+		t.Error("deadline timer did not fire at the deadline")
+	}
+
+	if _, err := wake.wake(); !errors.Is(err, errDeadlinePassed) {
+		t.Errorf("wake after the deadline = %v, want %v", err, errDeadlinePassed)
+	}
+
+	select {
+	case <-wake.requests:
+		t.Error("a wake after the deadline was queued")
+	default:
+	}
+}
@@ -0,0 +1,226 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+// PhaseError reports the lifecycle phase that failed.
+type PhaseError struct {
+	Phase string
+	Err   error
+}
+
+func (e *PhaseError) Error() string {
+	return e.Phase + ": " + e.Err.Error()
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// phase is one step of the lifecycle.
+type phase struct {
+	name string
+	// timeout bounds run; zero leaves it unbounded.
+	timeout time.Duration
+	run     func(ctx context.Context) error
+	// rollback undoes the phase. It runs during cleanup once the phase has started, whether it
+	// succeeded or not, so it must tolerate a phase that did nothing. Nil when there is nothing
+	// to undo.
+	rollback func(ctx context.Context) error
+}
+
+// lifecycle runs phases in order, reporting each transition to the health state and the logs,
+// and remembers what cleanup has to undo and how every phase went.
+type lifecycle struct {
+	state     *health.State
+	clock     clock.Clock
+	current   string
+	entered   time.Time
+	rollbacks []phase
+	phases    []phaseReport
+	steps     []stepReport
+}
+
+func newLifecycle(state *health.State, c clock.Clock) *lifecycle {
+	return &lifecycle{
+		state:     state,
+		clock:     c,
+		current:   health.PhaseStarting,
+		entered:   c.Now(),
+		rollbacks: nil,
+		phases:    nil,
+		steps:     nil,
+	}
+}
+
+// run runs phases until one fails, returning its error as a *PhaseError. A phase may attribute
+// its failure to an earlier phase by returning a *PhaseError itself.
+func (l *lifecycle) run(ctx context.Context, phases []phase) error {
+	for _, p := range phases {
+		// A phase can finish despite its context ending; the next one must not start.
+		if err := ctx.Err(); err != nil {
+			return &PhaseError{Phase: p.name, Err: err}
+		}
+
+		l.transition(p.name)
+
+		if p.rollback != nil {
+			l.rollbacks = append(l.rollbacks, p)
+		}
+
+		err := runWithTimeout(ctx, p.timeout, p.run)
+		l.record(err)
+
+		if err != nil {
+			var phaseErr *PhaseError
+			if errors.As(err, &phaseErr) {
+				return err
+			}
+
+			return &PhaseError{Phase: p.name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// cleanup undoes every started phase, newest first, then runs finish. Every rollback is attempted;
+// their errors are joined into one *PhaseError for the cleanup phase. Afterwards there is nothing
+// left to undo, so the lifecycle can run again.
+func (l *lifecycle) cleanup(ctx context.Context, timeout time.Duration, finish phase) error {
+	l.transition(health.PhaseCleanup)
+
+	defer func() { l.rollbacks = nil }()
+
+	err := runWithTimeout(ctx, timeout, func(ctx context.Context) error {
+		var errs []error
+
+		for _, p := range slices.Backward(l.rollbacks) {
+			rollbackErr := p.rollback(ctx)
+			l.step("undo "+p.name, rollbackErr)
+
+			if rollbackErr != nil {
+				errs = append(errs, fmt.Errorf("undo %s: %w", p.name, rollbackErr))
+			}
+		}
+
+		finishErr := finish.run(ctx)
+		l.step(finish.name, finishErr)
+
+		return errors.Join(append(errs, finishErr)...)
+	})
+	l.record(err)
+
+	if err != nil {
+		return &PhaseError{Phase: health.PhaseCleanup, Err: err}
+	}
+
+	return nil
+}
+
+// transition records entering next.
+func (l *lifecycle) transition(next string) {
+	now := l.clock.Now()
+	slog.Info("lifecycle transition",
+		slog.String("from", l.current),
+		slog.String("to", next),
+		slog.Duration("elapsed", now.Sub(l.entered)))
+
+	l.current = next
+	l.entered = now
+	l.state.SetPhase(next)
+	logging.Annotate(logging.KeyPhase, next)
+}
+
+// record notes how the current phase ended and how long it took.
+func (l *lifecycle) record(err error) {
+	l.phases = append(l.phases, phaseReport{
+		Name:            l.current,
+		DurationSeconds: l.clock.Now().Sub(l.entered).Seconds(),
+		Error:           errorString(err),
+	})
+}
+
+// step notes the outcome of one cleanup step.
+func (l *lifecycle) step(name string, err error) {
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeFailure
+	}
+
+	l.steps = append(l.steps, stepReport{Name: name, Outcome: outcome, Error: errorString(err)})
+}
+
+// fail records that the lifecycle ended because err.
+func (l *lifecycle) fail(err error) {
+	failed := l.current
+
+	var phaseErr *PhaseError
+	if errors.As(err, &phaseErr) {
+		failed = phaseErr.Phase
+	}
+
+	slog.Error("lifecycle failed", slog.String("failedPhase", failed), logging.Err(err))
+	l.state.Fail(failed)
+	logging.Annotate(logging.KeyPhase, health.PhaseFailed)
+}
+
+func runWithTimeout(parent context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout <= 0 {
+		return fn(parent)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	return fn(ctx)
+}
+
+// task is work within a phase that does not depend on the phase's other tasks.
+type task struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// runConcurrently runs tasks in parallel, logging how long each took, and returns their errors
+// joined. The first failure cancels the tasks still running.
+func runConcurrently(parent context.Context, c clock.Clock, tasks ...task) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	errs := make([]error, len(tasks))
+
+	var wg sync.WaitGroup
+
+	for i, t := range tasks {
+		wg.Go(func() {
+			started := c.Now()
+			err := t.run(ctx)
+
+			attrs := []any{slog.String("task", t.name), slog.Duration("elapsed", c.Now().Sub(started))}
+			if err != nil {
+				cancel()
+
+				errs[i] = err
+				attrs = append(attrs, logging.Err(err))
+			}
+
+			slog.Info("task finished", attrs...)
+		})
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clocktest"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+)
+
+var errTest = errors.New("test failure")
+
+// recorder builds phases that log what ran, in order.
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) phase(name string, runErr, rollbackErr error) phase {
+	return phase{
+		name:    name,
+		timeout: 0,
+		run: func(context.Context) error {
+			r.events = append(r.events, "run "+name)
+
+			return runErr
+		},
+		rollback: func(context.Context) error {
+			r.events = append(r.events, "undo "+name)
+
+			return rollbackErr
+		},
+	}
+}
+
+func (r *recorder) finish() phase {
+	return phase{
+		name:    "finish",
+		timeout: 0,
+		run: func(context.Context) error {
+			r.events = append(r.events, "finish")
+
+			return nil
+		},
+		rollback: nil,
+	}
+}
+
+func TestLifecycleRollsBackStartedPhases(t *testing.T) {
+	t.Parallel()
+
+	state := health.NewState()
+	lc := newLifecycle(state, clocktest.NewFake(time.Now()))
+	rec := &recorder{events: nil}
+
+	err := lc.run(t.Context(), []phase{
+		rec.phase(health.PhaseActivate, nil, nil),
+		rec.phase(health.PhaseRegister, errTest, nil),
+		rec.phase(health.PhaseVerifyOnline, nil, nil),
+	})
+	if !errors.Is(err, errTest) || exitCode(err) != ExitRegister {
+		t.Fatalf("run = %v (exit %d); want the register failure", err, exitCode(err))
+	}
+
+	cleanupErr := lc.cleanup(t.Context(), time.Second, rec.finish())
+	if cleanupErr != nil {
+		t.Fatalf("cleanup: %v", cleanupErr)
+	}
+
+	lc.fail(err)
+
+	want := "[run activate run register undo register undo activate finish]"
+	if got := fmt.Sprint(rec.events); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+
+	if status := state.Snapshot(); status.Phase != health.PhaseFailed || status.FailedPhase != health.PhaseRegister {
+		t.Errorf("status phase %q, failed phase %q; want failed in register", status.Phase, status.FailedPhase)
+	}
+}
+
+func TestLifecycleCleanupAttemptsEveryRollback(t *testing.T) {
+	t.Parallel()
+
+	lc := newLifecycle(health.NewState(), clocktest.NewFake(time.Now()))
+	rec := &recorder{events: nil}
+
+	err := lc.run(t.Context(), []phase{
+		rec.phase(health.PhaseActivate, nil, errTest),
+		rec.phase(health.PhaseRegister, nil, errTest),
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	err = lc.cleanup(t.Context(), time.Second, rec.finish())
+	if !errors.Is(err, errTest) || exitCode(err) != ExitCleanup {
+		t.Fatalf("cleanup = %v (exit %d); want the rollback failures", err, exitCode(err))
+	}
+
+	want := "[run activate run register undo register undo activate finish]"
+	if got := fmt.Sprint(rec.events); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+}
+
+func TestLifecyclePhaseTimeout(t *testing.T) {
+	t.Parallel()
+
+	lc := newLifecycle(health.NewState(), clocktest.NewFake(time.Now()))
+
+	err := lc.run(t.Context(), []phase{{
+		name:     health.PhaseVerifyOnline,
+		timeout:  time.Millisecond,
+		run:      func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+		rollback: nil,
+	}})
+	if !errors.Is(err, context.DeadlineExceeded) || exitCode(err) != ExitVerifyOnline {
+		t.Fatalf("run = %v (exit %d); want a verify-online timeout", err, exitCode(err))
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+
+	err := runConcurrently(t.Context(), clocktest.NewFake(time.Now()),
+		task{name: "waits", run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+
+			return nil
+		}},
+		task{name: "fails", run: func(context.Context) error {
+			<-started
+
+			return errTest
+		}},
+	)
+	if !errors.Is(err, errTest) {
+		t.Fatalf("runConcurrently = %v, want the failing task's error", err)
+	}
+}
@@ -0,0 +1,192 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+
+	reasonTTLExpired    = "ttl-expired"
+	reasonStopRequested = "stop-requested"
+)
+
+// terminationReport is the JSON document written to the termination report path on exit, for
+// Kubernetes terminationMessagePath or a log shipper.
+type terminationReport struct {
+	ExitCode    int            `json:"exitCode"`
+	Reason      string         `json:"reason"`
+	FailedPhase string         `json:"failedPhase,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	StopReason  string         `json:"stopReason,omitempty"`
+	StartedAt   time.Time      `json:"startedAt"`
+	EndedAt     time.Time      `json:"endedAt"`
+	Phases      []phaseReport  `json:"phases"`
+	Agent       *agentReport   `json:"agent,omitempty"`
+	Cleanup     *cleanupReport `json:"cleanup,omitempty"`
+}
+
+// phaseReport is how one lifecycle phase ended.
+type phaseReport struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// agentReport is how the supervised agent exited.
+type agentReport struct {
+	ExitCode   int    `json:"exitCode"`
+	TTLExpired bool   `json:"ttlExpired"`
+	Stopped    bool   `json:"stopped"`
+	Unhealthy  string `json:"unhealthy,omitempty"`
+}
+
+// cleanupReport is the outcome of the cleanup phase and each of its steps.
+type cleanupReport struct {
+	Outcome string       `json:"outcome"`
+	Steps   []stepReport `json:"steps"`
+}
+
+// stepReport is the outcome of one cleanup step.
+type stepReport struct {
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newTerminationReport(startedAt time.Time) *terminationReport {
+	return &terminationReport{
+		ExitCode:    0,
+		Reason:      "",
+		FailedPhase: "",
+		Error:       "",
+		StopReason:  "",
+		StartedAt:   startedAt,
+		EndedAt:     time.Time{},
+		Phases:      []phaseReport{},
+		Agent:       nil,
+		Cleanup:     nil,
+	}
+}
+
+// recordRun copies the phase timings and cleanup outcome from lc, and the agent's exit from sess
+// once it has exited.
+func (r *terminationReport) recordRun(lc *lifecycle, sess *session) {
+	r.Phases = lc.phases
+
+	if len(lc.steps) > 0 {
+		outcome := outcomeSuccess
+
+		for _, step := range lc.steps {
+			if step.Outcome == outcomeFailure {
+				outcome = outcomeFailure
+			}
+		}
+
+		r.Cleanup = &cleanupReport{Outcome: outcome, Steps: lc.steps}
+	}
+
+	if sig := sess.interrupt.interrupted(); sig != nil {
+		r.StopReason = "signal " + sig.String()
+		r.Agent = nil
+	}
+
+	if sess.agent == nil {
+		return
+	}
+
+	select {
+	case <-sess.agent.done:
+	default:
+		return
+	}
+
+	result := sess.agent.result
+	r.StopReason = sess.agent.stopReason
+	r.Agent = &agentReport{
+		ExitCode:   result.ExitCode,
+		TTLExpired: result.TTLExpired,
+		Stopped:    result.Stopped,
+		Unhealthy:  "",
+	}
+
+	if result.Unhealthy != nil {
+		r.Agent.Unhealthy = result.Unhealthy.Kind
+	}
+}
+
+// finish records the exit code and error the wrapper is about to return.
+func (r *terminationReport) finish(code int, err error, endedAt time.Time) {
+	r.ExitCode = code
+	r.Reason = exitReasons[code]
+	r.Error = errorString(err)
+	r.EndedAt = endedAt
+
+	if code == ExitOK {
+		switch {
+		case r.Agent == nil:
+			// A lazy wrapper stopped while dormant has no agent to report on.
+			switch r.StopReason {
+			case "":
+			case stopReasonDeadline:
+				r.Reason = reasonTTLExpired
+			default:
+				r.Reason = reasonStopRequested
+			}
+		case r.Agent.TTLExpired:
+			r.Reason = reasonTTLExpired
+		case r.Agent.Stopped:
+			r.Reason = reasonStopRequested
+		}
+	}
+
+	var phaseErr *PhaseError
+	if errors.As(err, &phaseErr) {
+		r.FailedPhase = phaseErr.Phase
+	}
+}
+
+// writeReport finishes report and writes it to path, if one is configured. A report that cannot
+// be written is logged and does not change the exit code.
+func (a App) writeReport(path string, report *terminationReport, code int, err error) {
+	if path == "" {
+		return
+	}
+
+	report.finish(code, err, a.clock.Now().UTC())
+
+	writeErr := writeJSONFile(path, report)
+	if writeErr != nil {
+		slog.Warn("failed to write termination report", slog.String("path", path), logging.Err(writeErr))
+	}
+}
+
+func writeJSONFile(path string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	err = os.WriteFile(path, data, runtimeFilePerm)
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -4,230 +4,508 @@ package runner
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/benwsapp/aws-ssm-minimal/internal"
 	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
-	"github.com/benwsapp/aws-ssm-minimal/internal/awsconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/control"
 	"github.com/benwsapp/aws-ssm-minimal/internal/env"
 	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+	"github.com/benwsapp/aws-ssm-minimal/internal/hooks"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
 	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+	"github.com/benwsapp/aws-ssm-minimal/internal/secretstore"
 	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
-	"github.com/benwsapp/aws-ssm-minimal/internal/supervisor"
 )
 
+// RunCommand names the subcommand that supervises the agent; it is the default command.
+const RunCommand = "run"
+
 const (
-	defaultMetadataTimeout   = 5 * time.Second
-	activationTimeout        = 30 * time.Second
-	registrationTimeout      = 60 * time.Second
-	metadataRegistrationBase = "/var/lib/amazon/ssm"
+	defaultMetadataTimeout = 5 * time.Second
+	activationTimeout      = 30 * time.Second
+	registrationTimeout    = 60 * time.Second
+	healthShutdownTimeout  = 5 * time.Second
+	pingStatusInterval     = 10 * time.Second
+	metricsPath            = "/metrics"
+	procRoot               = "/proc"
+	healthSignalBuffer     = 4
+	outputDrainTimeout     = 5 * time.Second
 )
 
 var (
-	errArgsMissing             = errors.New("service command not specified")
-	errNonPositiveTTL          = errors.New("ttl must be greater than zero")
-	errRegistrationPathInvalid = errors.New("registration path outside allowed base")
+	errAgentUnhealthy = errors.New("agent reported fatal condition")
+	errArgsMissing    = errors.New("service command not specified")
 )
 
 // App represents the command-line entrypoint.
-type App struct{}
+type App struct {
+	state      *health.State
+	startedAt  time.Time
+	onlineOnce *sync.Once
+	environ    []string
+	root       fsRoot
+	clock      clock.Clock
+	command    ssmagent.CommandFunc
+	signals    <-chan os.Signal
+	logOutput  io.Writer
+}
+
+// Option customizes an App.
+type Option func(*App)
+
+// WithEnviron replaces the process environment: configuration is read from environ, a list of
+// KEY=value entries, and the agent is started with it.
+func WithEnviron(environ []string) Option {
+	return func(a *App) {
+		a.environ = environ
+	}
+}
+
+// WithRoot relocates the files the wrapper writes (the registration file, agent config, runtime
+// identity and control socket) under dir.
+func WithRoot(dir string) Option {
+	return func(a *App) {
+		a.root = fsRoot(dir)
+	}
+}
+
+// WithClock replaces the real clock used for timestamps, the TTL and grace timers and status
+// polling.
+func WithClock(c clock.Clock) Option {
+	return func(a *App) {
+		a.clock = c
+	}
+}
+
+// WithCommand replaces exec.CommandContext for starting the agent, both to register and to run.
+func WithCommand(command ssmagent.CommandFunc) Option {
+	return func(a *App) {
+		a.command = command
+	}
+}
+
+// WithSignals forwards signals received on signals to the agent instead of those sent to the
+// wrapper process.
+func WithSignals(signals <-chan os.Signal) Option {
+	return func(a *App) {
+		a.signals = signals
+	}
+}
+
+// WithLogOutput writes the wrapper's own log records to w instead of standard error.
+func WithLogOutput(w io.Writer) Option {
+	return func(a *App) {
+		a.logOutput = w
+	}
+}
 
 // NewApp returns a new App instance.
-func NewApp() App {
-	return App{}
+func NewApp(opts ...Option) App {
+	app := App{
+		state:      health.NewState(),
+		startedAt:  time.Time{},
+		onlineOnce: &sync.Once{},
+		environ:    os.Environ(),
+		root:       "",
+		clock:      clock.Real(),
+		command:    exec.CommandContext,
+		signals:    nil,
+		logOutput:  os.Stderr,
+	}
+
+	for _, opt := range opts {
+		opt(&app)
+	}
+
+	app.startedAt = app.clock.Now()
+
+	return app
 }
 
-// Run executes the TTL runner and returns the exit code.
-func (App) Run() (int, error) {
-	args, err := parseArgs()
+// Run parses wrapper flags from args, supervises the service command that follows them and
+// returns the exit code.
+func (a App) Run(args []string) (int, error) {
+	report := newTerminationReport(a.clock.Now().UTC())
+
+	cfg, inv, err := a.loadConfig(args)
 	if err != nil {
-		return 1, err
+		if !errors.Is(err, flag.ErrHelp) {
+			// Without a configuration, the report path can only come from the environment.
+			path, _ := env.EnvironLookup(a.environ)(internal.EnvTerminationReport)
+			a.writeReport(path, report, ExitConfig, err)
+		}
+
+		return ExitConfig, err
 	}
 
-	ttl, grace, err := readDurations()
+	err = logging.Setup(a.logOutput, cfg.Logging.Format, cfg.Logging.Level)
 	if err != nil {
-		return 1, err
+		err = fmt.Errorf("configure logging: %w", err)
+		a.writeReport(cfg.TerminationReport, report, ExitConfig, err)
+
+		return ExitConfig, err
 	}
 
-	roleName, err := env.MustGetNonEmpty(internal.EnvManagedInstanceRole)
-	if err != nil {
-		return 1, fmt.Errorf("read managed instance role: %w", err)
+	if inv.dryRun {
+		return printPlan(context.Background(), os.Stdout, cfg, inv.command)
 	}
 
-	ctx := context.Background()
+	a.registerMetrics()
 
-	execCtx, err := discoverExecutionContext(ctx)
-	if err != nil {
-		return 1, err
+	var wake *waker
+	if cfg.Lazy.Enabled {
+		wake = newWaker(a.state, a.clock, cfg.Deadline.Std())
 	}
 
-	ssmClient, err := awsconfig.NewSSMClient(ctx, execCtx.Region)
+	server, err := a.startHealthServer(cfg, wake)
 	if err != nil {
-		return 1, fmt.Errorf("create ssm client: %w", err)
+		a.writeReport(cfg.TerminationReport, report, ExitFailure, err)
+
+		return ExitFailure, err
 	}
+	defer stopHealthServer(server)
+
+	code, err := a.run(cfg, inv.command, report, wake)
+	a.writeReport(cfg.TerminationReport, report, code, err)
 
-	registrationPath, err := resolveRegistrationPath()
+	return code, err
+}
+
+// run runs the lifecycle once, or in lazy mode whenever wake is woken.
+func (a App) run(cfg config.Config, args []string, report *terminationReport, wake *waker) (int, error) {
+	px, err := proxy.New(cfg.Proxy)
 	if err != nil {
-		return 1, err
+		return ExitFailure, fmt.Errorf("configure proxy: %w", err)
 	}
 
-	activationResult, cleanup, err := activateInstance(ctx, ssmClient, roleName, execCtx, registrationPath)
+	px.LogSummary()
+
+	hookSet, err := hooksFromConfig(cfg.Hooks, px.Client(), a.environ)
 	if err != nil {
-		return 1, err
+		return ExitFailure, err
 	}
-	defer cleanup()
 
-	registrationErr := registerAgent(ctx, activationResult, execCtx.Region, args[0])
-	if registrationErr != nil {
-		return 1, registrationErr
+	agentLog := newAgentLogging(cfg.Agent)
+
+	controlServer := startControlServer(cfg.Control.Socket, agentLog, wake)
+	defer stopControlServer(controlServer)
+
+	newSession := func() *session {
+		return &session{
+			app:        a,
+			cfg:        cfg,
+			args:       args,
+			px:         px,
+			hooks:      hookSet,
+			restarts:   agentLog.restarts,
+			agentEnv:   append(px.Environ(a.environ), agentconfig.Environ(cfg.AWS)...),
+			watcher:    a.newWatcher(cfg),
+			ready:      a.newReadyWatcher(cfg),
+			watched:    nil,
+			signals:    a.signals,
+			interrupt:  nil,
+			wakes:      nil,
+			execCtx:    execution.Context{Region: "", RegionSource: "", AvailabilityZone: "", TaskARN: ""},
+			client:     nil,
+			activation: activation.Result{ActivationID: "", ActivationCode: ""},
+			cleaner:    nil,
+			agent:      nil,
+			exitCode:   0,
+		}
+	}
+
+	lc := newLifecycle(a.state, a.clock)
+
+	if wake != nil {
+		return a.runLazy(lc, newSession, wake, report)
 	}
 
-	return superviseCommand(ctx, args, ttl, grace)
+	sess := newSession()
+	code, err := a.runSession(lc, sess)
+	report.recordRun(lc, sess)
+
+	return code, err
 }
 
-func parseArgs() ([]string, error) {
-	args := os.Args[1:]
-	if len(args) == 0 {
-		return nil, errArgsMissing
+// runSession runs sess through the lifecycle and cleanup, and returns the wrapper's exit code. A
+// signal before the agent starts ends the lifecycle; once rolled back, that exits with ExitOK.
+func (a App) runSession(lc *lifecycle, sess *session) (int, error) {
+	ctx := context.Background()
+
+	signals, stopSignals := sess.signals, func() {}
+	if signals == nil {
+		signals, stopSignals = a.notifySignals()
 	}
+	defer stopSignals()
+
+	phaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sess.interrupt = newInterrupter(cancel)
+	sess.signals = sess.interrupt.relay
+
+	stopInterrupt := sess.interrupt.watch(signals)
+	defer stopInterrupt()
 
-	return args, nil
+	stopWatcher := sess.startWatcher()
+	err := lc.run(phaseCtx, sess.phases())
+
+	stopWatcher()
+
+	if sig := sess.interrupt.interrupted(); sig != nil && err != nil {
+		slog.Info("lifecycle interrupted", slog.String("signal", sig.String()), logging.Err(err))
+
+		err = nil
+	}
+
+	cleanupErr := lc.cleanup(ctx, activationTimeout+sess.cfg.ShutdownGrace.Std(), phase{
+		name:    hooks.EventPostCleanup,
+		timeout: 0,
+		run: func(ctx context.Context) error {
+			return sess.hooks.PostCleanup.Run(ctx, a.hookPayload(sess.execCtx, ""))
+		},
+		rollback: nil,
+	})
+
+	switch {
+	case err != nil:
+		lc.fail(errors.Join(err, cleanupErr))
+
+		return exitCode(err), errors.Join(err, cleanupErr)
+	case cleanupErr != nil:
+		lc.fail(cleanupErr)
+
+		return exitCode(cleanupErr), cleanupErr
+	default:
+		return sess.exitCode, nil
+	}
 }
 
-func readDurations() (time.Duration, time.Duration, error) {
-	ttl, err := env.DurationSeconds(internal.EnvTTLSeconds, internal.DefaultTTLSeconds)
-	if err != nil {
-		return 0, 0, fmt.Errorf("read ttl seconds: %w", err)
+// ssmAPI is every SSM operation the wrapper calls; *ssm.Client satisfies it.
+type ssmAPI interface {
+	activation.CreateAPI
+	ssmagent.CleanupAPI
+	ssmagent.DescribeAPI
+}
+
+func (a App) startHealthServer(cfg config.Config, wake *waker) (*health.Server, error) {
+	if cfg.Health.ListenAddr == "" {
+		return nil, nil //nolint:nilnil // health server is optional
 	}
 
-	if ttl <= 0 {
-		return 0, 0, fmt.Errorf("%w: %s", errNonPositiveTTL, internal.EnvTTLSeconds)
+	server := health.NewServer(cfg.Health.ListenAddr, a.state)
+	server.Handle(metricsPath, metrics.Default.Handler())
+
+	if wake != nil && cfg.Lazy.Token != "" {
+		server.Handle(control.WakePath, control.RequireToken(cfg.Lazy.Token, control.WakeHandler(wake.wake)))
 	}
 
-	grace, err := env.DurationSeconds(internal.EnvTTLShutdownGraceSeconds, internal.DefaultShutdownGraceSeconds)
+	err := server.Start()
 	if err != nil {
-		return 0, 0, fmt.Errorf("read shutdown grace: %w", err)
+		return nil, fmt.Errorf("start health server: %w", err)
 	}
 
-	if grace < 0 {
-		grace = 0
-	}
+	return server, nil
+}
 
-	return ttl, grace, nil
+// registerMetrics exposes lifecycle state that is sampled at scrape time.
+func (a App) registerMetrics() {
+	metrics.Default.NewGaugeFunc(
+		"ssm_wrapper_ttl_remaining_seconds",
+		"Seconds remaining before the TTL expires.",
+		func() float64 { return float64(a.state.Snapshot().TTLRemainingSeconds) },
+	)
+	metrics.Default.NewCounterFunc(
+		"ssm_wrapper_child_restarts_total",
+		"Number of times the supervised agent was restarted.",
+		func() float64 { return float64(a.state.Snapshot().RestartCount) },
+	)
+	metrics.Default.NewGaugeFunc(
+		"ssm_wrapper_active_sessions",
+		"Number of running Session Manager worker processes.",
+		func() float64 { return float64(ssmagent.CountSessionWorkers(a.root.path(procRoot))) },
+	)
 }
 
-func discoverExecutionContext(parent context.Context) (execution.Context, error) {
-	ctx, cancel := context.WithTimeout(parent, defaultMetadataTimeout)
+func stopHealthServer(server *health.Server) {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
 	defer cancel()
 
-	provider := execution.NewProvider(metadata.NewProvider(nil))
+	err := server.Shutdown(ctx)
+	if err != nil {
+		slog.Warn("failed to stop health server", logging.Err(err))
+	}
+}
 
-	execCtx, err := provider.Discover(ctx)
+func (a App) recordManagedInstanceID(registrationPath string) {
+	instanceID, err := ssmagent.ReadManagedInstanceID(registrationPath)
 	if err != nil {
-		return execution.Context{}, fmt.Errorf("discover execution context: %w", err)
+		slog.Warn("unable to read managed instance id", logging.Err(err))
+
+		return
 	}
 
-	log.Printf("discovered execution context: region=%s az=%s taskArn=%s",
-		execCtx.Region, execCtx.AvailabilityZone, execCtx.TaskARN)
+	a.state.SetManagedInstanceID(instanceID)
+	logging.Annotate(logging.KeyManagedInstanceID, instanceID)
+	slog.Info("registered managed instance")
+}
 
-	return execCtx, nil
+// monitorPingStatus polls SSM for the managed instance ping status until ctx is cancelled, or
+// until the poll is denied: /readyz then stays unready, as retrying cannot help.
+func (a App) monitorPingStatus(ctx context.Context, client ssmagent.DescribeAPI) {
+	ticker := a.clock.NewTicker(pingStatusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			_, err := a.refreshPingStatus(ctx, client)
+			if errors.Is(err, ssmagent.ErrDescribeDenied) {
+				slog.Warn("stopped polling ping status; /readyz will not report ready", logging.Err(err))
+
+				return
+			}
+		}
+	}
 }
 
-// resolveRegistrationPath determines where the amazon-ssm-agent registration file lives.
-func resolveRegistrationPath() (string, error) {
-	path := env.GetString(internal.EnvRegistrationFileOverride)
-	if path == "" {
-		return internal.RegistrationFilePath, nil
+// refreshPingStatus records and returns the managed instance's current ping status, or "" and
+// the error when it cannot be read.
+func (a App) refreshPingStatus(ctx context.Context, client ssmagent.DescribeAPI) (string, error) {
+	instanceID := a.state.Snapshot().ManagedInstanceID
+	if instanceID == "" {
+		return "", nil
 	}
 
-	clean := filepath.Clean(path)
-	if !strings.HasPrefix(clean, metadataRegistrationBase) {
-		return "", fmt.Errorf("%w: %s within %s", errRegistrationPathInvalid, clean, metadataRegistrationBase)
+	status, err := ssmagent.PingStatus(ctx, client, instanceID)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("unable to refresh ping status", logging.Err(err))
+		}
+
+		return "", err
 	}
 
-	return clean, nil
-}
+	previous := a.state.Snapshot().PingStatus
+	a.state.SetPingStatus(status)
+
+	if status != previous {
+		slog.Info("managed instance ping status changed", slog.String("pingStatus", status))
+	}
+
+	if status == string(types.PingStatusOnline) {
+		a.onlineOnce.Do(func() {
+			metrics.ObservePhase(metrics.PhaseOnline, a.clock.Now().Sub(a.startedAt))
+		})
+	}
 
-func activateInstance(
-	parent context.Context,
-	client *ssm.Client,
-	roleName string,
-	execCtx execution.Context,
-	registrationPath string,
-) (activation.Result, func(), error) {
-	ctx, cancel := context.WithTimeout(parent, activationTimeout)
-	result, err := activation.NewService(client).Create(ctx, roleName, execCtx)
+	return status, nil
+}
 
-	cancel()
+// loadConfig parses wrapper flags from args and resolves the configuration from the App's
+// environment, with the wrapper's own paths relocated under its root. Flag parsing stops at the
+// first non-flag argument, which begins the service command.
+func (a App) loadConfig(args []string) (config.Config, invocation, error) {
+	fs := flag.NewFlagSet(RunCommand, flag.ContinueOnError)
+	config.RegisterFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "print the resolved activation plan as JSON and exit without calling AWS")
 
+	err := fs.Parse(args)
 	if err != nil {
-		return activation.Result{}, nil, fmt.Errorf("create activation: %w", err)
+		return config.Config{}, invocation{}, fmt.Errorf("parse flags: %w", err)
 	}
 
-	log.Printf("created SSM activation id=%s", result.ActivationID)
+	ctx := context.Background()
+
+	cfg, err := config.Load(config.EnvLookup(ctx, env.EnvironLookup(a.environ), secretstore.New()), fs)
+	if err != nil {
+		return config.Config{}, invocation{}, err
+	}
 
-	cleaner := ssmagent.NewCleaner(client, result.ActivationID, registrationPath)
-	cleanupFn := func() {
-		cleanupCtx, cancelCleanup := context.WithTimeout(parent, activationTimeout)
-		defer cancelCleanup()
+	cfg.RegistrationFile = a.root.path(cfg.RegistrationFile)
+	cfg.Agent.ConfigDir = a.root.path(cfg.Agent.ConfigDir)
+	cfg.Control.Socket = a.root.path(cfg.Control.Socket)
 
-		cleanupErr := cleaner.Cleanup(cleanupCtx)
-		if cleanupErr != nil {
-			log.Printf("warning: cleanup failed: %v", cleanupErr)
-		}
+	if fs.NArg() == 0 {
+		return config.Config{}, invocation{}, errArgsMissing
 	}
 
-	return result, cleanupFn, nil
+	return cfg, invocation{command: fs.Args(), dryRun: *dryRun}, nil
 }
 
-func registerAgent(parent context.Context, activationResult activation.Result, region, agentPath string) error {
-	ctx, cancel := context.WithTimeout(parent, registrationTimeout)
-	defer cancel()
+// NewLookup reads the environment with KEY_FILE indirection and resolves ssm-parameter:// and
+// secretsmanager:// references with the default AWS credential chain.
+func NewLookup(ctx context.Context) config.LookupFunc {
+	return config.EnvLookup(ctx, nil, secretstore.New())
+}
 
-	registrationErr := ssmagent.Register(
-		ctx,
-		agentPath,
-		region,
-		activationResult.ActivationID,
-		activationResult.ActivationCode,
-	)
-	if registrationErr != nil {
-		return fmt.Errorf("register SSM agent: %w", registrationErr)
-	}
+// invocation holds the run command's non-configuration arguments.
+type invocation struct {
+	command []string
+	dryRun  bool
+}
 
-	log.Printf("registered amazon-ssm-agent with activation id=%s", activationResult.ActivationID)
+// renderAgentConfig writes amazon-ssm-agent.json and the seelog files before the agent registers
+// or starts.
+func renderAgentConfig(settings config.Agent, endpoints config.Endpoints) error {
+	path, err := agentconfig.WriteAppConfig(settings.ConfigDir, agentconfig.Build(settings, endpoints))
+	if err != nil {
+		return fmt.Errorf("render agent config: %w", err)
+	}
 
-	identityErr := persistIdentity(region)
-	if identityErr != nil {
-		log.Printf("warning: failed to persist identity config: %v", identityErr)
+	seelogPaths, err := agentconfig.WriteSeelog(settings.ConfigDir, settings)
+	if err != nil {
+		return fmt.Errorf("render seelog config: %w", err)
 	}
 
+	slog.Info("rendered agent config", slog.String("path", path), slog.Any("seelog", seelogPaths))
+
 	return nil
 }
 
-func superviseCommand(ctx context.Context, args []string, ttl, grace time.Duration) (int, error) {
-	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // #nosec G204 -- command path managed by container image
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+func discoverExecutionContext(parent context.Context, settings config.Metadata) (execution.Context, error) {
+	ctx, cancel := context.WithTimeout(parent, defaultMetadataTimeout)
+	defer cancel()
+
+	provider := execution.NewProvider(metadata.NewProvider(nil), settings)
+
+	started := time.Now()
+	execCtx, err := provider.Discover(ctx)
+
+	metrics.ObservePhase(metrics.PhaseDiscovery, time.Since(started))
 
-	result, err := supervisor.Run(cmd, ttl, grace)
 	if err != nil {
-		return 1, fmt.Errorf("supervise service: %w", err)
+		return execution.Context{}, fmt.Errorf("discover execution context: %w", err)
 	}
 
-	if result.TTLExpired {
-		log.Printf("ttl elapsed; exiting wrapper with status 0")
+	logging.Annotate(logging.KeyRegion, execCtx.Region)
 
-		return 0, nil
+	if execCtx.TaskARN != "" {
+		logging.Annotate(logging.KeyTaskARN, execCtx.TaskARN)
 	}
 
-	return result.ExitCode, nil
+	slog.Info("discovered execution context", slog.String("availabilityZone", execCtx.AvailabilityZone))
+
+	return execCtx, nil
 }
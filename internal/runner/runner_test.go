@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/hooks"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+func newTestSession(cfg config.Config, client ssmAPI) *session {
+	return &session{
+		app:        NewApp(),
+		cfg:        cfg,
+		args:       nil,
+		px:         nil,
+		hooks:      hooks.Set{},
+		restarts:   nil,
+		agentEnv:   nil,
+		watcher:    nil,
+		ready:      nil,
+		watched:    nil,
+		signals:    nil,
+		interrupt:  nil,
+		wakes:      nil,
+		execCtx:    execution.Context{Region: ssmtest.Region, RegionSource: "", AvailabilityZone: "", TaskARN: ""},
+		client:     client,
+		activation: activation.Result{ActivationID: "", ActivationCode: ""},
+		cleaner:    nil,
+		agent:      nil,
+		exitCode:   0,
+	}
+}
+
+// TestActivationLifecycle drives activation, agent registration and the rollbacks against the
+// fake SSM API and checks nothing is left behind.
+func TestActivationLifecycle(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	cfg := config.Default()
+	cfg.ManagedInstanceRole = "ssm-role"
+	cfg.RegistrationFile = filepath.Join(t.TempDir(), "registration")
+
+	sess := newTestSession(cfg, client)
+
+	err := sess.createActivation(t.Context())
+	if err != nil {
+		t.Fatalf("createActivation: %v", err)
+	}
+
+	result := sess.activation
+
+	// Stand in for amazon-ssm-agent -register, which calls RegisterManagedInstance and records
+	// the instance ID in the registration file.
+	instanceID, err := server.Register(t.Context(), result.ActivationID, result.ActivationCode)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	err = os.WriteFile(cfg.RegistrationFile, []byte(`{"ManagedInstanceID":"`+instanceID+`"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := sess.app
+	app.recordManagedInstanceID(cfg.RegistrationFile)
+
+	if got, err := app.refreshPingStatus(t.Context(), client); err != nil || got != ssmtest.PingStatusOnline {
+		t.Fatalf("ping status = %q, %v; want %s", got, err, ssmtest.PingStatusOnline)
+	}
+
+	err = sess.deregister(t.Context())
+	if err != nil {
+		t.Fatalf("deregister: %v", err)
+	}
+
+	err = sess.deleteActivation(t.Context())
+	if err != nil {
+		t.Fatalf("deleteActivation: %v", err)
+	}
+
+	if server.Activations() != 0 || server.Instances() != 0 {
+		t.Fatalf("state left behind: %d activations, %d instances", server.Activations(), server.Instances())
+	}
+}
+
+func TestActivateFailure(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	server.Inject(ssmtest.OpCreateActivation, ssmtest.Fault{Code: ssmtest.CodeThrottling})
+
+	cfg := config.Default()
+	cfg.ManagedInstanceRole = "ssm-role"
+
+	sess := newTestSession(cfg, server.Client())
+
+	err := sess.createActivation(t.Context())
+	if err == nil || sess.cleaner != nil {
+		t.Fatalf("createActivation = %v, cleaner set: %t; want an error and no cleaner", err, sess.cleaner != nil)
+	}
+
+	err = sess.deleteActivation(t.Context())
+	if err != nil || server.Calls(ssmtest.OpDeleteActivation) != 0 {
+		t.Fatalf("deleteActivation after a failed createActivation = %v; want a no-op", err)
+	}
+}
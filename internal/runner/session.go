@@ -0,0 +1,567 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/activation"
+	"github.com/benwsapp/aws-ssm-minimal/internal/agentlog"
+	"github.com/benwsapp/aws-ssm-minimal/internal/awsconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/containerwatch"
+	"github.com/benwsapp/aws-ssm-minimal/internal/execution"
+	"github.com/benwsapp/aws-ssm-minimal/internal/health"
+	"github.com/benwsapp/aws-ssm-minimal/internal/hooks"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+	"github.com/benwsapp/aws-ssm-minimal/internal/supervisor"
+)
+
+const (
+	discoverTimeout        = 15 * time.Second
+	endpointWarmupTimeout  = 3 * time.Second
+	persistIdentityTimeout = 10 * time.Second
+	onlinePollInterval     = 2 * time.Second
+
+	// phasePrepare names the preparation that runs alongside activation in errors and the
+	// termination report; it has no health phase of its own.
+	phasePrepare = "prepare"
+)
+
+var (
+	errExitedBeforeOnline = errors.New("agent exited before coming online")
+	errChildCrashed       = errors.New("agent exited unexpectedly")
+)
+
+// session carries one run of the wrapper through the lifecycle phases, collecting what each
+// phase creates for the phases and rollbacks after it.
+type session struct {
+	app      App
+	cfg      config.Config
+	args     []string
+	px       *proxy.Proxy
+	hooks    hooks.Set
+	restarts <-chan struct{}
+	agentEnv []string
+	watcher  containerwatch.Watcher
+	ready    containerwatch.Watcher
+	// watched receives the watcher's stop reason; it is set by startWatcher.
+	watched <-chan string
+	// signals replaces the wrapper's own signals when set.
+	signals <-chan os.Signal
+	// interrupt receives the signals while the session runs; runSession sets it.
+	interrupt *interrupter
+	// wakes receives wake requests while the session runs in lazy mode; nil otherwise.
+	wakes <-chan struct{}
+
+	execCtx    execution.Context
+	client     ssmAPI
+	activation activation.Result
+	cleaner    *ssmagent.Cleaner
+	agent      *agentRun
+	exitCode   int
+}
+
+// agentRun tracks the supervised agent from the verify-online phase until it exits.
+type agentRun struct {
+	sup            *supervisor.Supervisor
+	stdout         *agentlog.LineWriter
+	stderr         *agentlog.LineWriter
+	started        chan struct{}
+	stopRequested  chan struct{}
+	stopOnce       sync.Once
+	done           chan struct{}
+	result         supervisor.Result
+	err            error
+	preShutdownErr error
+	// preShutdownDone is closed once the pre-shutdown hook, started with stopRequested, has run.
+	preShutdownDone chan struct{}
+	stopReason      string
+	stopMonitor     context.CancelFunc
+}
+
+// phases returns the lifecycle from discovery until the agent has stopped, waiting for the
+// application to be ready before activating when that is configured.
+func (s *session) phases() []phase {
+	grace := s.cfg.ShutdownGrace.Std()
+
+	phases := []phase{
+		{name: health.PhaseDiscover, timeout: discoverTimeout, run: s.discover, rollback: nil},
+		{name: health.PhaseActivate, timeout: activationTimeout, run: s.activate, rollback: s.deleteActivation},
+		{name: health.PhaseRegister, timeout: registrationTimeout, run: s.register, rollback: s.deregister},
+		{name: health.PhasePersistIdentity, timeout: persistIdentityTimeout, run: s.persistIdentity, rollback: nil},
+		{name: health.PhaseVerifyOnline, timeout: s.cfg.OnlineTimeout.Std(), run: s.verifyOnline, rollback: s.stopAgent},
+		{name: health.PhaseSupervise, timeout: 0, run: s.supervise, rollback: nil},
+		{name: health.PhaseDrain, timeout: grace + outputDrainTimeout, run: s.drain, rollback: nil},
+	}
+
+	if s.ready != nil {
+		phases = slices.Insert(phases, 1,
+			phase{name: health.PhaseWaitReady, timeout: s.cfg.Ready.Timeout.Std(), run: s.waitReady, rollback: nil})
+	}
+
+	return phases
+}
+
+// end returns when the child is due to stop: the deadline when one is set, otherwise the TTL
+// from now.
+func (s *session) end(now time.Time) time.Time {
+	if deadline := s.cfg.Deadline.Std(); !deadline.IsZero() {
+		return deadline
+	}
+
+	return now.Add(s.cfg.TTL.Std())
+}
+
+// discover reads the execution context while the AWS credentials are resolved and, once the
+// region is known, a connection to the SSM endpoint is opened.
+func (s *session) discover(ctx context.Context) error {
+	awsCfg, err := awsconfig.Load(ctx, s.cfg.Metadata.Region, s.cfg.AWS, s.px)
+	if err != nil {
+		return fmt.Errorf("create ssm client: %w", err)
+	}
+
+	var execCtx execution.Context
+
+	discovered := make(chan struct{})
+
+	err = runConcurrently(ctx, s.app.clock,
+		task{name: "discover", run: func(ctx context.Context) error {
+			var err error
+
+			execCtx, err = discoverExecutionContext(ctx, s.cfg.Metadata)
+			if err != nil {
+				return err
+			}
+
+			close(discovered)
+
+			return nil
+		}},
+		task{name: "credentials", run: func(ctx context.Context) error {
+			s.resolveCredentials(ctx, awsCfg)
+
+			return nil
+		}},
+		task{name: "endpoint-warmup", run: func(ctx context.Context) error {
+			select {
+			case <-discovered:
+			case <-ctx.Done():
+				return nil
+			}
+
+			warmCfg := awsCfg.Copy()
+			warmCfg.Region = execCtx.Region
+			s.warmEndpoint(ctx, warmCfg)
+
+			return nil
+		}},
+	)
+	if err != nil {
+		return err
+	}
+
+	awsCfg.Region = execCtx.Region
+	s.execCtx = execCtx
+	s.client = awsconfig.SSMClient(awsCfg, s.cfg.AWS)
+
+	return nil
+}
+
+// resolveCredentials fetches and caches the AWS credentials ahead of the first call. A failure is
+// only logged: the first SSM call retries it and reports the error.
+func (s *session) resolveCredentials(ctx context.Context, awsCfg aws.Config) {
+	started := s.app.clock.Now()
+	creds, err := awsCfg.Credentials.Retrieve(ctx)
+
+	metrics.ObservePhase(metrics.PhaseCredentials, s.app.clock.Now().Sub(started))
+
+	if err != nil {
+		slog.Warn("unable to resolve AWS credentials ahead of activation", logging.Err(err))
+
+		return
+	}
+
+	slog.Debug("resolved AWS credentials", slog.String("source", creds.Source))
+}
+
+// warmEndpoint opens a pooled connection to the SSM endpoint for the activation to reuse. A
+// failure is only logged.
+func (s *session) warmEndpoint(parent context.Context, awsCfg aws.Config) {
+	ctx, cancel := context.WithTimeout(parent, endpointWarmupTimeout)
+	defer cancel()
+
+	started := s.app.clock.Now()
+	err := awsconfig.WarmSSMEndpoint(ctx, awsCfg, s.cfg.AWS)
+
+	metrics.ObservePhase(metrics.PhaseEndpointWarmup, s.app.clock.Now().Sub(started))
+
+	if err != nil {
+		slog.Debug("unable to warm SSM endpoint", logging.Err(err))
+	}
+}
+
+// activate creates the activation while the agent config is rendered and the directories the
+// agent and wrapper write to are created.
+func (s *session) activate(ctx context.Context) error {
+	return runConcurrently(ctx, s.app.clock,
+		task{name: "activation", run: s.createActivation},
+		task{name: phasePrepare, run: s.prepare},
+	)
+}
+
+// prepare readies the filesystem for registration. Its errors are attributed to phasePrepare.
+func (s *session) prepare(context.Context) error {
+	started := s.app.clock.Now()
+
+	err := renderAgentConfig(s.cfg.Agent, s.cfg.AWS.Endpoints)
+	if err == nil {
+		err = prepareFilesystem(s.app.root, s.cfg.RegistrationFile)
+	}
+
+	metrics.ObservePhase(metrics.PhasePrepare, s.app.clock.Now().Sub(started))
+
+	if err != nil {
+		return &PhaseError{Phase: phasePrepare, Err: err}
+	}
+
+	return nil
+}
+
+func (s *session) createActivation(ctx context.Context) error {
+	started := s.app.clock.Now()
+	result, err := activation.NewService(s.client, s.cfg.Activation).Create(ctx, s.cfg.ManagedInstanceRole, s.execCtx)
+
+	metrics.ObservePhase(metrics.PhaseActivation, s.app.clock.Now().Sub(started))
+
+	if err != nil {
+		return fmt.Errorf("create activation: %w", err)
+	}
+
+	logging.Annotate(logging.KeyActivationID, result.ActivationID)
+	slog.Info("created SSM activation")
+
+	s.activation = result
+	s.cleaner = ssmagent.NewCleaner(s.client, result.ActivationID, s.cfg.RegistrationFile)
+	s.app.state.SetActivationID(result.ActivationID)
+
+	return nil
+}
+
+func (s *session) deleteActivation(ctx context.Context) error {
+	if s.cleaner == nil {
+		return nil
+	}
+
+	return s.cleaner.DeleteActivation(ctx)
+}
+
+func (s *session) register(ctx context.Context) error {
+	started := s.app.clock.Now()
+	err := ssmagent.Register(
+		ctx,
+		s.app.command,
+		s.args[0],
+		s.execCtx.Region,
+		s.activation.ActivationID,
+		s.activation.ActivationCode,
+		s.agentEnv,
+	)
+
+	metrics.ObservePhase(metrics.PhaseRegistration, s.app.clock.Now().Sub(started))
+
+	if err != nil {
+		return fmt.Errorf("register SSM agent: %w", err)
+	}
+
+	slog.Info("registered amazon-ssm-agent")
+	s.app.recordManagedInstanceID(s.cfg.RegistrationFile)
+
+	return s.hooks.PostRegistration.Run(ctx, s.app.hookPayload(s.execCtx, ""))
+}
+
+func (s *session) deregister(ctx context.Context) error {
+	if s.cleaner == nil {
+		return nil
+	}
+
+	return s.cleaner.DeregisterInstance(ctx)
+}
+
+func (s *session) persistIdentity(context.Context) error {
+	return persistIdentity(s.app.root, s.cfg.RegistrationFile, s.execCtx.Region)
+}
+
+// verifyOnline starts the agent and waits for SSM to report the instance online. A zero online
+// timeout only starts the agent.
+func (s *session) verifyOnline(ctx context.Context) error {
+	s.startAgent()
+
+	select {
+	case <-s.agent.started:
+	case <-s.agent.done:
+		if s.agent.err != nil {
+			return fmt.Errorf("start agent: %w", s.agent.err)
+		}
+
+		return fmt.Errorf("%w: exit status %d", errExitedBeforeOnline, s.agent.result.ExitCode)
+	}
+
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	s.agent.stopMonitor = stopMonitor
+
+	if s.cfg.OnlineTimeout.Std() > 0 {
+		err := s.waitOnline(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	go s.app.monitorPingStatus(monitorCtx, s.client)
+
+	if s.watched != nil {
+		go s.watchContainers(monitorCtx)
+	}
+
+	if s.wakes != nil {
+		go s.watchIdle(monitorCtx)
+	}
+
+	slog.Info("agent ready", slog.Duration("startup", s.app.clock.Now().Sub(s.app.startedAt)))
+
+	return nil
+}
+
+// waitOnline polls the ping status until it is Online, the agent exits or ctx ends.
+func (s *session) waitOnline(ctx context.Context) error {
+	ticker := s.app.clock.NewTicker(onlinePollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := s.app.refreshPingStatus(ctx, s.client)
+		if errors.Is(err, ssmagent.ErrDescribeDenied) {
+			return fmt.Errorf("wait for managed instance to come online: %w; grant it or set %s=0",
+				err, internal.EnvOnlineTimeoutSeconds)
+		}
+
+		if status == string(types.PingStatusOnline) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for managed instance to come online: %w", ctx.Err())
+		case <-s.agent.done:
+			return fmt.Errorf("%w: exit status %d", errExitedBeforeOnline, s.agent.result.ExitCode)
+		case <-ticker.C():
+		}
+	}
+}
+
+// startAgent starts supervising the agent in the background. The agent's context is not bound
+// to any phase: it runs until the supervisor stops it.
+func (s *session) startAgent() {
+	s.interrupt.agentStarting()
+
+	ctx := context.Background()
+	signals := make(chan supervisor.HealthSignal, healthSignalBuffer)
+	processor := agentlog.NewProcessor(signals)
+
+	run := &agentRun{
+		sup:             nil,
+		stdout:          processor.Writer("stdout"),
+		stderr:          processor.Writer("stderr"),
+		started:         make(chan struct{}),
+		stopRequested:   make(chan struct{}),
+		stopOnce:        sync.Once{},
+		done:            make(chan struct{}),
+		result:          supervisor.Result{ExitCode: 0, TTLExpired: false, Stopped: false, Unhealthy: nil},
+		err:             nil,
+		preShutdownErr:  nil,
+		preShutdownDone: make(chan struct{}),
+		stopReason:      "",
+		stopMonitor:     func() {},
+	}
+
+	newCmd := func() *exec.Cmd {
+		cmd := s.app.command(ctx, s.args[0], s.args[1:]...)
+		cmd.Stdout = run.stdout
+		cmd.Stderr = run.stderr
+		cmd.Stdin = os.Stdin
+		cmd.Env = s.agentEnv
+		// Session workers inherit the output pipes; bound how long Wait drains them after the agent exits.
+		cmd.WaitDelay = outputDrainTimeout
+
+		return cmd
+	}
+
+	onStart := func(pid int) {
+		s.app.state.SetChildPID(pid)
+
+		select {
+		case <-run.started:
+			s.app.state.IncrementRestarts()
+		default:
+			s.app.state.SetDeadline(s.end(s.app.clock.Now()))
+			close(run.started)
+		}
+	}
+
+	// The supervisor calls this once, after it first asks the agent to stop. The hook runs in the
+	// background so that the supervisor keeps forwarding signals and reaping the agent; drain
+	// collects its result.
+	onPreShutdown := func(reason string) {
+		run.stopReason = reason
+		run.stopOnce.Do(func() { close(run.stopRequested) })
+
+		payload := s.app.hookPayload(s.execCtx, reason)
+
+		go func() {
+			defer close(run.preShutdownDone)
+
+			run.preShutdownErr = s.hooks.PreShutdown.Run(ctx, payload)
+		}()
+	}
+
+	opts := []supervisor.Option{
+		supervisor.WithStartHook(onStart),
+		supervisor.WithHealthSignals(signals),
+		supervisor.WithPreShutdownHook(onPreShutdown),
+		supervisor.WithRestarts(s.restarts, newCmd),
+		supervisor.WithClock(s.app.clock),
+	}
+
+	if deadline := s.cfg.Deadline.Std(); !deadline.IsZero() {
+		opts = append(opts, supervisor.WithDeadline(deadline))
+	}
+
+	if s.signals != nil {
+		opts = append(opts, supervisor.WithSignals(s.signals))
+	}
+
+	run.sup = supervisor.NewSupervisor(newCmd(), s.cfg.TTL.Std(), s.cfg.ShutdownGrace.Std(), opts...)
+	s.agent = run
+
+	go func() {
+		run.result, run.err = run.sup.Run()
+		close(run.done)
+	}()
+}
+
+// stopAgent stops the agent if it is still running and waits for it to exit.
+func (s *session) stopAgent(ctx context.Context) error {
+	if s.agent == nil {
+		return nil
+	}
+
+	defer s.agent.stopMonitor()
+
+	s.agent.sup.Stop("rollback")
+
+	select {
+	case <-s.agent.done:
+	case <-ctx.Done():
+		return fmt.Errorf("wait for agent to exit: %w", ctx.Err())
+	}
+
+	return s.agent.waitForPreShutdown(ctx)
+}
+
+// supervise waits until the agent is asked to stop or exits on its own.
+func (s *session) supervise(context.Context) error {
+	select {
+	case <-s.agent.stopRequested:
+		return nil
+	case <-s.agent.done:
+		return s.agent.failure()
+	}
+}
+
+// drain waits for the stopping agent to exit, its output to be flushed and the pre-shutdown hook
+// to finish, then records its exit status.
+func (s *session) drain(ctx context.Context) error {
+	select {
+	case <-s.agent.done:
+	case <-ctx.Done():
+		return fmt.Errorf("wait for agent to exit: %w", ctx.Err())
+	}
+
+	hookErr := s.agent.waitForPreShutdown(ctx)
+
+	s.agent.stopMonitor()
+	s.agent.stdout.Flush()
+	s.agent.stderr.Flush()
+	s.app.state.SetChildPID(0)
+
+	err := s.agent.failure()
+	if err != nil {
+		return err
+	}
+
+	if hookErr != nil {
+		return hookErr
+	}
+
+	if s.agent.result.TTLExpired {
+		slog.Info("ttl elapsed; exiting wrapper with status 0")
+	}
+
+	if s.agent.result.Stopped {
+		slog.Info("agent stopped on request; exiting wrapper with status 0", slog.String("reason", s.agent.stopReason))
+
+		return nil
+	}
+
+	s.exitCode = s.agent.result.ExitCode
+
+	return nil
+}
+
+// waitForPreShutdown waits for the pre-shutdown hook, if the agent was asked to stop, and returns
+// its error.
+func (r *agentRun) waitForPreShutdown(ctx context.Context) error {
+	select {
+	case <-r.stopRequested:
+	default:
+		return nil
+	}
+
+	select {
+	case <-r.preShutdownDone:
+		return r.preShutdownErr
+	case <-ctx.Done():
+		return fmt.Errorf("wait for %s hook: %w", hooks.EventPreShutdown, ctx.Err())
+	}
+}
+
+// failure reports why the agent's run went wrong, if it did, as a supervise-phase error: it could
+// not be supervised, reported a fatal condition, or exited non-zero without the TTL or a stop
+// request ending it.
+func (r *agentRun) failure() error {
+	var err error
+
+	switch signal := r.result.Unhealthy; {
+	case r.err != nil:
+		err = fmt.Errorf("supervise service: %w", r.err)
+	case signal != nil:
+		err = fmt.Errorf("%w: %s: %s", errAgentUnhealthy, signal.Kind, signal.Message)
+	case !r.result.TTLExpired && !r.result.Stopped && r.result.ExitCode != 0:
+		err = fmt.Errorf("%w: exit status %d", errChildCrashed, r.result.ExitCode)
+	default:
+		return nil
+	}
+
+	return &PhaseError{Phase: health.PhaseSupervise, Err: err}
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/containerwatch"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metadata"
+)
+
+var errNotReady = errors.New("application not ready")
+
+// newWatcher returns the watcher cfg selects: ECS containers, a process in the pod or a completion
+// file. It returns nil when nothing is watched.
+func (a App) newWatcher(cfg config.Config) containerwatch.Watcher {
+	interval := cfg.Watch.Interval.Std()
+
+	switch {
+	case len(cfg.Watch.Containers) > 0:
+		return containerwatch.NewECS(metadata.NewProvider(nil), cfg.Metadata.URI, cfg.Watch.Containers,
+			interval, a.clock)
+	case cfg.Watch.Process != "":
+		return containerwatch.NewProcess(a.root.path(procRoot), cfg.Watch.Process, interval, a.clock)
+	case cfg.Watch.File != "":
+		return containerwatch.NewFile(cfg.Watch.File, interval, a.clock)
+	default:
+		return nil
+	}
+}
+
+// newReadyWatcher returns the watcher for the readiness cfg waits for before activating, or nil
+// when activation does not wait.
+func (a App) newReadyWatcher(cfg config.Config) containerwatch.Watcher {
+	switch {
+	case len(cfg.Ready.Containers) > 0:
+		return containerwatch.NewECSHealthy(metadata.NewProvider(nil), cfg.Metadata.URI, cfg.Ready.Containers,
+			cfg.Watch.Interval.Std(), a.clock)
+	case cfg.Ready.File != "":
+		return containerwatch.NewFile(cfg.Ready.File, cfg.Watch.Interval.Std(), a.clock)
+	default:
+		return nil
+	}
+}
+
+// waitReady waits for the application to be ready before anything is created in SSM. With the
+// continue policy an application that never gets ready is only logged; one whose container
+// stopped fails whatever the policy, as there is nothing left to debug.
+func (s *session) waitReady(ctx context.Context) error {
+	reason, err := s.ready.Wait(ctx)
+	switch {
+	case err == nil:
+		slog.Info("application ready", slog.String("reason", reason))
+
+		return nil
+	case errors.Is(err, containerwatch.ErrStopped):
+		return fmt.Errorf("%w: %w", errNotReady, err)
+	case s.cfg.Ready.Policy == config.ReadyPolicyContinue:
+		slog.Warn("application not ready; continuing", logging.Err(err))
+
+		return nil
+	default:
+		return fmt.Errorf("%w: %w", errNotReady, err)
+	}
+}
+
+// startWatcher starts the watcher for the whole lifecycle, so a main container that finishes
+// before the agent is online is still seen, and returns the function that stops it.
+func (s *session) startWatcher() context.CancelFunc {
+	if s.watcher == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan string, 1)
+	s.watched = watched
+
+	go func() {
+		reason, err := s.watcher.Wait(ctx)
+		if err == nil {
+			watched <- reason
+		}
+	}()
+
+	return cancel
+}
+
+// watchContainers stops the agent once a watched container has finished, unless ctx ends first.
+func (s *session) watchContainers(ctx context.Context) {
+	var reason string
+
+	select {
+	case <-ctx.Done():
+		return
+	case reason = <-s.watched:
+	}
+
+	slog.Info("watched container finished; stopping agent", slog.String("reason", reason))
+	s.agent.sup.Stop(reason)
+}
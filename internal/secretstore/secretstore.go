@@ -0,0 +1,125 @@
+// Package secretstore resolves ssm-parameter:// and secretsmanager:// references against AWS.
+package secretstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/benwsapp/aws-ssm-minimal/internal/awsconfig"
+	"github.com/benwsapp/aws-ssm-minimal/internal/config"
+	"github.com/benwsapp/aws-ssm-minimal/internal/proxy"
+)
+
+const (
+	arnParts    = 6
+	arnRegion   = 3
+	arnPrefix   = "arn:"
+	noRegionKey = ""
+)
+
+var (
+	errNoParameter  = errors.New("empty response")
+	errBinarySecret = errors.New("secret has no string value")
+)
+
+// Store implements env.SecretStore with SSM Parameter Store and Secrets Manager. Clients are
+// created on first use, one per region, so configuration without references never loads
+// AWS credentials. References are resolved before the configuration is loaded, so endpoint,
+// FIPS, CA bundle and proxy settings apply only through their environment variables.
+type Store struct {
+	mu      sync.Mutex
+	configs map[string]aws.Config
+}
+
+// New returns an empty Store.
+func New() *Store {
+	return &Store{mu: sync.Mutex{}, configs: map[string]aws.Config{}}
+}
+
+// GetParameter returns the decrypted value of an SSM parameter given by name or ARN.
+func (s *Store) GetParameter(ctx context.Context, name string) (string, error) {
+	cfg, err := s.config(ctx, regionFromARN(name))
+	if err != nil {
+		return "", err
+	}
+
+	output, err := awsconfig.SSMClient(cfg, config.AWS{}).GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("ssm:GetParameter %s: %w", name, err)
+	}
+
+	if output.Parameter == nil {
+		return "", fmt.Errorf("ssm:GetParameter %s: %w", name, errNoParameter)
+	}
+
+	return aws.ToString(output.Parameter.Value), nil
+}
+
+// GetSecret returns the string value of a Secrets Manager secret given by name or ARN.
+func (s *Store) GetSecret(ctx context.Context, id string) (string, error) {
+	cfg, err := s.config(ctx, regionFromARN(id))
+	if err != nil {
+		return "", err
+	}
+
+	output, err := awsconfig.SecretsManagerClient(cfg, config.AWS{}).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", fmt.Errorf("secretsmanager:GetSecretValue %s: %w", id, err)
+	}
+
+	if output.SecretString == nil {
+		return "", fmt.Errorf("secretsmanager:GetSecretValue %s: %w", id, errBinarySecret)
+	}
+
+	return aws.ToString(output.SecretString), nil
+}
+
+// config returns the cached AWS configuration for region. An empty region leaves the SDK to
+// resolve it from AWS_REGION or the shared config files.
+func (s *Store) config(ctx context.Context, region string) (aws.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cfg, ok := s.configs[region]; ok {
+		return cfg, nil
+	}
+
+	px, err := proxy.FromEnvironment()
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("secret store: %w", err)
+	}
+
+	cfg, err := awsconfig.Load(ctx, region, config.AWS{}, px)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("secret store: %w", err)
+	}
+
+	s.configs[region] = cfg
+
+	return cfg, nil
+}
+
+// regionFromARN returns the region of an ARN, or an empty string for plain names.
+func regionFromARN(id string) string {
+	if !strings.HasPrefix(id, arnPrefix) {
+		return noRegionKey
+	}
+
+	parts := strings.SplitN(id, ":", arnParts)
+	if len(parts) != arnParts {
+		return noRegionKey
+	}
+
+	return parts[arnRegion]
+}
@@ -0,0 +1,104 @@
+package secretstore
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegionFromARN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "/ttl/role", want: ""},
+		{id: "ttl/token", want: ""},
+		{id: "arn:aws:ssm:eu-west-1:123456789012:parameter/ttl/role", want: "eu-west-1"},
+		{id: "arn:aws:secretsmanager:us-east-2:123456789012:secret:ttl/token-AbCdEf", want: "us-east-2"},
+		{id: "arn:aws-us-gov:ssm:us-gov-west-1:123456789012:parameter/x", want: "us-gov-west-1"},
+		{id: "arn:aws:ssm", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := regionFromARN(tt.id); got != tt.want {
+			t.Errorf("regionFromARN(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+// fakeAWS answers GetParameter and GetSecretValue and records the signing region of each call.
+type fakeAWS struct {
+	mu      sync.Mutex
+	regions []string
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The credential scope is "<key>/<date>/<region>/<service>/aws4_request".
+	_, scope, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	if parts := strings.Split(scope, "/"); len(parts) > 2 {
+		f.mu.Lock()
+		f.regions = append(f.regions, parts[2])
+		f.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "AmazonSSM.GetParameter":
+		_, _ = w.Write([]byte(`{"Parameter":{"Name":"/ttl/role","Value":"from-parameter"}}`))
+	case "secretsmanager.GetSecretValue":
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"SecretId":"binary"`) {
+			_, _ = w.Write([]byte(`{"Name":"binary","SecretBinary":"AAEC"}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"Name":"ttl/token","SecretString":"s3cret"}`))
+	default:
+		http.Error(w, "unexpected target", http.StatusBadRequest)
+	}
+}
+
+func TestStore(t *testing.T) {
+	fake := &fakeAWS{mu: sync.Mutex{}, regions: nil}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+
+	store := New()
+
+	value, err := store.GetParameter(t.Context(), "/ttl/role")
+	if err != nil || value != "from-parameter" {
+		t.Errorf("GetParameter = %q, %v; want from-parameter", value, err)
+	}
+
+	value, err = store.GetSecret(t.Context(), "arn:aws:secretsmanager:eu-west-1:123456789012:secret:ttl/token")
+	if err != nil || value != "s3cret" {
+		t.Errorf("GetSecret = %q, %v; want s3cret", value, err)
+	}
+
+	if _, err := store.GetSecret(t.Context(), "binary"); !errors.Is(err, errBinarySecret) {
+		t.Errorf("GetSecret(binary) = %v, want %v", err, errBinarySecret)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	want := []string{"us-west-2", "eu-west-1", "us-west-2"}
+	if strings.Join(fake.regions, ",") != strings.Join(want, ",") {
+		t.Errorf("signing regions = %v, want %v: an ARN selects its own region", fake.regions, want)
+	}
+}
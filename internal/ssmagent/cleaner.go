@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 	"sync"
@@ -14,27 +14,59 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
 )
 
-const cleanupTimeout = 30 * time.Second
+const (
+	cleanupTimeout = 30 * time.Second
+
+	stepDeleteActivation   = "delete_activation"
+	stepDeregisterInstance = "deregister_instance"
+)
+
+// CleanupAPI is the set of SSM operations Cleaner needs; *ssm.Client satisfies it.
+type CleanupAPI interface {
+	DeleteActivation(ctx context.Context, params *ssm.DeleteActivationInput, optFns ...func(*ssm.Options)) (
+		*ssm.DeleteActivationOutput, error)
+	DeregisterManagedInstance(ctx context.Context, params *ssm.DeregisterManagedInstanceInput,
+		optFns ...func(*ssm.Options)) (*ssm.DeregisterManagedInstanceOutput, error)
+}
 
 // Cleaner tears down activations and managed instance registrations.
 type Cleaner struct {
 	once sync.Once
 
-	client           *ssm.Client
+	client           CleanupAPI
 	activationID     string
 	registrationPath string
+	instanceID       string
+}
+
+// CleanerOption customizes a Cleaner.
+type CleanerOption func(*Cleaner)
+
+// WithInstanceID deregisters the given managed instance instead of the one in the registration file.
+func WithInstanceID(instanceID string) CleanerOption {
+	return func(c *Cleaner) {
+		c.instanceID = instanceID
+	}
 }
 
 // NewCleaner constructs a Cleaner tied to the provided activation metadata.
-func NewCleaner(client *ssm.Client, activationID, registrationPath string) *Cleaner {
-	return &Cleaner{
+func NewCleaner(client CleanupAPI, activationID, registrationPath string, opts ...CleanerOption) *Cleaner {
+	cleaner := &Cleaner{
 		once:             sync.Once{},
 		client:           client,
 		activationID:     activationID,
 		registrationPath: registrationPath,
+		instanceID:       "",
+	}
+
+	for _, opt := range opts {
+		opt(cleaner)
 	}
+
+	return cleaner
 }
 
 // Cleanup removes the activation and managed instance registration, returning the first error encountered.
@@ -52,59 +84,83 @@ func (c *Cleaner) runCleanup(ctx context.Context) error {
 	cleanupCtx, cancel := context.WithTimeout(ctx, cleanupTimeout)
 	defer cancel()
 
-	deleteErr := c.deleteActivation(cleanupCtx)
-	if deleteErr != nil {
-		return deleteErr
+	err := c.DeleteActivation(cleanupCtx)
+	if err != nil {
+		return err
 	}
 
-	return c.deregisterInstance(cleanupCtx)
+	return c.DeregisterInstance(cleanupCtx)
 }
 
-func (c *Cleaner) deleteActivation(ctx context.Context) error {
+// DeleteActivation deletes the activation, if one was given. Unlike Cleanup it runs on every call.
+func (c *Cleaner) DeleteActivation(ctx context.Context) error {
 	if c.activationID == "" {
+		metrics.ObserveCleanup(stepDeleteActivation, metrics.OutcomeSkipped)
+
 		return nil
 	}
 
 	input := &ssm.DeleteActivationInput{ActivationId: aws.String(c.activationID)}
 
-	deleteResult, deleteErr := c.client.DeleteActivation(ctx, input)
-	_ = deleteResult
+	_, err := c.client.DeleteActivation(ctx, input)
 
-	if deleteErr != nil {
-		return fmt.Errorf("delete activation %s: %w", c.activationID, deleteErr)
+	metrics.ObserveAWSCall("DeleteActivation", err)
+
+	if err != nil {
+		metrics.ObserveCleanup(stepDeleteActivation, metrics.OutcomeFailure)
+
+		return fmt.Errorf("delete activation %s: %w", c.activationID, err)
 	}
 
-	log.Printf("deleted activation %s", c.activationID)
+	metrics.ObserveCleanup(stepDeleteActivation, metrics.OutcomeSuccess)
+	slog.Info("deleted activation")
 
 	return nil
 }
 
-func (c *Cleaner) deregisterInstance(ctx context.Context) error {
-	instanceID, err := readManagedInstanceID(c.registrationPath)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
+// DeregisterInstance deregisters the managed instance, skipping it when no instance ID was given
+// and the registration file does not exist. Unlike Cleanup it runs on every call.
+func (c *Cleaner) DeregisterInstance(ctx context.Context) error {
+	instanceID := c.instanceID
+	if instanceID == "" {
+		recorded, err := ReadManagedInstanceID(c.registrationPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				metrics.ObserveCleanup(stepDeregisterInstance, metrics.OutcomeSkipped)
+
+				return nil
+			}
+
+			metrics.ObserveCleanup(stepDeregisterInstance, metrics.OutcomeFailure)
+
+			return fmt.Errorf("read registration: %w", err)
 		}
 
-		return fmt.Errorf("read registration: %w", err)
+		instanceID = recorded
 	}
 
 	if instanceID == "" {
-		log.Printf("warning: managed instance ID was empty; skipping deregistration")
+		slog.Warn("managed instance ID was empty; skipping deregistration")
+		metrics.ObserveCleanup(stepDeregisterInstance, metrics.OutcomeSkipped)
 
 		return nil
 	}
 
 	input := &ssm.DeregisterManagedInstanceInput{InstanceId: aws.String(instanceID)}
 
-	deregisterResult, deregisterErr := c.client.DeregisterManagedInstance(ctx, input)
-	_ = deregisterResult
+	_, err := c.client.DeregisterManagedInstance(ctx, input)
+
+	metrics.ObserveAWSCall("DeregisterManagedInstance", err)
 
-	if deregisterErr != nil {
-		return fmt.Errorf("deregister instance %s: %w", instanceID, deregisterErr)
+	if err != nil {
+		metrics.ObserveCleanup(stepDeregisterInstance, metrics.OutcomeFailure)
+
+		return fmt.Errorf("deregister instance %s: %w", instanceID, err)
 	}
 
-	log.Printf("deregistered managed instance %s", instanceID)
+	metrics.ObserveCleanup(stepDeregisterInstance, metrics.OutcomeSuccess)
+
+	slog.Info("deregistered managed instance")
 
 	return nil
 }
@@ -114,7 +170,8 @@ type registrationDocument struct {
 	ManagedInstanceID string `json:"ManagedInstanceID"` //nolint:tagliatelle // controlled by AWS agent
 }
 
-func readManagedInstanceID(path string) (string, error) {
+// ReadManagedInstanceID returns the managed instance ID recorded in the agent registration file.
+func ReadManagedInstanceID(path string) (string, error) {
 	data, err := os.ReadFile(path) // #nosec G304 -- path validated before call
 	if err != nil {
 		return "", fmt.Errorf("read registration file: %w", err)
@@ -0,0 +1,171 @@
+package ssmagent_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+// registered creates an activation on server and registers one instance with it.
+func registered(t *testing.T, server *ssmtest.Server) (string, string) {
+	t.Helper()
+
+	created, err := server.Client().CreateActivation(t.Context(), &ssm.CreateActivationInput{IamRole: aws.String("role")})
+	if err != nil {
+		t.Fatalf("CreateActivation: %v", err)
+	}
+
+	instanceID, err := server.Register(t.Context(), aws.ToString(created.ActivationId), aws.ToString(created.ActivationCode))
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	return aws.ToString(created.ActivationId), instanceID
+}
+
+func writeRegistration(t *testing.T, instanceID string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "registration")
+
+	err := os.WriteFile(path, []byte(`{"ManagedInstanceID":"`+instanceID+`","Region":"us-east-1"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCleanupFromRegistrationFile(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	activationID, instanceID := registered(t, server)
+	cleaner := ssmagent.NewCleaner(server.Client(), activationID, writeRegistration(t, instanceID))
+
+	err := cleaner.Cleanup(t.Context())
+	if err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+
+	if server.Activations() != 0 || server.Instances() != 0 {
+		t.Fatalf("state left behind: %d activations, %d instances", server.Activations(), server.Instances())
+	}
+
+	// Cleanup runs once; a second call must not reach the API.
+	err = cleaner.Cleanup(t.Context())
+	if err != nil || server.Calls(ssmtest.OpDeleteActivation) != 1 {
+		t.Fatalf("second Cleanup: err=%v, DeleteActivation calls=%d", err, server.Calls(ssmtest.OpDeleteActivation))
+	}
+}
+
+func TestCleanupWithInstanceID(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	activationID, instanceID := registered(t, server)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	err := ssmagent.NewCleaner(server.Client(), activationID, missing, ssmagent.WithInstanceID(instanceID)).
+		Cleanup(t.Context())
+	if err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+
+	if _, ok := server.Instance(instanceID); ok {
+		t.Fatal("instance was not deregistered")
+	}
+}
+
+func TestCleanupSkipsMissingRegistration(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	activationID, _ := registered(t, server)
+
+	err := ssmagent.NewCleaner(server.Client(), activationID, filepath.Join(t.TempDir(), "missing")).
+		Cleanup(t.Context())
+	if err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+
+	if server.Activations() != 0 || server.Calls(ssmtest.OpDeregisterManagedInstance) != 0 {
+		t.Fatal("expected the activation deleted and no deregistration attempted")
+	}
+}
+
+func TestCleanupStopsWhenDeleteFails(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	activationID, instanceID := registered(t, server)
+	server.Inject(ssmtest.OpDeleteActivation, ssmtest.Fault{Code: ssmtest.CodeInternalServerError, Status: 500})
+
+	err := ssmagent.NewCleaner(server.Client(), activationID, writeRegistration(t, instanceID)).Cleanup(t.Context())
+	if err == nil {
+		t.Fatal("Cleanup succeeded despite the injected fault")
+	}
+
+	if server.Calls(ssmtest.OpDeregisterManagedInstance) != 0 {
+		t.Fatal("deregistration was attempted after the delete failed")
+	}
+
+	if _, ok := server.Instance(instanceID); !ok {
+		t.Fatal("instance should still be registered")
+	}
+}
+
+func TestPingStatus(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	_, instanceID := registered(t, server)
+	client := server.Client()
+
+	status, err := ssmagent.PingStatus(t.Context(), client, instanceID)
+	if err != nil || status != ssmtest.PingStatusOnline {
+		t.Fatalf("PingStatus = %q, %v; want %s", status, err, ssmtest.PingStatusOnline)
+	}
+
+	server.SetPingStatus(instanceID, "ConnectionLost")
+
+	status, err = ssmagent.PingStatus(t.Context(), client, instanceID)
+	if err != nil || status != "ConnectionLost" {
+		t.Fatalf("PingStatus = %q, %v; want ConnectionLost", status, err)
+	}
+
+	_, err = ssmagent.PingStatus(t.Context(), client, "mi-00000000000000000")
+	if err == nil {
+		t.Fatal("PingStatus succeeded for an unknown instance")
+	}
+}
+
+func TestReadManagedInstanceID(t *testing.T) {
+	t.Parallel()
+
+	id, err := ssmagent.ReadManagedInstanceID(writeRegistration(t, " mi-0123456789abcdef0 "))
+	if err != nil || id != "mi-0123456789abcdef0" {
+		t.Fatalf("ReadManagedInstanceID = %q, %v", id, err)
+	}
+
+	_, err = ssmagent.ReadManagedInstanceID(filepath.Join(t.TempDir(), "missing"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("error = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,89 @@
+package ssmagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
+)
+
+// ReapAPI is the set of SSM operations Reap needs; *ssm.Client satisfies it.
+type ReapAPI interface {
+	DescribeAPI
+	DeregisterManagedInstance(ctx context.Context, params *ssm.DeregisterManagedInstanceInput,
+		optFns ...func(*ssm.Options)) (*ssm.DeregisterManagedInstanceOutput, error)
+}
+
+// StaleInstance is a managed instance registered by a wrapper that has stopped pinging.
+type StaleInstance struct {
+	InstanceID string
+	LastPing   time.Time
+}
+
+// Reap deregisters the managed instances registered by wrappers, recognised by the activation tag
+// they inherit, that have been ConnectionLost since before cutoff: the leftovers of wrappers that
+// were killed before they could clean up. With dryRun it only lists them. It returns the stale
+// instances and the deregistration failures joined together.
+func Reap(ctx context.Context, client ReapAPI, cutoff time.Time, dryRun bool) ([]StaleInstance, error) {
+	input := &ssm.DescribeInstanceInformationInput{
+		Filters: []types.InstanceInformationStringFilter{
+			{
+				Key:    aws.String("tag:" + internal.FaultInjectionSidecarTagKey),
+				Values: []string{internal.FaultInjectionSidecarTagValue},
+			},
+			{Key: aws.String("PingStatus"), Values: []string{string(types.PingStatusConnectionLost)}},
+		},
+	}
+
+	var stale []StaleInstance
+
+	paginator := ssm.NewDescribeInstanceInformationPaginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		metrics.ObserveAWSCall("DescribeInstanceInformation", err)
+
+		if err != nil {
+			return stale, fmt.Errorf("describe stale instances: %w", err)
+		}
+
+		for _, info := range page.InstanceInformationList {
+			lastPing := aws.ToTime(info.LastPingDateTime)
+			if lastPing.Before(cutoff) {
+				stale = append(stale, StaleInstance{InstanceID: aws.ToString(info.InstanceId), LastPing: lastPing})
+			}
+		}
+	}
+
+	if dryRun {
+		return stale, nil
+	}
+
+	var errs []error
+
+	for _, instance := range stale {
+		_, err := client.DeregisterManagedInstance(ctx,
+			&ssm.DeregisterManagedInstanceInput{InstanceId: aws.String(instance.InstanceID)})
+		metrics.ObserveAWSCall("DeregisterManagedInstance", err)
+
+		if err != nil {
+			slog.Warn("failed to deregister stale instance",
+				slog.String(logging.KeyManagedInstanceID, instance.InstanceID), logging.Err(err))
+			errs = append(errs, fmt.Errorf("deregister instance %s: %w", instance.InstanceID, err))
+
+			continue
+		}
+
+		slog.Info("deregistered stale instance",
+			slog.String(logging.KeyManagedInstanceID, instance.InstanceID), slog.Time("lastPing", instance.LastPing))
+	}
+
+	return stale, errors.Join(errs...)
+}
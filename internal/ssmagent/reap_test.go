@@ -0,0 +1,81 @@
+package ssmagent_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/benwsapp/aws-ssm-minimal/internal"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+// registerAt registers an instance at registered, tagged as the wrapper's when wrapper is set,
+// and gives it status.
+func registerAt(t *testing.T, server *ssmtest.Server, registered time.Time, wrapper bool, status string) string {
+	t.Helper()
+
+	input := &ssm.CreateActivationInput{IamRole: aws.String("role")}
+	if wrapper {
+		input.Tags = []types.Tag{{
+			Key:   aws.String(internal.FaultInjectionSidecarTagKey),
+			Value: aws.String(internal.FaultInjectionSidecarTagValue),
+		}}
+	}
+
+	created, err := server.Client().CreateActivation(t.Context(), input)
+	if err != nil {
+		t.Fatalf("CreateActivation: %v", err)
+	}
+
+	server.SetNow(func() time.Time { return registered })
+	defer server.SetNow(time.Now)
+
+	instanceID, err := server.Register(t.Context(), aws.ToString(created.ActivationId), aws.ToString(created.ActivationCode))
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	server.SetPingStatus(instanceID, status)
+
+	return instanceID
+}
+
+func TestReap(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	now := time.Now()
+	stale := registerAt(t, server, now.Add(-2*time.Hour), true, "ConnectionLost")
+	recent := registerAt(t, server, now.Add(-10*time.Minute), true, "ConnectionLost")
+	online := registerAt(t, server, now.Add(-2*time.Hour), true, ssmtest.PingStatusOnline)
+	foreign := registerAt(t, server, now.Add(-2*time.Hour), false, "ConnectionLost")
+
+	found, err := ssmagent.Reap(t.Context(), server.Client(), now.Add(-time.Hour), true)
+	if err != nil || len(found) != 1 || found[0].InstanceID != stale {
+		t.Fatalf("dry-run Reap = %+v, %v; want only %s", found, err, stale)
+	}
+
+	if calls := server.Calls(ssmtest.OpDeregisterManagedInstance); calls != 0 {
+		t.Fatalf("dry run deregistered %d instances", calls)
+	}
+
+	_, err = ssmagent.Reap(t.Context(), server.Client(), now.Add(-time.Hour), false)
+	if err != nil {
+		t.Fatalf("Reap: %v", err)
+	}
+
+	for _, id := range []string{recent, online, foreign} {
+		if _, ok := server.Instance(id); !ok {
+			t.Errorf("instance %s was deregistered", id)
+		}
+	}
+
+	if _, ok := server.Instance(stale); ok || server.Calls(ssmtest.OpDeregisterManagedInstance) != 1 {
+		t.Errorf("stale instance %s still registered, or others deregistered with it", stale)
+	}
+}
@@ -6,32 +6,51 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
 )
 
 var errMissingActivation = errors.New("activation credentials not provided")
 
-// Register invokes the amazon-ssm-agent binary to register with Systems Manager.
-func Register(ctx context.Context, agentPath, region, activationID, activationCode string) error {
+// CommandFunc builds the command that runs name with args; exec.CommandContext satisfies it.
+type CommandFunc func(ctx context.Context, name string, args ...string) *exec.Cmd
+
+// Register invokes the amazon-ssm-agent binary, built by command, to register with Systems
+// Manager. It runs with env as its environment, or the inherited one when env is nil.
+func Register(
+	ctx context.Context,
+	command CommandFunc,
+	agentPath, region, activationID, activationCode string,
+	env []string,
+) error {
 	if activationID == "" || activationCode == "" {
 		return errMissingActivation
 	}
 
-	cmd := exec.CommandContext(
-		ctx,
-		agentPath,
-		"-register",
-		"-code", activationCode,
-		"-id", activationID,
-		"-region", region,
-	)
+	args := RegistrationArgs(region, activationID, activationCode)
+
+	cmd := command(ctx, agentPath, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
+	cmd.Env = env
 
 	runErr := cmd.Run()
+	metrics.ObserveRegistration(runErr)
+
 	if runErr != nil {
 		return fmt.Errorf("run amazon-ssm-agent registration: %w", runErr)
 	}
 
 	return nil
 }
+
+// RegistrationArgs returns the amazon-ssm-agent arguments that register with an activation.
+func RegistrationArgs(region, activationID, activationCode string) []string {
+	return []string{
+		"-register",
+		"-code", activationCode,
+		"-id", activationID,
+		"-region", region,
+	}
+}
@@ -0,0 +1,58 @@
+package ssmagent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// sessionWorkerName is the process name of the per-session worker spawned by the agent.
+	sessionWorkerName = "ssm-session-worker"
+	// commLength is how much of a process name the kernel keeps in /proc/<pid>/comm.
+	commLength = 15
+)
+
+// CountSessionWorkers returns the number of running session worker processes visible under procRoot.
+// The kernel truncates comm, so it is compared with the truncated worker name.
+func CountSessionWorkers(procRoot string) int {
+	want := sessionWorkerName[:commLength]
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !isPID(entry.Name()) {
+			continue
+		}
+
+		comm, readErr := os.ReadFile(filepath.Join(procRoot, entry.Name(), "comm")) // #nosec G304 -- procfs path
+		if readErr != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(comm)) == want {
+			count++
+		}
+	}
+
+	return count
+}
+
+func isPID(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
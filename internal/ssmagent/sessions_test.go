@@ -0,0 +1,39 @@
+package ssmagent_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmagent"
+)
+
+func TestCountSessionWorkers(t *testing.T) {
+	t.Parallel()
+
+	procRoot := t.TempDir()
+
+	for pid, comm := range map[string]string{
+		"10":   "ssm-session-wor\n", // the kernel's truncation of ssm-session-worker
+		"11":   "ssm-session-wor\n",
+		"12":   "amazon-ssm-agen\n",
+		"self": "ssm-session-wor\n",
+	} {
+		dir := filepath.Join(procRoot, pid)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := ssmagent.CountSessionWorkers(procRoot); got != 2 {
+		t.Errorf("CountSessionWorkers = %d, want 2", got)
+	}
+
+	if got := ssmagent.CountSessionWorkers(filepath.Join(procRoot, "missing")); got != 0 {
+		t.Errorf("CountSessionWorkers on a missing root = %d, want 0", got)
+	}
+}
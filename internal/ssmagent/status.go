@@ -0,0 +1,54 @@
+package ssmagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/benwsapp/aws-ssm-minimal/internal/awserr"
+	"github.com/benwsapp/aws-ssm-minimal/internal/metrics"
+)
+
+var errInstanceNotFound = errors.New("managed instance not found")
+
+// ErrDescribeDenied marks a DescribeInstanceInformation call refused for lack of permission,
+// which retrying cannot fix.
+var ErrDescribeDenied = errors.New("ssm:DescribeInstanceInformation denied")
+
+// DescribeAPI is the SSM operation PingStatus needs; *ssm.Client satisfies it.
+type DescribeAPI interface {
+	DescribeInstanceInformation(ctx context.Context, params *ssm.DescribeInstanceInformationInput,
+		optFns ...func(*ssm.Options)) (*ssm.DescribeInstanceInformationOutput, error)
+}
+
+// PingStatus returns the SSM ping status reported for the managed instance. Authorization
+// failures are marked with ErrDescribeDenied.
+func PingStatus(ctx context.Context, client DescribeAPI, instanceID string) (string, error) {
+	input := &ssm.DescribeInstanceInformationInput{
+		Filters: []types.InstanceInformationStringFilter{
+			{Key: aws.String("InstanceIds"), Values: []string{instanceID}},
+		},
+	}
+
+	output, err := client.DescribeInstanceInformation(ctx, input)
+	metrics.ObserveAWSCall("DescribeInstanceInformation", err)
+
+	if err != nil {
+		if awserr.IsAccessDenied(err) {
+			return "", fmt.Errorf("%w: %w", ErrDescribeDenied, err)
+		}
+
+		return "", fmt.Errorf("describe instance %s: %w", instanceID, err)
+	}
+
+	for _, info := range output.InstanceInformationList {
+		if aws.ToString(info.InstanceId) == instanceID {
+			return string(info.PingStatus), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", errInstanceNotFound, instanceID)
+}
@@ -0,0 +1,243 @@
+package ssmtest
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+type tag struct {
+	Key   string `json:"Key"`   //nolint:tagliatelle // SSM API casing
+	Value string `json:"Value"` //nolint:tagliatelle // SSM API casing
+}
+
+//nolint:tagliatelle // SSM API casing
+type createActivationInput struct {
+	IamRole             string   `json:"IamRole"`
+	Description         string   `json:"Description"`
+	DefaultInstanceName string   `json:"DefaultInstanceName"`
+	RegistrationLimit   int      `json:"RegistrationLimit"`
+	ExpirationDate      *float64 `json:"ExpirationDate"`
+	Tags                []tag    `json:"Tags"`
+}
+
+func (s *Server) createActivation(body []byte) (any, *apiError) {
+	var input createActivationInput
+	if err := decode(body, &input); err != nil {
+		return nil, err
+	}
+
+	if input.IamRole == "" {
+		return nil, newError("ValidationException", "IamRole is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiration := s.now().Add(defaultValidity)
+	if input.ExpirationDate != nil {
+		expiration = time.Unix(int64(*input.ExpirationDate), 0)
+	}
+
+	limit := input.RegistrationLimit
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	tags := make(map[string]string, len(input.Tags))
+	for _, t := range input.Tags {
+		tags[t.Key] = t.Value
+	}
+
+	activation := &Activation{
+		ID:                  newActivationID(),
+		Code:                randomHex(codeBytes),
+		IamRole:             input.IamRole,
+		Description:         input.Description,
+		DefaultInstanceName: input.DefaultInstanceName,
+		RegistrationLimit:   limit,
+		RegistrationsCount:  0,
+		Expiration:          expiration,
+		Tags:                tags,
+	}
+	s.activations[activation.ID] = activation
+
+	return map[string]string{"ActivationId": activation.ID, "ActivationCode": activation.Code}, nil
+}
+
+//nolint:tagliatelle // SSM API casing
+type deleteActivationInput struct {
+	ActivationID string `json:"ActivationId"`
+}
+
+func (s *Server) deleteActivation(body []byte) (any, *apiError) {
+	var input deleteActivationInput
+	if err := decode(body, &input); err != nil {
+		return nil, err
+	}
+
+	if input.ActivationID == "" {
+		return nil, newError(CodeInvalidActivationID, "activation ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.activations[input.ActivationID]; !ok {
+		return nil, newError(CodeInvalidActivation, "activation %s does not exist", input.ActivationID)
+	}
+
+	delete(s.activations, input.ActivationID)
+
+	return struct{}{}, nil
+}
+
+//nolint:tagliatelle // SSM API casing
+type deregisterInput struct {
+	InstanceID string `json:"InstanceId"`
+}
+
+func (s *Server) deregisterManagedInstance(body []byte) (any, *apiError) {
+	var input deregisterInput
+	if err := decode(body, &input); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.instances[input.InstanceID]; !ok {
+		return nil, newError(CodeInvalidInstanceID, "instance %s is not registered", input.InstanceID)
+	}
+
+	delete(s.instances, input.InstanceID)
+
+	return struct{}{}, nil
+}
+
+//nolint:tagliatelle // SSM API casing
+type describeInput struct {
+	Filters []struct {
+		Key    string   `json:"Key"`
+		Values []string `json:"Values"`
+	} `json:"Filters"`
+}
+
+//nolint:tagliatelle // SSM API casing
+type instanceInformation struct {
+	InstanceID       string  `json:"InstanceId"`
+	ActivationID     string  `json:"ActivationId"`
+	IamRole          string  `json:"IamRole"`
+	PingStatus       string  `json:"PingStatus"`
+	LastPingDateTime float64 `json:"LastPingDateTime"`
+	ResourceType     string  `json:"ResourceType"`
+	RegistrationDate float64 `json:"RegistrationDate"`
+}
+
+func (s *Server) describeInstanceInformation(body []byte) (any, *apiError) {
+	var input describeInput
+	if err := decode(body, &input); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []instanceInformation{}
+
+	for _, instance := range s.instances {
+		if !matchesFilters(instance, input) {
+			continue
+		}
+
+		list = append(list, instanceInformation{
+			InstanceID:       instance.ID,
+			ActivationID:     instance.ActivationID,
+			IamRole:          instance.IamRole,
+			PingStatus:       instance.PingStatus,
+			LastPingDateTime: float64(instance.LastPing.Unix()),
+			ResourceType:     "ManagedInstance",
+			RegistrationDate: float64(instance.Registered.Unix()),
+		})
+	}
+
+	slices.SortFunc(list, func(a, b instanceInformation) int { return strings.Compare(a.InstanceID, b.InstanceID) })
+
+	return map[string]any{"InstanceInformationList": list}, nil
+}
+
+// matchesFilters applies the InstanceIds, PingStatus and tag:<key> filters; others are ignored.
+func matchesFilters(instance *Instance, input describeInput) bool {
+	for _, filter := range input.Filters {
+		var value string
+
+		switch key, isTag := strings.CutPrefix(filter.Key, "tag:"); {
+		case isTag:
+			value = instance.Tags[key]
+		case filter.Key == "InstanceIds":
+			value = instance.ID
+		case filter.Key == "PingStatus":
+			value = instance.PingStatus
+		default:
+			continue
+		}
+
+		if !slices.Contains(filter.Values, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//nolint:tagliatelle // SSM API casing
+type registerInput struct {
+	ActivationID   string `json:"ActivationId"`
+	ActivationCode string `json:"ActivationCode"`
+}
+
+func (s *Server) registerManagedInstance(body []byte) (any, *apiError) {
+	var input registerInput
+	if err := decode(body, &input); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activation, ok := s.activations[input.ActivationID]
+	if !ok || activation.Code != input.ActivationCode {
+		return nil, newError(CodeInvalidActivation, "activation %s or its code is invalid", input.ActivationID)
+	}
+
+	if !s.now().Before(activation.Expiration) {
+		return nil, newError(CodeInvalidActivation, "activation %s has expired", input.ActivationID)
+	}
+
+	if activation.RegistrationsCount >= activation.RegistrationLimit {
+		return nil, newError(CodeInvalidActivation, "activation %s reached its registration limit", input.ActivationID)
+	}
+
+	activation.RegistrationsCount++
+
+	instance := &Instance{
+		ID:           "mi-" + randomHex(idBytes) + "0",
+		ActivationID: activation.ID,
+		IamRole:      activation.IamRole,
+		PingStatus:   PingStatusOnline,
+		Registered:   s.now(),
+		LastPing:     s.now(),
+		Tags:         maps.Clone(activation.Tags),
+	}
+	s.instances[instance.ID] = instance
+
+	return map[string]string{"InstanceId": instance.ID}, nil
+}
+
+// newActivationID returns an ID in the UUID form SSM uses.
+func newActivationID() string {
+	id := randomHex(16)
+
+	return id[:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:]
+}
@@ -0,0 +1,414 @@
+// Package ssmtest provides an in-memory fake of the SSM API for tests.
+//
+// The fake speaks the awsJson1_1 protocol the SDK and the agent use, so a real *ssm.Client or
+// amazon-ssm-agent pointed at Server.URL exercises the same serialization as production.
+package ssmtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Operations implemented by the fake, as named in the X-Amz-Target header.
+const (
+	OpCreateActivation            = "CreateActivation"
+	OpDeleteActivation            = "DeleteActivation"
+	OpDeregisterManagedInstance   = "DeregisterManagedInstance"
+	OpDescribeInstanceInformation = "DescribeInstanceInformation"
+	OpRegisterManagedInstance     = "RegisterManagedInstance"
+)
+
+// Error codes returned by the fake, matching the SSM API's modeled exceptions.
+const (
+	CodeAccessDenied        = "AccessDeniedException"
+	CodeInvalidActivation   = "InvalidActivation"
+	CodeInvalidActivationID = "InvalidActivationId"
+	CodeInvalidInstanceID   = "InvalidInstanceId"
+	CodeInternalServerError = "InternalServerError"
+	CodeThrottling          = "ThrottlingException"
+	CodeUnknownOperation    = "UnknownOperationException"
+	CodeSerialization       = "SerializationException"
+
+	// PingStatusOnline is the ping status reported for newly registered instances.
+	PingStatusOnline = "Online"
+
+	// Region is the region the client returned by Client is configured for.
+	Region = "us-east-1"
+
+	targetPrefix    = "AmazonSSM."
+	contentType     = "application/x-amz-json-1.1"
+	idBytes         = 8
+	codeBytes       = 10
+	defaultLimit    = 1
+	defaultValidity = 24 * time.Hour
+)
+
+// Fault makes matching requests fail or stall instead of being handled.
+type Fault struct {
+	// Status is the HTTP status; 400 when zero.
+	Status int
+	// Code is the error type, such as CodeThrottling. A fault with only Delay set is not an error.
+	Code    string
+	Message string
+	// Delay is waited before responding, or until the request is cancelled.
+	Delay time.Duration
+	// Times limits how many requests the fault applies to; zero means every request.
+	Times int
+}
+
+// Activation is the fake's record of an activation.
+type Activation struct {
+	ID                  string
+	Code                string
+	IamRole             string
+	Description         string
+	DefaultInstanceName string
+	RegistrationLimit   int
+	RegistrationsCount  int
+	Expiration          time.Time
+	Tags                map[string]string
+}
+
+// Instance is the fake's record of a registered managed instance.
+type Instance struct {
+	ID           string
+	ActivationID string
+	IamRole      string
+	PingStatus   string
+	Registered   time.Time
+	// LastPing is when the instance was last Online.
+	LastPing time.Time
+	// Tags are inherited from the activation the instance registered with.
+	Tags map[string]string
+}
+
+// Server is a fake SSM endpoint. The zero value is not usable; call NewServer.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	activations map[string]*Activation
+	instances   map[string]*Instance
+	faults      map[string]*Fault
+	calls       map[string]int
+	now         func() time.Time
+}
+
+// NewServer starts a fake SSM server. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		Server:      nil,
+		mu:          sync.Mutex{},
+		activations: map[string]*Activation{},
+		instances:   map[string]*Instance{},
+		faults:      map[string]*Fault{},
+		calls:       map[string]int{},
+		now:         time.Now,
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Client returns an SSM client for the fake with static credentials and retries disabled, so each
+// injected fault is seen by exactly one call. optFns are applied last.
+func (s *Server) Client(optFns ...func(*ssm.Options)) *ssm.Client {
+	opts := ssm.Options{
+		Region:       Region,
+		BaseEndpoint: aws.String(s.URL),
+		Credentials: aws.CredentialsProviderFunc(func(_ context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDTEST", SecretAccessKey: "secret", Source: "ssmtest"}, nil
+		}),
+		Retryer:    aws.NopRetryer{},
+		HTTPClient: s.Server.Client(),
+	}
+
+	return ssm.New(opts, optFns...)
+}
+
+// Inject makes requests for operation fail or stall as described by fault, replacing any fault
+// already set for it.
+func (s *Server) Inject(operation string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[operation] = &fault
+}
+
+// Clear removes the fault for operation.
+func (s *Server) Clear(operation string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.faults, operation)
+}
+
+// Calls returns how many requests for operation were received, including failed ones.
+func (s *Server) Calls(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[operation]
+}
+
+// Activation returns a copy of the activation with id.
+func (s *Server) Activation(id string) (Activation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activation, ok := s.activations[id]
+	if !ok {
+		return Activation{}, false
+	}
+
+	return *activation, true
+}
+
+// Activations returns how many activations exist.
+func (s *Server) Activations() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.activations)
+}
+
+// Instance returns a copy of the managed instance with id.
+func (s *Server) Instance(id string) (Instance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instance, ok := s.instances[id]
+	if !ok {
+		return Instance{}, false
+	}
+
+	return *instance, true
+}
+
+// Instances returns how many managed instances are registered.
+func (s *Server) Instances() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.instances)
+}
+
+// SetPingStatus changes the ping status DescribeInstanceInformation reports for an instance. An
+// Online status also counts as a ping.
+func (s *Server) SetPingStatus(instanceID, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if instance, ok := s.instances[instanceID]; ok {
+		instance.PingStatus = status
+
+		if status == PingStatusOnline {
+			instance.LastPing = s.now()
+		}
+	}
+}
+
+// SetNow replaces the clock used for expirations and registration dates.
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.now = now
+}
+
+// apiError is an SSM error response.
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newError(code, format string, args ...any) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	operation, ok := strings.CutPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+	if r.Method != http.MethodPost || !ok {
+		writeError(w, newError(CodeUnknownOperation, "unsupported request %s %s", r.Method, r.URL.Path))
+
+		return
+	}
+
+	// The body is read first so the server notices a client that goes away during a delay.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, newError(CodeSerialization, "read body: %v", err))
+
+		return
+	}
+
+	fault := s.takeFault(operation)
+	if fault != nil && fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fault != nil && fault.Code != "" {
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+
+		writeError(w, &apiError{status: status, code: fault.Code, message: fault.Message})
+
+		return
+	}
+
+	output, apiErr := s.dispatch(operation, body)
+	if apiErr != nil {
+		writeError(w, apiErr)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_ = json.NewEncoder(w).Encode(output)
+}
+
+// takeFault counts the call and returns the fault to apply to it, if any.
+func (s *Server) takeFault(operation string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[operation]++
+
+	fault, ok := s.faults[operation]
+	if !ok {
+		return nil
+	}
+
+	applied := *fault
+
+	if fault.Times > 0 {
+		fault.Times--
+		if fault.Times == 0 {
+			delete(s.faults, operation)
+		}
+	}
+
+	return &applied
+}
+
+func (s *Server) dispatch(operation string, body []byte) (any, *apiError) {
+	handlers := map[string]func([]byte) (any, *apiError){
+		OpCreateActivation:            s.createActivation,
+		OpDeleteActivation:            s.deleteActivation,
+		OpDeregisterManagedInstance:   s.deregisterManagedInstance,
+		OpDescribeInstanceInformation: s.describeInstanceInformation,
+		OpRegisterManagedInstance:     s.registerManagedInstance,
+	}
+
+	handler, ok := handlers[operation]
+	if !ok {
+		return nil, newError(CodeUnknownOperation, "operation %s is not implemented by ssmtest", operation)
+	}
+
+	return handler(body)
+}
+
+func decode(body []byte, v any) *apiError {
+	err := json.Unmarshal(body, v)
+	if err != nil {
+		return newError(CodeSerialization, "decode request: %v", err)
+	}
+
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Amzn-ErrorType", err.code)
+	w.WriteHeader(err.status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": err.code, "message": err.message})
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
+
+// Register registers a managed instance the way amazon-ssm-agent -register does, by sending
+// RegisterManagedInstance to the fake, and returns the new instance ID.
+func (s *Server) Register(ctx context.Context, activationID, activationCode string) (string, error) {
+	return Register(ctx, s.Server.Client(), s.URL, activationID, activationCode)
+}
+
+// Register sends RegisterManagedInstance to the fake at endpoint with client. It lets a stub
+// agent running in another process register with a Server.
+func Register(ctx context.Context, client *http.Client, endpoint, activationID, activationCode string) (string, error) {
+	body, err := json.Marshal(registerInput{ActivationID: activationID, ActivationCode: activationCode})
+	if err != nil {
+		return "", fmt.Errorf("encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Target", targetPrefix+OpRegisterManagedInstance)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var output struct {
+		InstanceID string `json:"InstanceId"` //nolint:tagliatelle // SSM API casing
+		Type       string `json:"__type"`     //nolint:tagliatelle // SSM API casing
+		Message    string `json:"message"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&output)
+	if err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &apiError{status: resp.StatusCode, code: output.Type, message: output.Message}
+	}
+
+	return output.InstanceID, nil
+}
+
+// ErrorCode returns the SSM error code of an error returned by Register, or "" for other errors.
+func ErrorCode(err error) string {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.code
+	}
+
+	return ""
+}
@@ -0,0 +1,208 @@
+package ssmtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/benwsapp/aws-ssm-minimal/internal/ssmtest"
+)
+
+func createActivation(t *testing.T, client *ssm.Client, input *ssm.CreateActivationInput) *ssm.CreateActivationOutput {
+	t.Helper()
+
+	output, err := client.CreateActivation(t.Context(), input)
+	if err != nil {
+		t.Fatalf("CreateActivation: %v", err)
+	}
+
+	return output
+}
+
+func TestActivationLifecycle(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	created := createActivation(t, client, &ssm.CreateActivationInput{
+		IamRole: aws.String("role"),
+		Tags:    []types.Tag{{Key: aws.String("k"), Value: aws.String("v")}},
+	})
+
+	activation, ok := server.Activation(aws.ToString(created.ActivationId))
+	if !ok {
+		t.Fatal("activation was not recorded")
+	}
+
+	if activation.Code != aws.ToString(created.ActivationCode) || activation.RegistrationLimit != 1 ||
+		activation.Tags["k"] != "v" {
+		t.Fatalf("unexpected activation %+v", activation)
+	}
+
+	instanceID, err := server.Register(t.Context(), activation.ID, activation.Code)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	described, err := client.DescribeInstanceInformation(t.Context(), &ssm.DescribeInstanceInformationInput{
+		Filters: []types.InstanceInformationStringFilter{{Key: aws.String("InstanceIds"), Values: []string{instanceID}}},
+	})
+	if err != nil {
+		t.Fatalf("DescribeInstanceInformation: %v", err)
+	}
+
+	if len(described.InstanceInformationList) != 1 ||
+		described.InstanceInformationList[0].PingStatus != types.PingStatusOnline {
+		t.Fatalf("unexpected instances %+v", described.InstanceInformationList)
+	}
+
+	_, err = client.DeleteActivation(t.Context(), &ssm.DeleteActivationInput{ActivationId: created.ActivationId})
+	if err != nil {
+		t.Fatalf("DeleteActivation: %v", err)
+	}
+
+	_, err = client.DeregisterManagedInstance(t.Context(),
+		&ssm.DeregisterManagedInstanceInput{InstanceId: aws.String(instanceID)})
+	if err != nil {
+		t.Fatalf("DeregisterManagedInstance: %v", err)
+	}
+
+	if server.Activations() != 0 || server.Instances() != 0 {
+		t.Fatalf("state left behind: %d activations, %d instances", server.Activations(), server.Instances())
+	}
+}
+
+func TestRegisterRejectsInvalidActivations(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		code  func(string) string
+		setup func(*ssmtest.Server, string, string)
+	}{
+		{name: "wrong code", code: func(string) string { return "wrong" }, setup: nil},
+		{
+			name: "expired",
+			code: func(code string) string { return code },
+			setup: func(server *ssmtest.Server, _, _ string) {
+				server.SetNow(func() time.Time { return now.Add(25 * time.Hour) })
+			},
+		},
+		{
+			name: "limit reached",
+			code: func(code string) string { return code },
+			setup: func(server *ssmtest.Server, id, code string) {
+				_, err := server.Register(context.Background(), id, code)
+				if err != nil {
+					panic(err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := ssmtest.NewServer()
+			defer server.Close()
+
+			server.SetNow(func() time.Time { return now })
+
+			created := createActivation(t, server.Client(), &ssm.CreateActivationInput{IamRole: aws.String("role")})
+			id, code := aws.ToString(created.ActivationId), aws.ToString(created.ActivationCode)
+
+			if test.setup != nil {
+				test.setup(server, id, code)
+			}
+
+			_, err := server.Register(t.Context(), id, test.code(code))
+			if got := ssmtest.ErrorCode(err); got != ssmtest.CodeInvalidActivation {
+				t.Fatalf("got error %v (code %q), want %s", err, got, ssmtest.CodeInvalidActivation)
+			}
+		})
+	}
+}
+
+func TestUnknownResources(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	_, err := client.DeleteActivation(t.Context(), &ssm.DeleteActivationInput{ActivationId: aws.String("missing")})
+
+	var invalidActivation *types.InvalidActivation
+	if !errors.As(err, &invalidActivation) {
+		t.Fatalf("DeleteActivation error = %v, want InvalidActivation", err)
+	}
+
+	_, err = client.DeregisterManagedInstance(t.Context(),
+		&ssm.DeregisterManagedInstanceInput{InstanceId: aws.String("mi-0123456789abcdef0")})
+
+	var invalidInstance *types.InvalidInstanceId
+	if !errors.As(err, &invalidInstance) {
+		t.Fatalf("DeregisterManagedInstance error = %v, want InvalidInstanceId", err)
+	}
+}
+
+func TestInjectFault(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	input := &ssm.CreateActivationInput{IamRole: aws.String("role")}
+
+	server.Inject(ssmtest.OpCreateActivation, ssmtest.Fault{
+		Status:  http.StatusBadRequest,
+		Code:    ssmtest.CodeThrottling,
+		Message: "slow down",
+		Delay:   0,
+		Times:   1,
+	})
+
+	_, err := client.CreateActivation(t.Context(), input)
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != ssmtest.CodeThrottling {
+		t.Fatalf("first call error = %v, want %s", err, ssmtest.CodeThrottling)
+	}
+
+	createActivation(t, client, input)
+
+	if calls := server.Calls(ssmtest.OpCreateActivation); calls != 2 {
+		t.Fatalf("calls = %d, want 2", calls)
+	}
+}
+
+func TestInjectDelayHonorsCancellation(t *testing.T) {
+	t.Parallel()
+
+	server := ssmtest.NewServer()
+	defer server.Close()
+
+	server.Inject(ssmtest.OpDescribeInstanceInformation, ssmtest.Fault{Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := server.Client().DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want deadline exceeded", err)
+	}
+}
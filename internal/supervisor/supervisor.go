@@ -4,12 +4,15 @@ package supervisor
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clock"
+	"github.com/benwsapp/aws-ssm-minimal/internal/logging"
 )
 
 const (
@@ -21,38 +24,151 @@ const (
 type Result struct {
 	ExitCode   int
 	TTLExpired bool
+	// Stopped reports that Stop asked the child to exit; ExitCode is what it exited with.
+	Stopped bool
+	// Unhealthy holds the health signal that caused the child to be stopped, if any.
+	Unhealthy *HealthSignal
+}
+
+// HealthSignal reports a condition in the child's output after which it can no longer serve sessions.
+type HealthSignal struct {
+	Kind    string
+	Message string
 }
 
 // Supervisor coordinates TTL enforcement and signal forwarding for a process.
 type Supervisor struct {
 	cmd           *exec.Cmd
-	ttlTimer      *time.Timer
+	clock         clock.Clock
+	ttlTimer      clock.Timer
 	ttlDuration   time.Duration
+	deadline      time.Time
 	shutdownGrace time.Duration
 	done          chan error
 	sigs          chan os.Signal
-	graceTimer    *time.Timer
+	signals       <-chan os.Signal
+	graceTimer    clock.Timer
 	ttlExpired    bool
+	unhealthy     *HealthSignal
+	onStart       func(pid int)
+	health        <-chan HealthSignal
+	preShutdown   func(reason string)
+	stopping      bool
+	restarts      <-chan struct{}
+	newCmd        func() *exec.Cmd
+	restarting    bool
+	stops         chan string
+	stopped       bool
+}
+
+// Option customizes a Supervisor.
+type Option func(*Supervisor)
+
+// WithStartHook registers a callback invoked with the child PID once it has started.
+func WithStartHook(hook func(pid int)) Option {
+	return func(s *Supervisor) {
+		s.onStart = hook
+	}
+}
+
+// WithHealthSignals stops the child gracefully when a signal arrives on signals.
+func WithHealthSignals(signals <-chan HealthSignal) Option {
+	return func(s *Supervisor) {
+		s.health = signals
+	}
+}
+
+// WithPreShutdownHook registers a callback run once, right after the child is first asked to stop.
+// It runs on the supervisor's event loop, so slow work belongs in a goroutine of its own.
+func WithPreShutdownHook(hook func(reason string)) Option {
+	return func(s *Supervisor) {
+		s.preShutdown = hook
+	}
+}
+
+// WithRestarts restarts the child with a fresh command from newCmd whenever a request arrives on
+// requests. The TTL keeps running across restarts.
+func WithRestarts(requests <-chan struct{}, newCmd func() *exec.Cmd) Option {
+	return func(s *Supervisor) {
+		s.restarts = requests
+		s.newCmd = newCmd
+	}
+}
+
+// WithSignals forwards signals received on signals instead of those sent to the wrapper process.
+func WithSignals(signals <-chan os.Signal) Option {
+	return func(s *Supervisor) {
+		s.signals = signals
+	}
+}
+
+// WithDeadline ends the child at an absolute time instead of after the TTL. The timer is armed
+// from the time remaining when the Supervisor is constructed.
+func WithDeadline(deadline time.Time) Option {
+	return func(s *Supervisor) {
+		s.deadline = deadline
+	}
+}
+
+// WithClock replaces the real clock used for the TTL and grace timers.
+func WithClock(c clock.Clock) Option {
+	return func(s *Supervisor) {
+		s.clock = c
+	}
 }
 
 // Run starts the given command and enforces TTL and graceful shutdown behavior.
-func Run(cmd *exec.Cmd, ttl, shutdownGrace time.Duration) (Result, error) {
-	s := NewSupervisor(cmd, ttl, shutdownGrace)
+func Run(cmd *exec.Cmd, ttl, shutdownGrace time.Duration, opts ...Option) (Result, error) {
+	s := NewSupervisor(cmd, ttl, shutdownGrace, opts...)
 
 	return s.Run()
 }
 
 // NewSupervisor constructs a Supervisor instance.
-func NewSupervisor(cmd *exec.Cmd, ttl, shutdownGrace time.Duration) *Supervisor {
-	return &Supervisor{
+func NewSupervisor(cmd *exec.Cmd, ttl, shutdownGrace time.Duration, opts ...Option) *Supervisor {
+	s := &Supervisor{
 		cmd:           cmd,
-		ttlTimer:      time.NewTimer(ttl),
+		clock:         clock.Real(),
+		ttlTimer:      nil,
 		ttlDuration:   ttl,
+		deadline:      time.Time{},
 		shutdownGrace: shutdownGrace,
 		done:          make(chan error, 1),
 		sigs:          make(chan os.Signal, defaultSignalBuffer),
+		signals:       nil,
 		graceTimer:    nil,
 		ttlExpired:    false,
+		unhealthy:     nil,
+		onStart:       nil,
+		health:        nil,
+		preShutdown:   nil,
+		stopping:      false,
+		restarts:      nil,
+		newCmd:        nil,
+		restarting:    false,
+		stops:         make(chan string, 1),
+		stopped:       false,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if !s.deadline.IsZero() {
+		s.ttlDuration = s.deadline.Sub(s.clock.Now())
+	}
+
+	s.ttlTimer = s.clock.NewTimer(s.ttlDuration)
+
+	return s
+}
+
+// Stop asks the child to exit as the TTL does, with SIGTERM and then SIGKILL after the grace
+// period. It is safe to call from any goroutine, before or during Run; later calls are ignored.
+func (s *Supervisor) Stop(reason string) {
+	select {
+	case s.stops <- reason:
+	default:
 	}
 }
 
@@ -69,19 +185,39 @@ func (s *Supervisor) Run() (Result, error) {
 }
 
 func (s *Supervisor) start() error {
+	startErr := s.startChild()
+	if startErr != nil {
+		return startErr
+	}
+
+	if s.signals == nil {
+		signal.Notify(s.sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+		s.signals = s.sigs
+	}
+
+	return nil
+}
+
+func (s *Supervisor) startChild() error {
 	startErr := s.cmd.Start()
 	if startErr != nil {
 		return fmt.Errorf("start child process: %w", startErr)
 	}
 
-	log.Printf("started child process pid=%d ttl=%s", s.cmd.Process.Pid, s.ttlDuration)
+	slog.Info("started child process",
+		slog.Int("pid", s.cmd.Process.Pid),
+		slog.Duration("ttl", s.ttlDuration))
+
+	if s.onStart != nil {
+		s.onStart(s.cmd.Process.Pid)
+	}
+
+	cmd := s.cmd
 
 	go func() {
-		s.done <- s.cmd.Wait()
+		s.done <- cmd.Wait()
 	}()
 
-	signal.Notify(s.sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
-
 	return nil
 }
 
@@ -89,11 +225,26 @@ func (s *Supervisor) eventLoop() (Result, error) {
 	for {
 		select {
 		case err := <-s.done:
+			if s.restarting && !s.stopping && !s.shuttingDown() {
+				restartErr := s.restartChild(err)
+				if restartErr != nil {
+					return Result{}, restartErr
+				}
+
+				continue
+			}
+
 			return s.handleProcessExit(err)
-		case sig := <-s.sigs:
+		case <-s.restarts:
+			s.handleRestartRequest()
+		case sig := <-s.signals:
 			s.forwardSignal(sig)
-		case <-s.ttlTimer.C:
+		case <-s.ttlTimer.C():
 			s.handleTTLExpiry()
+		case reason := <-s.stops:
+			s.handleStop(reason)
+		case signal := <-s.health:
+			s.handleHealthSignal(signal)
 		}
 	}
 }
@@ -102,58 +253,143 @@ func (s *Supervisor) handleProcessExit(err error) (Result, error) {
 	s.stopTimer(s.graceTimer)
 
 	if s.ttlExpired {
-		log.Printf("child exited after ttl expiry")
+		slog.Info("child exited after ttl expiry")
 
-		return Result{ExitCode: 0, TTLExpired: true}, nil
+		return Result{ExitCode: 0, TTLExpired: true, Stopped: false, Unhealthy: nil}, nil
 	}
 
 	exitCode, exitErr := exitCodeFromError(err)
 
-	return Result{ExitCode: exitCode, TTLExpired: false}, exitErr
+	return Result{ExitCode: exitCode, TTLExpired: false, Stopped: s.stopped, Unhealthy: s.unhealthy}, exitErr
 }
 
 func (s *Supervisor) forwardSignal(sig os.Signal) {
-	log.Printf("forwarding signal %s to child", sig)
+	slog.Info("forwarding signal to child", slog.String("signal", sig.String()))
 
-	if s.cmd.Process == nil {
-		return
+	if s.cmd.Process != nil {
+		signalErr := s.cmd.Process.Signal(sig)
+		if signalErr != nil {
+			slog.Warn("failed to forward signal", slog.String("signal", sig.String()), logging.Err(signalErr))
+		}
 	}
 
-	signalErr := s.cmd.Process.Signal(sig)
-	if signalErr != nil {
-		log.Printf("warning: failed to forward signal %s: %v", sig, signalErr)
+	if sig != syscall.SIGHUP {
+		s.afterStopRequested("signal " + sig.String())
+	}
+}
+
+func (s *Supervisor) handleRestartRequest() {
+	if s.newCmd == nil || s.restarting || s.stopping {
+		return
 	}
+
+	s.restarting = true
+	slog.Info("restarting child; sending SIGTERM before SIGKILL", slog.Duration("grace", s.shutdownGrace))
+	s.signalChild(syscall.SIGTERM)
+	s.scheduleKill()
+}
+
+// restartChild starts a replacement child once the previous one has exited.
+func (s *Supervisor) restartChild(exitErr error) error {
+	s.stopTimer(s.graceTimer)
+	s.restarting = false
+
+	code, _ := exitCodeFromError(exitErr)
+	slog.Info("child exited for restart", slog.Int("exitCode", code))
+
+	s.cmd = s.newCmd()
+
+	return s.startChild()
 }
 
 func (s *Supervisor) handleTTLExpiry() {
+	if s.shuttingDown() {
+		return
+	}
+
 	s.ttlExpired = true
-	log.Printf("ttl expired; sending SIGTERM and waiting %s before SIGKILL", s.shutdownGrace)
+	slog.Info("ttl expired; sending SIGTERM before SIGKILL", slog.Duration("grace", s.shutdownGrace))
 	s.signalChild(syscall.SIGTERM)
 	s.scheduleKill()
+	s.afterStopRequested("ttl expired")
+}
+
+func (s *Supervisor) handleStop(reason string) {
+	if s.shuttingDown() {
+		return
+	}
+
+	s.stopped = true
+	slog.Info("stop requested; sending SIGTERM before SIGKILL",
+		slog.String("reason", reason),
+		slog.Duration("grace", s.shutdownGrace))
+	s.signalChild(syscall.SIGTERM)
+	s.scheduleKill()
+	s.afterStopRequested(reason)
+}
+
+func (s *Supervisor) handleHealthSignal(signal HealthSignal) {
+	if s.shuttingDown() {
+		return
+	}
+
+	s.unhealthy = &signal
+	slog.Warn("child reported fatal condition; sending SIGTERM before SIGKILL",
+		slog.String("signal", signal.Kind),
+		slog.String("detail", signal.Message),
+		slog.Duration("grace", s.shutdownGrace))
+	s.signalChild(syscall.SIGTERM)
+	s.scheduleKill()
+	s.afterStopRequested("agent unhealthy: " + signal.Kind)
+}
+
+// afterStopRequested runs the pre-shutdown hook the first time the child has been asked to stop.
+func (s *Supervisor) afterStopRequested(reason string) {
+	if s.stopping {
+		return
+	}
+
+	s.stopping = true
+
+	if s.preShutdown != nil {
+		s.preShutdown(reason)
+	}
+}
+
+func (s *Supervisor) shuttingDown() bool {
+	return s.ttlExpired || s.unhealthy != nil || s.stopped
 }
 
 func (s *Supervisor) scheduleKill() {
 	if s.shutdownGrace <= 0 {
-		log.Printf("grace period is zero; sending SIGKILL immediately")
+		slog.Info("grace period is zero; sending SIGKILL immediately")
 		s.signalChild(syscall.SIGKILL)
 
 		return
 	}
 
-	s.graceTimer = time.AfterFunc(s.shutdownGrace, func() {
-		log.Printf("grace period elapsed; sending SIGKILL to child")
-		s.signalChild(syscall.SIGKILL)
+	// The timer fires on another goroutine, and a restart replaces s.cmd, so the callback kills
+	// the process that was running when the grace period began rather than reading s.cmd.
+	process := s.cmd.Process
+
+	s.graceTimer = s.clock.AfterFunc(s.shutdownGrace, func() {
+		slog.Warn("grace period elapsed; sending SIGKILL to child")
+		signalProcess(process, syscall.SIGKILL)
 	})
 }
 
 func (s *Supervisor) signalChild(sig os.Signal) {
-	if s.cmd.Process == nil {
+	signalProcess(s.cmd.Process, sig)
+}
+
+func signalProcess(process *os.Process, sig os.Signal) {
+	if process == nil {
 		return
 	}
 
-	signalErr := s.cmd.Process.Signal(sig)
+	signalErr := process.Signal(sig)
 	if signalErr != nil {
-		log.Printf("warning: failed to send %s to child: %v", sig, signalErr)
+		slog.Warn("failed to signal child", slog.String("signal", sig.String()), logging.Err(signalErr))
 	}
 }
 
@@ -163,14 +399,14 @@ func (s *Supervisor) cleanup() {
 	signal.Stop(s.sigs)
 }
 
-func (s *Supervisor) stopTimer(timer *time.Timer) {
+func (s *Supervisor) stopTimer(timer clock.Timer) {
 	if timer == nil {
 		return
 	}
 
 	if !timer.Stop() {
 		select {
-		case <-timer.C:
+		case <-timer.C():
 		default:
 		}
 	}
@@ -186,7 +422,7 @@ func exitCodeFromError(err error) (int, error) {
 		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
 			if status.Signaled() {
 				sig := status.Signal()
-				log.Printf("child terminated by signal %s", sig)
+				slog.Info("child terminated by signal", slog.String("signal", sig.String()))
 
 				return defaultKillOffset + int(sig), nil
 			}
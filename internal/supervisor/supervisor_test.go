@@ -0,0 +1,389 @@
+package supervisor_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/benwsapp/aws-ssm-minimal/internal/clocktest"
+	"github.com/benwsapp/aws-ssm-minimal/internal/supervisor"
+)
+
+// envChild selects how the test binary behaves when re-executed as the supervised child.
+const envChild = "SUPERVISOR_TEST_CHILD"
+
+const (
+	// childExitOnTerm exits 0 on the first SIGTERM.
+	childExitOnTerm = "exit-on-term"
+	// childIgnoreTerm survives SIGTERM and only stops on SIGKILL.
+	childIgnoreTerm = "ignore-term"
+
+	ttl   = time.Hour
+	grace = 10 * time.Second
+)
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(envChild); mode != "" {
+		runChild(mode)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runChild reports "ready" once it handles SIGTERM and "term" for every SIGTERM it receives.
+func runChild(mode string) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+
+	fmt.Println("ready") //nolint:forbidigo // protocol with the test
+
+	for range sigs {
+		fmt.Println("term") //nolint:forbidigo // protocol with the test
+
+		if mode == childExitOnTerm {
+			os.Exit(0)
+		}
+	}
+}
+
+// child is a running re-executed test binary and its output, one line per event.
+type child struct {
+	cmd   *exec.Cmd
+	lines chan string
+}
+
+func newChild(t *testing.T, mode string) child {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = reader.Close() })
+
+	cmd := exec.Command(os.Args[0]) // #nosec G204 -- re-executes the test binary
+	cmd.Env = append(os.Environ(), envChild+"="+mode)
+	cmd.Stdout = writer
+	cmd.Stderr = os.Stderr
+
+	lines := make(chan string, 8)
+
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+
+		close(lines)
+	}()
+
+	// The parent's copy of the write end is only needed until the child starts.
+	t.Cleanup(func() { _ = writer.Close() })
+
+	return child{cmd: cmd, lines: lines}
+}
+
+// expect waits for the child to print line.
+func (c child) expect(t *testing.T, line string) {
+	t.Helper()
+
+	select {
+	case got := <-c.lines:
+		if got != line {
+			t.Fatalf("child printed %q, want %q", got, line)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("child never printed %q", line)
+	}
+}
+
+type outcome struct {
+	result supervisor.Result
+	err    error
+}
+
+// step drives one moment of a scenario: it sends a signal, requests a stop or advances the fake
+// clock, optionally after waiting for the supervisor to have a given number of timers pending.
+type step struct {
+	// timers, when non-negative, is waited for before the step acts.
+	timers int
+	signal os.Signal
+	// stop, when set, is passed to Stop as the reason.
+	stop    string
+	advance time.Duration
+	// expect is a line the child must print after the step.
+	expect string
+}
+
+func TestSupervisor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		child    string
+		grace    time.Duration
+		steps    []step
+		want     supervisor.Result
+		reasons  []string
+		deadline bool
+	}{
+		{
+			name:  "ttl expiry with child exiting in the grace window",
+			child: childExitOnTerm,
+			grace: grace,
+			steps: []step{
+				{timers: 1, signal: nil, stop: "", advance: ttl, expect: "term"},
+			},
+			want:    supervisor.Result{ExitCode: 0, TTLExpired: true, Stopped: false, Unhealthy: nil},
+			reasons: []string{"ttl expired"},
+		},
+		{
+			name:  "ttl expiry with child ignoring SIGTERM is killed after the grace",
+			child: childIgnoreTerm,
+			grace: grace,
+			steps: []step{
+				{timers: 1, signal: nil, stop: "", advance: ttl, expect: "term"},
+				{timers: 1, signal: nil, stop: "", advance: grace - time.Second, expect: ""},
+				{timers: 1, signal: nil, stop: "", advance: time.Second, expect: ""},
+			},
+			want:    supervisor.Result{ExitCode: 0, TTLExpired: true, Stopped: false, Unhealthy: nil},
+			reasons: []string{"ttl expired"},
+		},
+		{
+			name:  "zero grace kills at ttl expiry",
+			child: childIgnoreTerm,
+			grace: 0,
+			steps: []step{
+				{timers: 1, signal: nil, stop: "", advance: ttl, expect: ""},
+			},
+			want:    supervisor.Result{ExitCode: 0, TTLExpired: true, Stopped: false, Unhealthy: nil},
+			reasons: []string{"ttl expired"},
+		},
+		{
+			name:  "forwarded signal stops the child before the ttl",
+			child: childExitOnTerm,
+			grace: grace,
+			steps: []step{
+				{timers: 1, signal: syscall.SIGTERM, stop: "", advance: 0, expect: "term"},
+			},
+			want:    supervisor.Result{ExitCode: 0, TTLExpired: false, Stopped: false, Unhealthy: nil},
+			reasons: []string{"signal terminated"},
+		},
+		{
+			name:  "ttl firing while a forwarded signal is pending",
+			child: childIgnoreTerm,
+			grace: grace,
+			steps: []step{
+				{timers: 1, signal: syscall.SIGTERM, stop: "", advance: 0, expect: "term"},
+				{timers: 1, signal: nil, stop: "", advance: ttl, expect: "term"},
+				{timers: 1, signal: nil, stop: "", advance: grace, expect: ""},
+			},
+			want:    supervisor.Result{ExitCode: 0, TTLExpired: true, Stopped: false, Unhealthy: nil},
+			reasons: []string{"signal terminated"},
+		},
+		{
+			name:  "stop request is escalated to SIGKILL after the grace",
+			child: childIgnoreTerm,
+			grace: grace,
+			steps: []step{
+				{timers: 1, signal: nil, stop: "rollback", advance: 0, expect: "term"},
+				{timers: 2, signal: nil, stop: "", advance: grace, expect: ""},
+			},
+			want:    supervisor.Result{ExitCode: 128 + int(syscall.SIGKILL), TTLExpired: false, Stopped: true, Unhealthy: nil},
+			reasons: []string{"rollback"},
+		},
+		{
+			name:     "deadline is measured on the supplied clock",
+			child:    childExitOnTerm,
+			grace:    grace,
+			deadline: true,
+			steps: []step{
+				{timers: 1, signal: nil, stop: "", advance: time.Minute - time.Second, expect: ""},
+				{timers: 1, signal: nil, stop: "", advance: time.Second, expect: "term"},
+			},
+			want:    supervisor.Result{ExitCode: 0, TTLExpired: true, Stopped: false, Unhealthy: nil},
+			reasons: []string{"ttl expired"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := clocktest.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+			proc := newChild(t, test.child)
+			signals := make(chan os.Signal, 1)
+
+			var reasons []string
+
+			opts := []supervisor.Option{
+				supervisor.WithClock(clock),
+				supervisor.WithSignals(signals),
+				supervisor.WithPreShutdownHook(func(reason string) { reasons = append(reasons, reason) }),
+			}
+
+			if test.deadline {
+				opts = append(opts, supervisor.WithDeadline(clock.Now().Add(time.Minute)))
+			}
+
+			sup := supervisor.NewSupervisor(proc.cmd, ttl, test.grace, opts...)
+			done := make(chan outcome, 1)
+
+			go func() {
+				result, err := sup.Run()
+				done <- outcome{result: result, err: err}
+			}()
+
+			proc.expect(t, "ready")
+
+			for _, s := range test.steps {
+				clock.BlockUntil(s.timers)
+
+				if s.signal != nil {
+					signals <- s.signal
+				}
+
+				if s.stop != "" {
+					sup.Stop(s.stop)
+				}
+
+				clock.Advance(s.advance)
+
+				if s.expect != "" {
+					proc.expect(t, s.expect)
+				}
+			}
+
+			var got outcome
+
+			select {
+			case got = <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("supervisor did not return")
+			}
+
+			if got.err != nil || got.result != test.want {
+				t.Fatalf("Run = %+v, %v; want %+v", got.result, got.err, test.want)
+			}
+
+			if fmt.Sprint(reasons) != fmt.Sprint(test.reasons) {
+				t.Errorf("pre-shutdown reasons = %q, want %q", reasons, test.reasons)
+			}
+
+			if pending := clock.Timers(); pending != 0 {
+				t.Errorf("%d timers still pending after Run returned", pending)
+			}
+		})
+	}
+}
+
+func TestChildExitCode(t *testing.T) {
+	t.Parallel()
+
+	clock := clocktest.NewFake(time.Now())
+	cmd := exec.Command("sh", "-c", "exit 3")
+
+	result, err := supervisor.Run(cmd, ttl, grace, supervisor.WithClock(clock))
+	if err != nil || result.ExitCode != 3 || result.TTLExpired {
+		t.Fatalf("Run = %+v, %v; want exit code 3", result, err)
+	}
+}
+
+func TestPreShutdownHookRunsAfterChildSignalled(t *testing.T) {
+	t.Parallel()
+
+	clock := clocktest.NewFake(time.Now())
+	proc := newChild(t, childExitOnTerm)
+	signalledFirst := make(chan bool, 1)
+
+	// The hook only returns once the child has reported the SIGTERM, which it cannot do if the
+	// supervisor waits for the hook before signalling.
+	hook := func(string) {
+		select {
+		case line := <-proc.lines:
+			signalledFirst <- line == "term"
+		case <-time.After(10 * time.Second):
+			signalledFirst <- false
+		}
+	}
+
+	sup := supervisor.NewSupervisor(proc.cmd, ttl, grace,
+		supervisor.WithClock(clock), supervisor.WithPreShutdownHook(hook))
+	done := make(chan outcome, 1)
+
+	go func() {
+		result, err := sup.Run()
+		done <- outcome{result: result, err: err}
+	}()
+
+	proc.expect(t, "ready")
+	sup.Stop("test")
+
+	if !<-signalledFirst {
+		t.Fatal("pre-shutdown hook ran before the child was signalled")
+	}
+
+	if got := <-done; got.err != nil || !got.result.Stopped {
+		t.Fatalf("Run = %+v, %v; want a requested stop", got.result, got.err)
+	}
+}
+
+func TestRestartKillsChildIgnoringTermAfterTheGrace(t *testing.T) {
+	t.Parallel()
+
+	clock := clocktest.NewFake(time.Now())
+	first := newChild(t, childIgnoreTerm)
+	second := newChild(t, childExitOnTerm)
+	requests := make(chan struct{}, 1)
+	started := make(chan int, 2)
+
+	sup := supervisor.NewSupervisor(first.cmd, ttl, grace,
+		supervisor.WithClock(clock),
+		supervisor.WithStartHook(func(pid int) { started <- pid }),
+		supervisor.WithRestarts(requests, func() *exec.Cmd { return second.cmd }))
+	done := make(chan outcome, 1)
+
+	go func() {
+		result, err := sup.Run()
+		done <- outcome{result: result, err: err}
+	}()
+
+	first.expect(t, "ready")
+
+	firstPID := <-started
+	requests <- struct{}{}
+
+	first.expect(t, "term")
+	clock.BlockUntil(2)
+	clock.Advance(grace)
+	second.expect(t, "ready")
+
+	if secondPID := <-started; secondPID == firstPID {
+		t.Fatalf("restarted child has the first child's pid %d", firstPID)
+	}
+
+	sup.Stop("test")
+	second.expect(t, "term")
+
+	var got outcome
+
+	select {
+	case got = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("supervisor did not return")
+	}
+
+	if got.err != nil || got.result != (supervisor.Result{ExitCode: 0, TTLExpired: false, Stopped: true, Unhealthy: nil}) {
+		t.Fatalf("Run = %+v, %v; want the replacement stopped on request", got.result, got.err)
+	}
+
+	if pending := clock.Timers(); pending != 0 {
+		t.Errorf("%d timers still pending after Run returned", pending)
+	}
+}